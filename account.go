@@ -30,6 +30,7 @@ func (c *Client) GetPortfolio(ctx context.Context, portfolio string) (Portfolio,
 	r := &request{
 		method:   http.MethodGet,
 		endpoint: queryURL.String(),
+		route:    "/md/v2/Clients/{exchange}/{portfolio}/summary",
 	}
 	result := Portfolio{}
 	data, err := c.callAPI(ctx, r)
@@ -50,6 +51,7 @@ func (c *Client) GetPortfolioRisk(ctx context.Context, portfolio string) (Portfo
 	r := &request{
 		method:   http.MethodGet,
 		endpoint: queryURL.String(),
+		route:    "/md/v2/Clients/{exchange}/{portfolio}/risk",
 	}
 	result := PortfolioRisk{}
 	data, err := c.callAPI(ctx, r)
@@ -70,6 +72,7 @@ func (c *Client) GetPortfolioFortsRisk(ctx context.Context, portfolio string) (P
 	r := &request{
 		method:   http.MethodGet,
 		endpoint: queryURL.String(),
+		route:    "/md/v2/Clients/{exchange}/{portfolio}/fortsrisk",
 	}
 	result := PortfolioFortsRisk{}
 	data, err := c.callAPI(ctx, r)
@@ -91,6 +94,7 @@ func (c *Client) GetPositions(ctx context.Context, portfolio string) ([]Position
 	r := &request{
 		method:   http.MethodGet,
 		endpoint: queryURL.String(),
+		route:    "/md/v2/Clients/{exchange}/{portfolio}/positions",
 	}
 	result := make([]Position, 0)
 	data, err := c.callAPI(ctx, r)
@@ -113,6 +117,7 @@ func (c *Client) GetPosition(ctx context.Context, portfolio, symbol string) (Pos
 	r := &request{
 		method:   http.MethodGet,
 		endpoint: queryURL.String(),
+		route:    "/md/v2/Clients/{exchange}/{portfolio}/positions/{symbol}",
 	}
 	result := Position{}
 	data, err := c.callAPI(ctx, r)
@@ -139,6 +144,7 @@ func (c *Client) GetLoginPositions(ctx context.Context, login string) ([]Positio
 	r := &request{
 		method:   http.MethodGet,
 		endpoint: queryURL.String(),
+		route:    "/md/v2/Clients/{login}/positions",
 	}
 	result := make([]Position, 0)
 	data, err := c.callAPI(ctx, r)
@@ -23,10 +23,10 @@ type IAlorClient interface {
 	GetSecurities(ctx context.Context, opts ...Option) ([]Security, error)
 
 	// GetQuotes Получение информации о котировках для выбранных инструментов
-	GetQuotes(ctx context.Context, symbols string) ([]Quote, error)
+	GetQuotes(ctx context.Context, symbols string, opts ...Option) ([]Quote, error)
 
 	// GetQuote Получение информации о котировках для одного выбранного инструмента
-	GetQuote(ctx context.Context, symbol string) (Quote, error)
+	GetQuote(ctx context.Context, symbol string, opts ...Option) (Quote, error)
 
 	// GetPortfolio Получение информации о портфеле
 	GetPortfolio(ctx context.Context, portfolio string) (Portfolio, error)
@@ -37,6 +37,12 @@ type IAlorClient interface {
 	// GetPortfolioRisk Получение информации по рискам срочного рынка (FORTS) для указанного портфеля.
 	GetPortfolioFortsRisk(ctx context.Context, portfolio string) (PortfolioFortsRisk, error)
 
+	// ListPortfolios список портфелей пользователя (логин из JWT токена)
+	ListPortfolios(ctx context.Context) ([]PortfolioInfo, error)
+
+	// GetConsolidatedPortfolio сводный портфель по всем рынкам (stock, forts, fx)
+	GetConsolidatedPortfolio(ctx context.Context) (ConsolidatedPortfolio, error)
+
 	// GetPositions получение информации о позициях
 	GetPositions(ctx context.Context, portfolio string) ([]Position, error)
 
@@ -44,13 +50,13 @@ type IAlorClient interface {
 	GetPosition(ctx context.Context, portfolio, symbol string) (Position, bool, error)
 
 	// GetHistory Запрос истории для выбранных биржи и инструмента
-	GetHistory(ctx context.Context, symbol string, interval Interval, from, to int64) (History, error)
+	GetHistory(ctx context.Context, symbol string, interval Interval, from, to int64, opts ...Option) (History, error)
 
 	// GetCandles Запрос истории свечей для выбранного инструмента (вызывает GetHistory)
-	GetCandles(ctx context.Context, symbol string, interval Interval, from, to int64) ([]Candle, error)
+	GetCandles(ctx context.Context, symbol string, interval Interval, from, to int64, opts ...Option) ([]Candle, error)
 
 	// GetOrderBooks Получение информации о биржевом стакане
-	GetOrderBooks(ctx context.Context, symbol string) (OrderBook, error)
+	GetOrderBooks(ctx context.Context, symbol string, opts ...Option) (OrderBook, error)
 
 	// GetOrders получение информации о всех заявках
 	GetOrders(ctx context.Context, portfolio string) ([]Order, error)
@@ -76,6 +82,9 @@ type IAlorClient interface {
 	// CancelOrder отменить заявку
 	CancelOrder(ctx context.Context, portfolio, orderId string) (bool, error)
 
+	// CancelAllOrders отменить все активные заявки (в том числе стоп-заявки) по портфелю
+	CancelAllOrders(ctx context.Context, portfolio string, opts ...CancelOption) (CancelReport, error)
+
 	// SubscribeCandles подписка на свечи
 	SubscribeCandles(ctx context.Context, symbol string, interval Interval, opts ...WSRequestOption) error
 
@@ -84,6 +93,12 @@ type IAlorClient interface {
 
 	// SubscribeOrders подписка на получение информации обо всех биржевых заявках с участием указанного портфеля
 	SubscribeOrders(ctx context.Context, portfolio string, opts ...WSRequestOption) error
+
+	// SubscribeAllTrades подписка на ленту всех сделок
+	SubscribeAllTrades(ctx context.Context, symbol string, opts ...WSRequestOption) error
+
+	// SubscribeBars подписка на бары, построенные из ленты всех сделок
+	SubscribeBars(ctx context.Context, symbol string, spec BarSpec, opts ...WSRequestOption) error
 }
 
 // GetTime
@@ -93,6 +108,7 @@ func (c *Client) GetTime(ctx context.Context) (time.Time, error) {
 	r := &request{
 		method:   http.MethodGet,
 		endpoint: "/md/v2/time",
+		route:    "/md/v2/time",
 	}
 	//t := time.Now()
 
@@ -109,7 +125,10 @@ func (c *Client) GetTime(ctx context.Context) (time.Time, error) {
 }
 
 // GetPortfolioID получим номер счета от кода режима торгов
+// номера счетов задаются вручную (SetStockPortfolio и т.д.) или вызовом DiscoverPortfolios
 func (c *Client) GetPortfolioID(board string) string {
+	c.portfolioMu.RLock()
+	defer c.portfolioMu.RUnlock()
 	// Для валютного рынка
 	if board == "CETS" {
 		return c.fxPortfolio
@@ -2,10 +2,10 @@ package alor
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"net/http"
+	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 /*
@@ -19,44 +19,182 @@ import (
 
 */
 //  Срок действия access токена составляет 30 минут. Ограничим 25 минут
-const jwtTokenTtl = 25 // Время жизни токена JWT в минутах
+const jwtTokenTtl = 25 // Время жизни токена JWT в минутах (если в токене нет claim exp)
+
+const (
+	jwtExpiryMargin  = time.Minute     // токен считаем истекшим за минуту до exp
+	jwtRenewBefore   = 3 * time.Minute // фоновое обновление токена за 3 минуты до exp
+	jwtRetryInterval = 30 * time.Second
+	jwtRetryMax      = 10 * time.Minute // максимальная пауза между повторами после ошибок обновления
+)
 
 type JSResp struct {
 	AccessToken string
 }
 
+// jwtRefresh обновление токена, которое сейчас выполняется (single-flight)
+type jwtRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// tokenListener функция, которую вызываем после обновления токена
+type tokenListener func(token string)
+
+// jwtState состояние access токена клиента
+type jwtState struct {
+	mu         sync.Mutex
+	refreshing *jwtRefresh           // текущее обновление токена
+	renewTimer *time.Timer           // таймер фонового обновления
+	listeners  map[int]tokenListener // подписчики на обновление токена (websocket соединения)
+	nextID     int
+	failures   int  // кол-во ошибок обновления подряд (для паузы перед повтором)
+	closed     bool // клиент закрыт (Close): фоновое обновление не планируем
+}
+
 // GetJWT получим accessToken
+// Безопасно для одновременного вызова: если токен обновляется, остальные горутины ждут результат
 func (c *Client) GetJWT() (string, error) {
-	//log.Debug("зашли в НОВУЮ GetJWT")
-	if c.refreshToken == "" {
-		c.accessToken = ""
-		return c.accessToken, nil
+	return c.getJWT(context.Background())
+}
+
+// getJWT получим accessToken (ctx для трассировки)
+func (c *Client) getJWT(ctx context.Context) (string, error) {
+	if c.tokenSource == nil {
+		return "", nil
 	}
+	c.jwt.mu.Lock()
 	// если не пустой токен и время окончания токена больше текущего время
-	if c.accessToken != "" && c.cancelTimeToken.After(time.Now()) {
-		//c.debug("GetJWT Не надо формировать новый токен")
-		return c.accessToken, nil
+	if c.accessToken != "" && time.Now().Before(c.cancelTimeToken) {
+		token := c.accessToken
+		c.jwt.mu.Unlock()
+		return token, nil
+	}
+	c.jwt.mu.Unlock()
+	ctx, span := c.startSpan(ctx, "alor.GetJWT")
+	token, err := c.refreshJWT(ctx)
+	endSpan(span, err)
+	return token, err
+}
+
+// refreshJWT получим новый токен. Одновременные вызовы выполняют один запрос
+// запрос токена не прерывается при отмене ctx вызвавшей горутины (результат ждут и другие)
+func (c *Client) refreshJWT(ctx context.Context) (string, error) {
+	c.jwt.mu.Lock()
+	if f := c.jwt.refreshing; f != nil {
+		c.jwt.mu.Unlock()
+		trace.SpanFromContext(ctx).AddEvent("wait refresh in progress")
+		<-f.done
+		return f.token, f.err
+	}
+	f := &jwtRefresh{done: make(chan struct{})}
+	c.jwt.refreshing = f
+	c.jwt.mu.Unlock()
+
+	f.token, f.err = c.tokenSource.Token(context.WithoutCancel(ctx))
+	c.metrics.TokenRefresh(f.err)
 
+	c.jwt.mu.Lock()
+	c.jwt.refreshing = nil
+	var listeners []tokenListener
+	if f.err == nil {
+		c.jwt.failures = 0
+		expiry := jwtExpiry(f.token)
+		c.cancelTimeToken = expiry.Add(-jwtExpiryMargin)
+		c.scheduleRenewLocked(time.Until(expiry.Add(-jwtRenewBefore)))
+		if f.token != c.accessToken {
+			for _, l := range c.jwt.listeners {
+				listeners = append(listeners, l)
+			}
+		}
+		c.accessToken = f.token
+	} else if c.accessToken != "" {
+		// старый токен еще может быть действителен: попробуем обновить позже
+		// пауза удваивается после каждой ошибки подряд (не больше jwtRetryMax)
+		c.jwt.failures++
+		c.scheduleRenewLocked(jwtRetryDelay(c.jwt.failures))
 	}
-	r := &request{
-		method:           http.MethodPost,
-		endpoint:         "/refresh",
-		notAuthorization: true, // Проставить обязательно. Иначе будет ошибка
+	c.jwt.mu.Unlock()
+	close(f.done)
+
+	// сообщим websocket соединениям о новом токене
+	for _, l := range listeners {
+		go l(f.token)
+	}
+	return f.token, f.err
+}
+
+// jwtExpiry время окончания действия токена (claim exp)
+// если exp нет, считаем что токен действует jwtTokenTtl минут
+func jwtExpiry(token string) time.Time {
+	claims, err := parseJWTClaims(token)
+	if err != nil || claims.Exp == 0 {
+		return time.Now().Add(jwtTokenTtl * time.Minute)
 	}
-	r.baseURL = getOauthEndPoint()
-	r.setParam("token", c.refreshToken)
+	return time.Unix(claims.Exp, 0)
+}
 
-	var result JSResp
-	data, err := c.callAPI(context.Background(), r)
-	if err != nil {
-		return "", fmt.Errorf("ошибка получения JWT токена: %w", err)
+// jwtRetryDelay пауза перед повтором обновления после failures ошибок подряд
+func jwtRetryDelay(failures int) time.Duration {
+	d := jwtRetryInterval
+	for n := 1; n < failures && d < jwtRetryMax; n++ {
+		d *= 2
 	}
-	if err = json.Unmarshal(data, &result); err != nil {
-		return "", fmt.Errorf("ошибка получения JWT токена: %w", err)
+	if d > jwtRetryMax {
+		d = jwtRetryMax
 	}
-	c.cancelTimeToken = time.Now().Add(jwtTokenTtl * time.Minute)
-	c.accessToken = result.AccessToken
+	return d
+}
 
-	return c.accessToken, nil
+// scheduleRenewLocked запланируем фоновое обновление токена. Вызывать под блокировкой c.jwt.mu
+// для источников, которые не могут выдать новый токен (StaticTokenSource), и после Close не планируется
+func (c *Client) scheduleRenewLocked(d time.Duration) {
+	if c.jwt.renewTimer != nil {
+		c.jwt.renewTimer.Stop()
+		c.jwt.renewTimer = nil
+	}
+	if c.jwt.closed || !canRefreshToken(c.tokenSource) {
+		return
+	}
+	// не чаще чем раз в jwtRetryInterval (токен мог прийти уже истекшим)
+	if d < jwtRetryInterval {
+		d = jwtRetryInterval
+	}
+	c.jwt.renewTimer = time.AfterFunc(d, func() {
+		if _, err := c.refreshJWT(context.Background()); err != nil {
+			log.Error("фоновое обновление JWT токена", "err", err.Error())
+		}
+	})
+}
 
+// onTokenRefresh зарегистрируем функцию на обновление токена
+// возвращает функцию для отмены регистрации
+func (c *Client) onTokenRefresh(f tokenListener) func() {
+	c.jwt.mu.Lock()
+	defer c.jwt.mu.Unlock()
+	if c.jwt.listeners == nil {
+		c.jwt.listeners = make(map[int]tokenListener)
+	}
+	id := c.jwt.nextID
+	c.jwt.nextID++
+	c.jwt.listeners[id] = f
+	return func() {
+		c.jwt.mu.Lock()
+		defer c.jwt.mu.Unlock()
+		delete(c.jwt.listeners, id)
+	}
+}
+
+// Close остановим фоновое обновление токена
+// запросы после Close получают токен как обычно (по требованию), но без фонового обновления
+func (c *Client) Close() error {
+	c.jwt.mu.Lock()
+	defer c.jwt.mu.Unlock()
+	c.jwt.closed = true
+	if c.jwt.renewTimer != nil {
+		c.jwt.renewTimer.Stop()
+		c.jwt.renewTimer = nil
+	}
+	return nil
 }
@@ -0,0 +1,185 @@
+package alor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func renewScheduled(c *Client) bool {
+	c.jwt.mu.Lock()
+	defer c.jwt.mu.Unlock()
+	return c.jwt.renewTimer != nil
+}
+
+// expireToken токен клиента считаем истекшим (следующий запрос обновит его)
+func expireToken(c *Client) {
+	c.jwt.mu.Lock()
+	defer c.jwt.mu.Unlock()
+	c.cancelTimeToken = time.Now().Add(-time.Minute)
+}
+
+func TestJWTRenewSchedule(t *testing.T) {
+	refresh := TokenSourceFunc(func(ctx context.Context) (string, error) { return "a.b.c", nil })
+	tests := []struct {
+		name   string
+		source TokenSource
+		renew  bool
+	}{
+		{"StaticTokenSource", StaticTokenSource("a.b.c"), false},
+		{"FileTokenSource(StaticTokenSource)", NewFileTokenSource(t.TempDir()+"/static.token", StaticTokenSource("a.b.c")), false},
+		{"TokenSourceFunc", refresh, true},
+		{"FileTokenSource(TokenSourceFunc)", NewFileTokenSource(t.TempDir()+"/func.token", refresh), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient("", WithTokenSource(tt.source))
+			defer c.Close()
+			if _, err := c.GetJWT(); err != nil {
+				t.Fatal(err)
+			}
+			if got := renewScheduled(c); got != tt.renew {
+				t.Fatalf("фоновое обновление %v, ожидали %v", got, tt.renew)
+			}
+		})
+	}
+}
+
+func TestClientClose(t *testing.T) {
+	var calls atomic.Int32
+	c := NewClient("", WithTokenSource(TokenSourceFunc(func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		return "a.b.c", nil
+	})))
+	if _, err := c.GetJWT(); err != nil {
+		t.Fatal(err)
+	}
+	if !renewScheduled(c) {
+		t.Fatal("фоновое обновление не запланировано")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if renewScheduled(c) {
+		t.Fatal("таймер обновления не остановлен")
+	}
+
+	// после Close токен обновляется по требованию, но без фонового обновления
+	expireToken(c)
+	if _, err := c.GetJWT(); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("токен получен %d раз, ожидали 2", calls.Load())
+	}
+	if renewScheduled(c) {
+		t.Fatal("фоновое обновление после Close")
+	}
+}
+
+func TestJWTRetryBackoff(t *testing.T) {
+	var fail atomic.Bool
+	c := NewClient("", WithTokenSource(TokenSourceFunc(func(ctx context.Context) (string, error) {
+		if fail.Load() {
+			return "", errors.New("сервер авторизации недоступен")
+		}
+		return "a.b.c", nil
+	})))
+	defer c.Close()
+	if _, err := c.GetJWT(); err != nil {
+		t.Fatal(err)
+	}
+
+	fail.Store(true)
+	for n := 1; n <= 3; n++ {
+		expireToken(c)
+		if _, err := c.GetJWT(); err == nil {
+			t.Fatal("ожидали ошибку")
+		}
+		c.jwt.mu.Lock()
+		failures := c.jwt.failures
+		c.jwt.mu.Unlock()
+		if failures != n {
+			t.Fatalf("ошибок подряд %d, ожидали %d", failures, n)
+		}
+		if !renewScheduled(c) {
+			t.Fatal("повтор обновления не запланирован")
+		}
+	}
+
+	fail.Store(false)
+	expireToken(c)
+	if _, err := c.GetJWT(); err != nil {
+		t.Fatal(err)
+	}
+	if c.jwt.failures != 0 {
+		t.Fatalf("после успешного обновления ошибок %d", c.jwt.failures)
+	}
+}
+
+func TestJWTRetryDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, jwtRetryInterval},
+		{2, 2 * jwtRetryInterval},
+		{3, 4 * jwtRetryInterval},
+		{5, 16 * jwtRetryInterval},
+		{6, jwtRetryMax},
+		{100, jwtRetryMax},
+	}
+	for _, tt := range tests {
+		if got := jwtRetryDelay(tt.failures); got != tt.want {
+			t.Errorf("jwtRetryDelay(%d) = %v, ожидали %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+// TestRefreshTokenSource токен запрашивается на сервере авторизации клиента (POST /refresh без авторизации)
+// и передается в REST запросы; ошибка источника токена возвращается без запроса к API
+func TestRefreshTokenSource(t *testing.T) {
+	token := testJWT(`{"sub":"P039004"}`)
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.URL.RawQuery+" "+r.Header.Get("Authorization"))
+		mu.Unlock()
+		if r.URL.Path == "/refresh" {
+			_, _ = w.Write([]byte(`{"AccessToken":"` + token + `"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}, WithTokenSource(NewRefreshTokenSource("my-refresh")))
+	defer c.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetPortfolio(context.Background(), "D39004"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{
+		"POST /refresh token=my-refresh ",
+		"GET /md/v2/Clients/MOEX/D39004/summary  Bearer " + token,
+		"GET /md/v2/Clients/MOEX/D39004/summary  Bearer " + token,
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !equalSlices(requests, want) {
+		t.Fatalf("запросы %q, ожидали %q", requests, want)
+	}
+
+	errSource := errors.New("нет токена")
+	c = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("запрос к API без токена: %s", r.URL.Path)
+	}, WithTokenSource(TokenSourceFunc(func(ctx context.Context) (string, error) { return "", errSource })))
+	if _, err := c.GetPortfolio(context.Background(), "D39004"); !errors.Is(err, errSource) {
+		t.Fatalf("ошибка %v, ожидали ошибку источника токена", err)
+	}
+}
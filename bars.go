@@ -0,0 +1,230 @@
+package alor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+/*
+	Построение баров из ленты всех сделок (AllTradesGetAndSubscribe)
+	Alor отдает свечи только по фиксированным таймфреймам.
+	BarAggregator позволяет строить:
+	 - временные бары произвольной длительности (например 10 секунд)
+	 - тиковые бары (например 100 сделок)
+	 - объемные бары (например 10 000 лот)
+	 - range бары (например 10 шагов цены)
+*/
+
+// BarType тип агрегируемого бара
+type BarType string
+
+const (
+	BarTypeTime   BarType = "time"   // Временной бар. Size = длительность в секундах
+	BarTypeTick   BarType = "tick"   // Тиковый бар. Size = кол-во сделок
+	BarTypeVolume BarType = "volume" // Объемный бар. Size = кол-во лот
+	BarTypeRange  BarType = "range"  // Range бар. Size = кол-во шагов цены (MinStep)
+)
+
+// BarSpec параметры агрегируемого бара
+type BarSpec struct {
+	Type BarType // Тип бара
+	Size int64   // Размер бара (зависит от типа)
+}
+
+// Validate проверим тип и размер бара
+func (b BarSpec) Validate() error {
+	switch b.Type {
+	case BarTypeTime, BarTypeTick, BarTypeVolume, BarTypeRange:
+	default:
+		return fmt.Errorf("не известный тип бара %q", b.Type)
+	}
+	if b.Size <= 0 {
+		return fmt.Errorf("не верный размер бара %d", b.Size)
+	}
+	return nil
+}
+
+// Interval вернем интервал бара для заполнения Candle.Interval
+// временной бар = кол-во секунд ("10"), остальные = префикс + размер ("T100", "V10000", "R5")
+func (b BarSpec) Interval() Interval {
+	size := strconv.FormatInt(b.Size, 10)
+	switch b.Type {
+	case BarTypeTick:
+		return Interval("T" + size)
+	case BarTypeVolume:
+		return Interval("V" + size)
+	case BarTypeRange:
+		return Interval("R" + size)
+	default:
+		return Interval(size)
+	}
+}
+
+// BarAggregator строит бары из потока сделок
+// Закрытый бар передается в функцию onBar (по умолчанию Client.PublishCandleClosed)
+// Не потокобезопасный: сделки должны поступать из одной горутины
+type BarAggregator struct {
+	symbol      string          // Тикер
+	spec        BarSpec         // Параметры бара
+	minStep     float64         // Минимальный шаг цены (для range баров)
+	onBar       CandleCloseFunc // Функция обработки закрытого бара
+	bar         Candle          // Текущий (не закрытый) бар
+	hasBar      bool            // Текущий бар начат
+	lastTradeID int64           // ID последней обработанной сделки
+	history     []AllTrade      // Буфер сделок из "снепшота" истории
+}
+
+// NewBarAggregator создать новый агрегатор баров
+// minStep нужен только для range баров
+func NewBarAggregator(symbol string, spec BarSpec, minStep float64, onBar CandleCloseFunc) *BarAggregator {
+	return &BarAggregator{
+		symbol:  symbol,
+		spec:    spec,
+		minStep: minStep,
+		onBar:   onBar,
+	}
+}
+
+// Add обработаем новую сделку
+// Сделки из истории (Existing = true) копятся в буфере и обрабатываются
+// в порядке возрастания ID при поступлении первой новой сделки (или при вызове Flush)
+// Повторно присланные сделки (например после реконнекта) отбрасываются по ID
+func (a *BarAggregator) Add(trade AllTrade) {
+	if trade.Existing {
+		a.history = append(a.history, trade)
+		return
+	}
+	a.replayHistory()
+	a.add(trade)
+}
+
+// Flush обработаем накопленную историю и принудительно закроем текущий бар
+func (a *BarAggregator) Flush() {
+	a.replayHistory()
+	if a.hasBar {
+		a.closeBar()
+	}
+}
+
+// Current вернем текущий (не закрытый) бар
+func (a *BarAggregator) Current() (Candle, bool) {
+	return a.bar, a.hasBar
+}
+
+// replayHistory обработаем сделки из истории в порядке возрастания ID
+func (a *BarAggregator) replayHistory() {
+	if len(a.history) == 0 {
+		return
+	}
+	sort.Slice(a.history, func(i, j int) bool {
+		return a.history[i].ID < a.history[j].ID
+	})
+	for _, trade := range a.history {
+		a.add(trade)
+	}
+	a.history = a.history[:0]
+}
+
+func (a *BarAggregator) add(trade AllTrade) {
+	// такую сделку уже обработали
+	if trade.ID != 0 && trade.ID <= a.lastTradeID {
+		return
+	}
+	if trade.ID != 0 {
+		a.lastTradeID = trade.ID
+	}
+
+	if a.hasBar && a.isNewBar(trade) {
+		a.closeBar()
+	}
+	if !a.hasBar {
+		a.openBar(trade)
+	} else {
+		a.updateBar(trade)
+	}
+	if a.isComplete() {
+		a.closeBar()
+	}
+}
+
+// isNewBar сделка не входит в текущий бар
+func (a *BarAggregator) isNewBar(trade AllTrade) bool {
+	switch a.spec.Type {
+	case BarTypeTime:
+		return a.barTime(trade) != a.bar.Time
+	case BarTypeRange:
+		size := float64(a.spec.Size) * a.minStep
+		high := math.Max(a.bar.High, trade.Price)
+		low := math.Min(a.bar.Low, trade.Price)
+		return high-low > size+a.minStep/2
+	default:
+		return false
+	}
+}
+
+// isComplete текущий бар набрал нужный размер
+func (a *BarAggregator) isComplete() bool {
+	switch a.spec.Type {
+	case BarTypeTick:
+		return int64(a.bar.Trades) >= a.spec.Size
+	case BarTypeVolume:
+		return int64(a.bar.Volume) >= a.spec.Size
+	default:
+		return false
+	}
+}
+
+// barTime время начала бара для сделки
+func (a *BarAggregator) barTime(trade AllTrade) int64 {
+	sec := trade.Timestamp / 1000
+	if a.spec.Type == BarTypeTime && a.spec.Size > 0 {
+		return sec - sec%a.spec.Size
+	}
+	return sec
+}
+
+func (a *BarAggregator) openBar(trade AllTrade) {
+	a.bar = Candle{
+		Symbol:   a.symbol,
+		Interval: a.spec.Interval(),
+		Time:     a.barTime(trade),
+		Open:     trade.Price,
+		High:     trade.Price,
+		Low:      trade.Price,
+		Close:    trade.Price,
+	}
+	a.hasBar = true
+	a.updateVolume(trade)
+}
+
+func (a *BarAggregator) updateBar(trade AllTrade) {
+	a.bar.High = math.Max(a.bar.High, trade.Price)
+	a.bar.Low = math.Min(a.bar.Low, trade.Price)
+	a.bar.Close = trade.Price
+	a.updateVolume(trade)
+}
+
+func (a *BarAggregator) updateVolume(trade AllTrade) {
+	a.bar.Volume += trade.Qty
+	a.bar.Trades++
+	switch trade.Side {
+	case SideTypeBuy:
+		a.bar.BuyVolume += trade.Qty
+	case SideTypeSell:
+		a.bar.SellVolume += trade.Qty
+	}
+	if trade.OpenInterest != 0 {
+		a.bar.OpenInterest = trade.OpenInterest
+	}
+}
+
+func (a *BarAggregator) closeBar() {
+	bar := a.bar
+	a.bar = Candle{}
+	a.hasBar = false
+	if a.onBar != nil {
+		a.onBar(bar)
+	}
+}
@@ -0,0 +1,239 @@
+package alor
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// barTrade сделка ленты: время в миллисекундах
+func barTrade(id, ms int64, price float64, qty int32, side SideType) AllTrade {
+	return AllTrade{ID: id, Symbol: "SBER", Timestamp: ms, Price: price, Qty: qty, Side: side}
+}
+
+func existing(trade AllTrade) AllTrade {
+	trade.Existing = true
+	return trade
+}
+
+// bar ожидаемый бар: время, OHLC, объем, кол-во сделок
+func bar(time int64, open, high, low, close float64, volume, trades int32) Candle {
+	return Candle{Time: time, Open: open, High: high, Low: low, Close: close, Volume: volume, Trades: trades}
+}
+
+func TestBarAggregator(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    BarSpec
+		minStep float64
+		trades  []AllTrade
+		flush   bool
+		want    []Candle
+	}{
+		{
+			name: "time 10 секунд",
+			spec: BarSpec{Type: BarTypeTime, Size: 10},
+			trades: []AllTrade{
+				barTrade(1, 100_000, 250, 1, SideTypeBuy),
+				barTrade(2, 103_500, 252, 2, SideTypeSell),
+				barTrade(3, 109_999, 249, 3, SideTypeBuy),
+				barTrade(4, 110_000, 251, 1, SideTypeBuy),
+				barTrade(5, 125_000, 253, 1, SideTypeBuy),
+			},
+			want: []Candle{
+				bar(100, 250, 252, 249, 249, 6, 3),
+				bar(110, 251, 251, 251, 251, 1, 1),
+			},
+		},
+		{
+			name: "time 10 секунд, Flush",
+			spec: BarSpec{Type: BarTypeTime, Size: 10},
+			trades: []AllTrade{
+				barTrade(1, 100_000, 250, 1, SideTypeBuy),
+				barTrade(2, 125_000, 253, 1, SideTypeBuy),
+			},
+			flush: true,
+			want: []Candle{
+				bar(100, 250, 250, 250, 250, 1, 1),
+				bar(120, 253, 253, 253, 253, 1, 1),
+			},
+		},
+		{
+			name: "tick 3",
+			spec: BarSpec{Type: BarTypeTick, Size: 3},
+			trades: []AllTrade{
+				barTrade(1, 100_000, 250, 1, SideTypeBuy),
+				barTrade(2, 101_000, 251, 1, SideTypeBuy),
+				barTrade(3, 102_000, 249, 1, SideTypeSell),
+				barTrade(4, 103_000, 248, 2, SideTypeSell),
+				barTrade(5, 104_000, 247, 1, SideTypeSell),
+				barTrade(6, 105_000, 250, 1, SideTypeBuy),
+				barTrade(7, 106_000, 252, 5, SideTypeBuy),
+			},
+			flush: true,
+			want: []Candle{
+				bar(100, 250, 251, 249, 249, 3, 3),
+				bar(103, 248, 250, 247, 250, 4, 3),
+				bar(106, 252, 252, 252, 252, 5, 1),
+			},
+		},
+		{
+			name: "volume 10",
+			spec: BarSpec{Type: BarTypeVolume, Size: 10},
+			trades: []AllTrade{
+				barTrade(1, 100_000, 250, 4, SideTypeBuy),
+				barTrade(2, 101_000, 251, 4, SideTypeBuy),
+				barTrade(3, 102_000, 252, 4, SideTypeBuy),
+				barTrade(4, 103_000, 253, 5, SideTypeSell),
+			},
+			want: []Candle{
+				bar(100, 250, 252, 250, 252, 12, 3),
+			},
+		},
+		{
+			name:    "range 2 шага цены",
+			spec:    BarSpec{Type: BarTypeRange, Size: 2},
+			minStep: 0.5,
+			trades: []AllTrade{
+				barTrade(1, 100_000, 250, 1, SideTypeBuy),
+				barTrade(2, 101_000, 250.5, 1, SideTypeBuy),
+				barTrade(3, 102_000, 251, 1, SideTypeBuy),   // диапазон 1.0 = 2 шага
+				barTrade(4, 103_000, 251.5, 1, SideTypeBuy), // диапазон 1.5: новый бар
+				barTrade(5, 104_000, 250.5, 1, SideTypeSell),
+				barTrade(6, 105_000, 250, 1, SideTypeSell), // диапазон 1.5: новый бар
+			},
+			flush: true,
+			want: []Candle{
+				bar(100, 250, 251, 250, 251, 3, 3),
+				bar(103, 251.5, 251.5, 250.5, 250.5, 2, 2),
+				bar(105, 250, 250, 250, 250, 1, 1),
+			},
+		},
+		{
+			name: "история до первой новой сделки: сортировка и повторы",
+			spec: BarSpec{Type: BarTypeTick, Size: 2},
+			trades: []AllTrade{
+				existing(barTrade(3, 102_000, 252, 1, SideTypeBuy)),
+				existing(barTrade(1, 100_000, 250, 1, SideTypeBuy)),
+				existing(barTrade(2, 101_000, 251, 1, SideTypeBuy)),
+				existing(barTrade(2, 101_000, 251, 1, SideTypeBuy)), // повтор в снепшоте
+				barTrade(2, 101_000, 251, 1, SideTypeBuy),           // та же сделка уже новой
+				barTrade(4, 103_000, 253, 1, SideTypeBuy),
+			},
+			want: []Candle{
+				bar(100, 250, 251, 250, 251, 2, 2),
+				bar(102, 252, 253, 252, 253, 2, 2),
+			},
+		},
+		{
+			name: "только история: бары после Flush",
+			spec: BarSpec{Type: BarTypeTick, Size: 2},
+			trades: []AllTrade{
+				existing(barTrade(2, 101_000, 251, 1, SideTypeBuy)),
+				existing(barTrade(1, 100_000, 250, 1, SideTypeBuy)),
+				existing(barTrade(3, 102_000, 252, 1, SideTypeBuy)),
+			},
+			flush: true,
+			want: []Candle{
+				bar(100, 250, 251, 250, 251, 2, 2),
+				bar(102, 252, 252, 252, 252, 1, 1),
+			},
+		},
+		{
+			name: "только история: без Flush баров нет",
+			spec: BarSpec{Type: BarTypeTick, Size: 1},
+			trades: []AllTrade{
+				existing(barTrade(1, 100_000, 250, 1, SideTypeBuy)),
+				existing(barTrade(2, 101_000, 251, 1, SideTypeBuy)),
+			},
+		},
+		{
+			name: "повтор после реконнекта",
+			spec: BarSpec{Type: BarTypeTick, Size: 2},
+			trades: []AllTrade{
+				barTrade(1, 100_000, 250, 1, SideTypeBuy),
+				barTrade(2, 101_000, 251, 1, SideTypeBuy),
+				existing(barTrade(1, 100_000, 250, 1, SideTypeBuy)),
+				existing(barTrade(2, 101_000, 251, 1, SideTypeBuy)),
+				barTrade(3, 102_000, 252, 1, SideTypeBuy),
+			},
+			flush: true,
+			want: []Candle{
+				bar(100, 250, 251, 250, 251, 2, 2),
+				bar(102, 252, 252, 252, 252, 1, 1),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Candle
+			a := NewBarAggregator("SBER", tt.spec, tt.minStep, func(c Candle) { got = append(got, c) })
+			for _, trade := range tt.trades {
+				a.Add(trade)
+			}
+			if tt.flush {
+				a.Flush()
+				if _, ok := a.Current(); ok {
+					t.Error("после Flush остался текущий бар")
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("баров %d, ожидали %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				c := got[i]
+				if c.Symbol != "SBER" || c.Interval != tt.spec.Interval() {
+					t.Errorf("бар %d: символ %s интервал %s", i, c.Symbol, c.Interval)
+				}
+				c.Symbol, c.Interval, c.BuyVolume, c.SellVolume = "", "", 0, 0
+				if c != want {
+					t.Errorf("бар %d = %+v, ожидали %+v", i, c, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBarAggregatorSideVolume(t *testing.T) {
+	var got Candle
+	a := NewBarAggregator("SBER", BarSpec{Type: BarTypeTick, Size: 3}, 0, func(c Candle) { got = c })
+	a.Add(barTrade(1, 100_000, 250, 2, SideTypeBuy))
+	a.Add(barTrade(2, 100_000, 250, 3, SideTypeSell))
+	a.Add(AllTrade{ID: 3, Timestamp: 100_000, Price: 250, Qty: 1, Side: SideTypeBuy, OpenInterest: 1000})
+	if got.BuyVolume != 3 || got.SellVolume != 3 || got.OpenInterest != 1000 {
+		t.Fatalf("бар %+v", got)
+	}
+}
+
+func TestBarSpec(t *testing.T) {
+	tests := []struct {
+		spec     BarSpec
+		interval Interval
+		valid    bool
+	}{
+		{BarSpec{Type: BarTypeTime, Size: 10}, "10", true},
+		{BarSpec{Type: BarTypeTick, Size: 100}, "T100", true},
+		{BarSpec{Type: BarTypeVolume, Size: 10000}, "V10000", true},
+		{BarSpec{Type: BarTypeRange, Size: 5}, "R5", true},
+		{BarSpec{Type: BarTypeTick, Size: 0}, "T0", false},
+		{BarSpec{Type: "renko", Size: 5}, "5", false},
+		{BarSpec{Size: 5}, "5", false},
+	}
+	for _, tt := range tests {
+		if got := tt.spec.Interval(); got != tt.interval {
+			t.Errorf("%+v: Interval = %s, ожидали %s", tt.spec, got, tt.interval)
+		}
+		if err := tt.spec.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v: Validate = %v", tt.spec, err)
+		}
+	}
+}
+
+func TestSubscribeBarsUnknownType(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("запрос %s при не верном типе бара", r.URL.Path)
+	})
+	if err := c.SubscribeBars(context.Background(), "SBER", BarSpec{Type: "renko", Size: 5}); err == nil {
+		t.Fatal("ожидали ошибку для неизвестного типа бара")
+	}
+}
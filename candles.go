@@ -2,7 +2,6 @@ package alor
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
 )
 
@@ -12,23 +11,27 @@ import (
 
 // GetHistory Запрос истории для выбранных биржи и инструмента
 // биржу берем по умолчанию
-func (c *Client) GetHistory(ctx context.Context, symbol string, interval Interval, from, to int64) (History, error) {
+// Формат ответа можно выбрать опцией WithFormat
+func (c *Client) GetHistory(ctx context.Context, symbol string, interval Interval, from, to int64, opts ...Option) (History, error) {
+	params := NewOptions(opts...)
 	r := &request{
 		method:   http.MethodGet,
 		endpoint: "/md/v2/history",
+		route:    "/md/v2/history",
 	}
 	r.setParam("exchange", c.Exchange)
 	r.setParam("symbol", symbol)
 	r.setParam("tf", interval)
 	r.setParam("from", from)
 	r.setParam("to", to)
+	params.setFormat(r)
 
 	result := History{}
 	data, err := c.callAPI(ctx, r)
 	if err != nil {
 		return result, err
 	}
-	if err = json.Unmarshal(data, &result); err != nil {
+	if err = decodeFormat[historySlim](data, params.Format, &result); err != nil {
 		return result, err
 	}
 	return result, nil
@@ -37,7 +40,7 @@ func (c *Client) GetHistory(ctx context.Context, symbol string, interval Interva
 
 // GetCandles Запрос свечей для выбранного инструмента
 // биржу берем по умолчанию
-func (c *Client) GetCandles(ctx context.Context, symbol string, interval Interval, from, to int64) ([]Candle, error) {
-	history, err := c.GetHistory(ctx, symbol, interval, from, to)
+func (c *Client) GetCandles(ctx context.Context, symbol string, interval Interval, from, to int64, opts ...Option) ([]Candle, error) {
+	history, err := c.GetHistory(ctx, symbol, interval, from, to, opts...)
 	return history.Candles, err
 }
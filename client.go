@@ -2,15 +2,21 @@ package alor
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -20,16 +26,16 @@ const (
 
 // Endpoints
 const (
-	apiProdURL   = "https://api.alor.ru"      // Боевой контур
-	apiDevURL    = "https://apidev.alor.ru"   // Тестовый контур
-	oauthProdURL = "https://oauth.alor.ru"    // Боевой контур авторизации
-	oauthDevURL  = "https://oauthdev.alor.ru" // Тестовый контур авторизации
-	wssDevURL    = "wss://apidev.alor.ru/ws"  // Тестовый контур wss
-	wssProdURL   = "wss://api.alor.ru/ws"     // Боевой контур wss
+	apiProdURL        = "https://api.alor.ru"      // Боевой контур
+	apiDevURL         = "https://apidev.alor.ru"   // Тестовый контур
+	oauthProdURL      = "https://oauth.alor.ru"    // Боевой контур авторизации
+	oauthDevURL       = "https://oauthdev.alor.ru" // Тестовый контур авторизации
+	wssDevURL         = "wss://apidev.alor.ru/ws"  // Тестовый контур wss
+	wssProdURL        = "wss://api.alor.ru/ws"     // Боевой контур wss
+	wssCommandDevURL  = "wss://apidev.alor.ru/cws" // Тестовый контур wss для торговых команд
+	wssCommandProdURL = "wss://api.alor.ru/cws"    // Боевой контур wss для торговых команд
 )
 
-var ErrNotFound = errors.New("404 Not Found")
-
 var logLevel = &slog.LevelVar{} // INFO
 var log = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 	Level: logLevel,
@@ -39,79 +45,105 @@ func SetLogger(logger *slog.Logger) {
 	log = logger
 }
 
+// debugEnabled включен ли уровень Debug (чтобы не готовить данные для лога зря)
+func debugEnabled() bool {
+	return log.Enabled(context.Background(), slog.LevelDebug)
+}
+
 //const (
 //	ErrNotFound = "404 Not Found"
 //)
 
-// demoServer использовать тестовый или боевой сервер
-var demoServer = false
-
-// getAPIEndpoint return the base endpoint of the Rest API according the UseDevelop flag
-func getAPIEndpoint() string {
-	if demoServer {
-		return apiDevURL
-	}
-	return apiProdURL
-}
-
-func getOauthEndPoint() string {
-	if demoServer {
-		return oauthDevURL
-	}
-	return oauthProdURL
-}
-
-func getWsEndpoint() string {
-	if demoServer {
-		return wssDevURL
-	}
-	return wssProdURL
-}
-
 // NewClient создание нового клиента
-func NewClient(token string) *Client {
+// token = refresh токен пользователя (можно пустой, если источник токена задан через WithTokenSource)
+func NewClient(token string, opts ...ClientOption) *Client {
 	//Log.Info("NewClient")
-	return &Client{
-		refreshToken: token,
+	c := &Client{
 		//Portfolio:    portfolio,
-		Exchange:   "MOEX", // по умолчанию работаем с биржей MOEX
-		HTTPClient: http.DefaultClient,
+		Exchange:    "MOEX", // по умолчанию работаем с биржей MOEX
+		HTTPClient:  http.DefaultClient,
+		environment: Prod, // по умолчанию боевой контур
+		retry:       DefaultRetryPolicy(),
+		limiter:     newRateLimiter(DefaultRateLimits()),
 		//Logger:     log.New(os.Stderr, "go-alor ", log.LstdFlags),
 	}
+	if token != "" {
+		c.tokenSource = NewRefreshTokenSource(token)
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.setDefaults()
+	if b, ok := c.tokenSource.(clientTokenSource); ok {
+		b.bindClient(c)
+	}
+	return c
 }
 
 // Client define API client
 type Client struct {
-	portfolioID     string    // Номер счета по умолчанию (Portfolio)
-	fortsPortfolio  string    // Номер счета для работы с ФОРТС
-	stockPortfolio  string    // Номер счета для работы с фондовым рынком
-	fxPortfolio     string    // Номер счета для работы с валютным рынком
-	refreshToken    string    // Refresh токен пользователя
-	accessToken     string    // JWT токен для дальнейшей авторизации
-	cancelTimeToken time.Time // Время завершения действия JWT токена
-	Exchange        string    // С какой биржей работаем по умолчанию
+	portfolioMu     sync.RWMutex // защита номеров счетов
+	portfolioID     string       // Номер счета по умолчанию (Portfolio)
+	fortsPortfolio  string       // Номер счета для работы с ФОРТС
+	stockPortfolio  string       // Номер счета для работы с фондовым рынком
+	fxPortfolio     string       // Номер счета для работы с валютным рынком
+	tokenSource     TokenSource  // Источник JWT токена
+	accessToken     string       // JWT токен для дальнейшей авторизации
+	cancelTimeToken time.Time    // Время завершения действия JWT токена
+	jwt             jwtState     // обновление JWT токена (защита accessToken и cancelTimeToken)
+	Exchange        string       // С какой биржей работаем по умолчанию
 	HTTPClient      *http.Client
+	environment     Environment       // Контур: боевой или тестовый
+	apiURL          string            // Адрес REST API
+	oauthURL        string            // Адрес сервера авторизации
+	wsURL           string            // Адрес websocket для подписок
+	wsCommandURL    string            // Адрес websocket для торговых команд
+	dialer          *websocket.Dialer // websocket dialer
+	retry           RetryPolicy       // Политика повтора REST запросов
+	limiter         *rateLimiter      // Ограничение частоты REST запросов
+	hooksMu         sync.RWMutex      // защита middleware и wsHooks
+	middleware      []Middleware      // Обертки REST запросов
+	wsHooks         []WsFrameHook     // Обработчики websocket сообщений
+	tracer          trace.Tracer      // Трассировка OpenTelemetry
+	metrics         Metrics           // Метрики клиента
 	Stream
+	secMu      sync.RWMutex              // защита securities
+	securities map[string]cachedSecurity // кэш параметров инструментов (для проверки заявок)
 	//Portfolio       string    // ID портфеля с которым работаем по умолчанию
 }
 
 // SetPortfolioID установим номер счета по умолчанию
 func (c *Client) SetPortfolioID(portfolio string) {
+	c.portfolioMu.Lock()
+	defer c.portfolioMu.Unlock()
 	c.portfolioID = portfolio
 }
 
+// defaultPortfolio вернем номер счета по умолчанию
+func (c *Client) defaultPortfolio() string {
+	c.portfolioMu.RLock()
+	defer c.portfolioMu.RUnlock()
+	return c.portfolioID
+}
+
 // SetFortsPortfolio установим номер счета для работы с рынком фортс
 func (c *Client) SetFortsPortfolio(portfolio string) {
+	c.portfolioMu.Lock()
+	defer c.portfolioMu.Unlock()
 	c.fortsPortfolio = portfolio
 }
 
 // SetFxAPortfolio установим номер счета для работы с валютным рынком
 func (c *Client) SetFxAPortfolio(portfolio string) {
+	c.portfolioMu.Lock()
+	defer c.portfolioMu.Unlock()
 	c.fxPortfolio = portfolio
 }
 
 // SetStockPortfolio установим номер счета для работы с фондовым рынком
 func (c *Client) SetStockPortfolio(portfolio string) {
+	c.portfolioMu.Lock()
+	defer c.portfolioMu.Unlock()
 	c.stockPortfolio = portfolio
 }
 
@@ -125,7 +157,7 @@ func (c *Client) SetLogDebug(debug bool) {
 	}
 
 }
-func (c *Client) parseRequest(r *request, opts ...RequestOption) (err error) {
+func (c *Client) parseRequest(ctx context.Context, r *request, opts ...RequestOption) (err error) {
 	// set request options from user
 	for _, opt := range opts {
 		opt(r)
@@ -146,20 +178,20 @@ func (c *Client) parseRequest(r *request, opts ...RequestOption) (err error) {
 	// если запрос нужно делать с авторизацией (по умолчанию)
 	if !r.notAuthorization {
 		// получим токен авторизации
-		_, err = c.GetJWT()
+		token, err := c.getJWT(ctx)
 		if err != nil {
 			log.Debug("parseRequest GetJWT", "error", err.Error())
 			return err
 		}
-		if c.accessToken != "" {
-			header.Set("Authorization", "Bearer "+c.accessToken)
+		if token != "" {
+			header.Set("Authorization", "Bearer "+token)
 		}
 	}
 
 	baseURL := r.baseURL
 	// если ранее не заполнили базовый путь (может быть заполнен в GetJWT())
 	if baseURL == "" {
-		baseURL = getAPIEndpoint()
+		baseURL = c.apiURL
 	}
 	// TODO переделать url.Parse + path.Join
 	fullURL := fmt.Sprintf("%s%s", baseURL, r.endpoint)
@@ -178,25 +210,38 @@ func (c *Client) parseRequest(r *request, opts ...RequestOption) (err error) {
 	return nil
 }
 
-func (c *Client) callAPI(ctx context.Context, r *request, opts ...RequestOption) (data []byte, err error) {
-	err = c.parseRequest(r, opts...)
+// doAPI одна попытка запроса (повторы в callAPI)
+func (c *Client) doAPI(ctx context.Context, r *request, opts ...RequestOption) (data []byte, err error) {
+	err = c.parseRequest(ctx, r, opts...)
 	if err != nil {
 		return []byte{}, err
 	}
+	waitStart := time.Now()
+	if err = c.waitRateLimit(ctx, r); err != nil {
+		return []byte{}, err
+	}
+	span := trace.SpanFromContext(ctx)
+	if wait := time.Since(waitStart); wait > time.Millisecond {
+		span.AddEvent("rate limit wait", trace.WithAttributes(attribute.Int64("alor.wait_ms", wait.Milliseconds())))
+	}
 	req, err := http.NewRequest(r.method, r.fullURL, r.body)
 	if err != nil {
 		return []byte{}, err
 	}
 	req = req.WithContext(ctx)
 	req.Header = r.header
+	c.injectTraceContext(ctx, req.Header)
 	//c.debug("request: %#v", req)
 	log.Debug("callAPI", slog.Any("request", req))
 
-	res, err := c.HTTPClient.Do(req)
+	start := time.Now()
+	res, err := c.roundTrip(req)
 	if err != nil {
+		c.metrics.ObserveRequest(r.method, r.metricsRoute(), 0, time.Since(start))
 		return []byte{}, err
 	}
 	data, err = io.ReadAll(res.Body)
+	c.metrics.ObserveRequest(r.method, r.metricsRoute(), res.StatusCode, time.Since(start))
 	if err != nil {
 		return []byte{}, err
 	}
@@ -214,26 +259,13 @@ func (c *Client) callAPI(ctx context.Context, r *request, opts ...RequestOption)
 	//c.debug("response status code: %d", res.StatusCode)
 	//Log.Debug("callAPI", "status code", res.StatusCode, slog.Any("body", res.Body))
 	log.Debug("callAPI", "status code", res.StatusCode)
+	span.AddEvent("response", trace.WithAttributes(attribute.Int("http.response.status_code", res.StatusCode)))
 	//c.debug("debug: GET %s -> %d", r.fullURL, res.StatusCode)
 
 	if res.StatusCode >= http.StatusBadRequest {
-		apiErr := new(APIError)
-		apiErr.Status = res.StatusCode
 		//c.debug("Error response body: %s", string(data))
 		log.Error("callAPI", "Error response body", res.StatusCode, slog.Any("data", data))
-		// TODO обработать ошибку StatusNotFound
-		if res.StatusCode == http.StatusNotFound {
-			return []byte{}, ErrNotFound
-		}
-
-		err := json.Unmarshal(data, apiErr)
-		if err != nil {
-			//c.debug("failed to unmarshal json: %s", e)
-			log.Error("callAPI json.Unmarshal", "err", err.Error())
-			apiErr.Code = strconv.Itoa(res.StatusCode)
-			apiErr.Message = http.StatusText(res.StatusCode)
-		}
-		return nil, apiErr
+		return nil, newAPIError(r, res.StatusCode, res.Header, data)
 		//c.debug("Erorr response body: %s", string(data))
 
 	}
@@ -245,23 +277,23 @@ func (c *Client) Version() string {
 	return libraryVersion
 }
 
-// getRequestID Получение уникального кода запроса
-// Текущее время в наносекундах, прошедших с 01.01.1970 в UTC
-func (c *Client) getRequestID() string {
-	return strconv.FormatInt(time.Now().UnixNano(), 10)
-}
-
-type APIError struct {
-	Code        string `json:"code"`
-	Message     string `json:"message"`
-	OrderNumber string `json:"orderNumber"`
-	Status      int    `json:"-"` // статус HTTP ответа
-}
+// счетчик и случайная часть для генерации уникального кода запроса
+var (
+	requestSeq  atomic.Uint64
+	requestSalt = newRequestSalt()
+)
 
-func (e APIError) Error() string {
-	return fmt.Sprintf("<APIError> code=%s, msg=%s", e.Code, e.Message)
+func newRequestSalt() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(int64(os.Getpid()), 16)
+	}
+	return hex.EncodeToString(b)
 }
 
-func (e APIError) HTTPStatus() int {
-	return e.Status
+// getRequestID Получение уникального кода запроса
+// Текущее время в наносекундах + случайная часть процесса + счетчик
+// (не совпадает даже при одновременном вызове из нескольких горутин и процессов)
+func (c *Client) getRequestID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 10) + "-" + requestSalt + "-" + strconv.FormatUint(requestSeq.Add(1), 10)
 }
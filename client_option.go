@@ -0,0 +1,112 @@
+package alor
+
+import (
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// Environment контур Алор: боевой или тестовый
+type Environment string
+
+const (
+	Prod Environment = "prod" // Боевой контур
+	Dev  Environment = "dev"  // Тестовый контур
+)
+
+// ClientOption параметры клиента
+type ClientOption func(c *Client)
+
+// WithTokenSource источник JWT токена (вместо refresh токена)
+func WithTokenSource(source TokenSource) ClientOption {
+	return func(c *Client) {
+		c.tokenSource = source
+	}
+}
+
+// WithEnvironment контур (по умолчанию Prod)
+// адреса, заданные через WithAPIURL, WithOAuthURL, WithWSURL и WithCommandWSURL, имеют приоритет
+func WithEnvironment(env Environment) ClientOption {
+	return func(c *Client) {
+		c.environment = env
+	}
+}
+
+// WithAPIURL адрес REST API (например https://apidev.alor.ru или адрес локальной заглушки)
+func WithAPIURL(url string) ClientOption {
+	return func(c *Client) {
+		c.apiURL = url
+	}
+}
+
+// WithOAuthURL адрес сервера авторизации
+func WithOAuthURL(url string) ClientOption {
+	return func(c *Client) {
+		c.oauthURL = url
+	}
+}
+
+// WithWSURL адрес websocket для подписок
+func WithWSURL(url string) ClientOption {
+	return func(c *Client) {
+		c.wsURL = url
+	}
+}
+
+// WithCommandWSURL адрес websocket для торговых команд
+func WithCommandWSURL(url string) ClientOption {
+	return func(c *Client) {
+		c.wsCommandURL = url
+	}
+}
+
+// WithHTTPClient http клиент для REST запросов
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.HTTPClient = httpClient
+	}
+}
+
+// WithDialer websocket dialer (прокси, TLS, таймауты)
+func WithDialer(dialer *websocket.Dialer) ClientOption {
+	return func(c *Client) {
+		c.dialer = dialer
+	}
+}
+
+// setDefaults заполним не заданные адреса по контуру и параметры по умолчанию
+func (c *Client) setDefaults() {
+	api, oauth, ws, wsCommand := apiProdURL, oauthProdURL, wssProdURL, wssCommandProdURL
+	if c.environment == Dev {
+		api, oauth, ws, wsCommand = apiDevURL, oauthDevURL, wssDevURL, wssCommandDevURL
+	}
+	if c.apiURL == "" {
+		c.apiURL = api
+	}
+	if c.oauthURL == "" {
+		c.oauthURL = oauth
+	}
+	if c.wsURL == "" {
+		c.wsURL = ws
+	}
+	if c.wsCommandURL == "" {
+		c.wsCommandURL = wsCommand
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = http.DefaultClient
+	}
+	if c.dialer == nil {
+		c.dialer = websocket.DefaultDialer
+	}
+	if c.tracer == nil {
+		c.tracer = defaultTracer()
+	}
+	if c.metrics == nil {
+		c.metrics = nopMetrics{}
+	}
+}
+
+// Environment вернем контур клиента
+func (c *Client) Environment() Environment {
+	return c.environment
+}
@@ -0,0 +1,95 @@
+package alor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClientEnvironment(t *testing.T) {
+	tests := []struct {
+		name                      string
+		opts                      []ClientOption
+		env                       Environment
+		api, oauth, ws, wsCommand string
+	}{
+		{name: "по умолчанию боевой контур",
+			env: Prod, api: apiProdURL, oauth: oauthProdURL, ws: wssProdURL, wsCommand: wssCommandProdURL},
+		{name: "тестовый контур", opts: []ClientOption{WithEnvironment(Dev)},
+			env: Dev, api: apiDevURL, oauth: oauthDevURL, ws: wssDevURL, wsCommand: wssCommandDevURL},
+		{name: "адреса важнее контура (порядок опций не важен)",
+			opts: []ClientOption{WithAPIURL("http://api.local"), WithWSURL("ws://api.local/ws"), WithEnvironment(Dev)},
+			env:  Dev, api: "http://api.local", oauth: oauthDevURL, ws: "ws://api.local/ws", wsCommand: wssCommandDevURL},
+		{name: "все адреса", opts: []ClientOption{
+			WithAPIURL("http://api.local"), WithOAuthURL("http://oauth.local"),
+			WithWSURL("ws://api.local/ws"), WithCommandWSURL("ws://api.local/cws")},
+			env: Prod, api: "http://api.local", oauth: "http://oauth.local", ws: "ws://api.local/ws", wsCommand: "ws://api.local/cws"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient("", tt.opts...)
+			if c.Environment() != tt.env {
+				t.Errorf("контур %s, ожидали %s", c.Environment(), tt.env)
+			}
+			got := []string{c.apiURL, c.oauthURL, c.wsURL, c.wsCommandURL}
+			want := []string{tt.api, tt.oauth, tt.ws, tt.wsCommand}
+			if !equalSlices(got, want) {
+				t.Errorf("адреса %v, ожидали %v", got, want)
+			}
+		})
+	}
+}
+
+// TestClientURLPerClient у каждого клиента свои адреса и http клиент
+func TestClientURLPerClient(t *testing.T) {
+	type hit struct {
+		path, auth string
+	}
+	newServer := func(hits chan<- hit) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits <- hit{r.URL.Path, r.Header.Get("Authorization")}
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	hitsA, hitsB := make(chan hit, 1), make(chan hit, 1)
+	srvA, srvB := newServer(hitsA), newServer(hitsB)
+
+	var transported atomic.Int32
+	httpClient := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		transported.Add(1)
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	a := NewClient("", WithAPIURL(srvA.URL), WithTokenSource(StaticTokenSource("token.a.1")),
+		WithRateLimits(NoRateLimits()), WithHTTPClient(httpClient))
+	b := NewClient("", WithAPIURL(srvB.URL), WithTokenSource(StaticTokenSource("token.b.2")),
+		WithRateLimits(NoRateLimits()))
+
+	ctx := context.Background()
+	if _, err := a.GetPortfolio(ctx, "D1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GetPortfolio(ctx, "D2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-hitsA; got != (hit{"/md/v2/Clients/MOEX/D1/summary", "Bearer token.a.1"}) {
+		t.Errorf("клиент a: %+v", got)
+	}
+	if got := <-hitsB; got != (hit{"/md/v2/Clients/MOEX/D2/summary", "Bearer token.b.2"}) {
+		t.Errorf("клиент b: %+v", got)
+	}
+	// WithHTTPClient только у клиента a
+	if n := transported.Load(); n != 1 {
+		t.Errorf("запросов через http клиент a %d, ожидали 1", n)
+	}
+}
+
+// roundTripperFunc функция как http.RoundTripper
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,41 @@
+package alor
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestClient клиент, REST запросы которого обрабатывает handler
+// токен статический, ограничение частоты запросов выключено
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...ClientOption) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	opts = append([]ClientOption{
+		WithAPIURL(srv.URL),
+		WithOAuthURL(srv.URL),
+		WithTokenSource(StaticTokenSource("a.b.c")),
+		WithRateLimits(NoRateLimits()),
+	}, opts...)
+	c := NewClient("", opts...)
+	c.SetPortfolioID("D39004")
+	return c
+}
+
+// testJWT JWT токен с заданными claims (без подписи)
+func testJWT(claims string) string {
+	return "e30." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".sig"
+}
+
+// fastRetry политика повтора без пауз
+func fastRetry(attempts int) RetryPolicy {
+	p := DefaultRetryPolicy()
+	p.MaxAttempts = attempts
+	p.BaseDelay = time.Millisecond
+	p.MaxDelay = time.Millisecond
+	p.Jitter = 0
+	return p
+}
@@ -0,0 +1,149 @@
+package alor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ошибки API. Проверка через errors.Is:
+//
+//	if errors.Is(err, alor.ErrInsufficientFunds) { ... }
+//
+// Подробности (статус, тело ответа, X-ALOR-REQID) через errors.As:
+//
+//	var apiErr *alor.APIError
+//	if errors.As(err, &apiErr) { ... }
+var (
+	ErrUnauthorized      = errors.New("не авторизован")
+	ErrForbidden         = errors.New("доступ запрещен")
+	ErrNotFound          = errors.New("404 Not Found")
+	ErrRateLimited       = errors.New("превышен лимит запросов")
+	ErrValidation        = errors.New("не корректные параметры запроса")
+	ErrInsufficientFunds = errors.New("недостаточно средств")
+	ErrInstrumentHalted  = errors.New("торги по инструменту не ведутся")
+	ErrOrderNotFound     = errors.New("заявка не найдена")
+	ErrServerError       = errors.New("ошибка сервера")
+)
+
+// фрагменты текста ошибки сервера (в нижнем регистре) для уточнения вида ошибки
+var (
+	insufficientFundsText = []string{"недостаточно", "insufficient", "not enough", "notenough"}
+	instrumentHaltedText  = []string{"приостановлен", "не торгуется", "торги не ведутся", "halt", "not traded", "trading is closed", "session is closed"}
+	orderNotFoundText     = []string{"заявка не найдена", "order not found", "ordernotfound", "order does not exist"}
+)
+
+type APIError struct {
+	Code        string        `json:"code"`
+	Message     string        `json:"message"`
+	OrderNumber string        `json:"orderNumber"`
+	Status      int           `json:"-"` // статус HTTP ответа
+	Body        []byte        `json:"-"` // тело ответа
+	RequestID   string        `json:"-"` // X-ALOR-REQID запроса (для websocket guid команды)
+	Method      string        `json:"-"` // метод запроса
+	Endpoint    string        `json:"-"` // адрес запроса (без параметров)
+	retryAfter  time.Duration // пауза перед повтором из заголовка Retry-After
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("<APIError> status=%d, code=%s, msg=%s, endpoint=%s %s", e.Status, e.Code, e.Message, e.Method, e.Endpoint)
+}
+
+func (e *APIError) HTTPStatus() int {
+	return e.Status
+}
+
+// Unwrap вид ошибки (ErrNotFound, ErrInsufficientFunds ...) для errors.Is
+func (e *APIError) Unwrap() []error {
+	return e.kinds()
+}
+
+// kinds определим вид ошибки по статусу, коду и тексту ответа
+func (e *APIError) kinds() []error {
+	text := strings.ToLower(e.Code + " " + e.Message)
+	orderNotFound := containsAny(text, orderNotFoundText)
+	switch {
+	case e.Status == http.StatusUnauthorized:
+		return []error{ErrUnauthorized}
+	case e.Status == http.StatusForbidden:
+		return []error{ErrForbidden}
+	case e.Status == http.StatusNotFound:
+		if orderNotFound || isOrderEndpoint(e.Endpoint) {
+			return []error{ErrNotFound, ErrOrderNotFound}
+		}
+		return []error{ErrNotFound}
+	case e.Status == http.StatusTooManyRequests:
+		return []error{ErrRateLimited}
+	case e.Status >= http.StatusInternalServerError:
+		return []error{ErrServerError}
+	case e.Status >= http.StatusBadRequest:
+		switch {
+		case containsAny(text, insufficientFundsText):
+			return []error{ErrInsufficientFunds}
+		case containsAny(text, instrumentHaltedText):
+			return []error{ErrInstrumentHalted}
+		case orderNotFound:
+			return []error{ErrNotFound, ErrOrderNotFound}
+		}
+		return []error{ErrValidation}
+	}
+	return nil
+}
+
+// isOrderEndpoint запрос по заявке
+func isOrderEndpoint(endpoint string) bool {
+	return strings.Contains(endpoint, "/orders") || strings.Contains(endpoint, "/stoporders")
+}
+
+func containsAny(s string, substrings []string) bool {
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// newAPIError ошибка по ответу сервера
+func newAPIError(r *request, status int, header http.Header, body []byte) *APIError {
+	apiErr := &APIError{
+		Status:     status,
+		Body:       body,
+		Method:     r.method,
+		Endpoint:   r.endpoint,
+		RequestID:  r.header.Get("X-ALOR-REQID"),
+		retryAfter: parseRetryAfter(header.Get("Retry-After")),
+	}
+	// тело может быть не json (или пустым)
+	if err := json.Unmarshal(body, apiErr); err != nil || (apiErr.Code == "" && apiErr.Message == "") {
+		apiErr.Code = strconv.Itoa(status)
+		apiErr.Message = strings.TrimSpace(string(body))
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(status)
+		}
+	}
+	return apiErr
+}
+
+// Err ошибка websocket (nil, если httpCode не ошибка)
+func (m *WsMessage) Err() error {
+	if m.HttpCode < http.StatusBadRequest {
+		return nil
+	}
+	return &APIError{
+		Code:      strconv.Itoa(m.HttpCode),
+		Message:   m.Message,
+		Status:    m.HttpCode,
+		RequestID: m.RequestGuid,
+		Method:    "WS",
+	}
+}
+
+// Is ошибки проверки заявки = ErrValidation
+func (e ValidationErrors) Is(target error) bool {
+	return target == ErrValidation
+}
@@ -0,0 +1,133 @@
+package alor
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// allErrors все виды ошибок API
+var allErrors = []error{
+	ErrUnauthorized, ErrForbidden, ErrNotFound, ErrRateLimited, ErrValidation,
+	ErrInsufficientFunds, ErrInstrumentHalted, ErrOrderNotFound, ErrServerError,
+}
+
+// checkKinds err соответствует видам want (errors.Is) и не соответствует остальным
+func checkKinds(t *testing.T, err error, want ...error) {
+	t.Helper()
+	for _, kind := range allErrors {
+		expected := false
+		for _, w := range want {
+			if w == kind {
+				expected = true
+			}
+		}
+		if got := errors.Is(err, kind); got != expected {
+			t.Errorf("errors.Is(%v, %q) = %v, ожидали %v", err, kind, got, expected)
+		}
+	}
+}
+
+func TestAPIErrorKinds(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		endpoint string
+		body     string
+		want     []error
+	}{
+		{"401", 401, "/md/v2/Clients/MOEX/D39004/summary", ``, []error{ErrUnauthorized}},
+		{"403", 403, "/md/v2/Clients/MOEX/D39004/summary", `{"code":"Forbidden","message":"нет доступа"}`, []error{ErrForbidden}},
+		{"404", 404, "/md/v2/Securities/MOEX/XXXX", ``, []error{ErrNotFound}},
+		{"404 по заявке", 404, "/md/v2/Clients/MOEX/D39004/orders/123", ``, []error{ErrNotFound, ErrOrderNotFound}},
+		{"404 по стоп-заявке", 404, "/md/v2/Clients/MOEX/D39004/stoporders/123", ``, []error{ErrNotFound, ErrOrderNotFound}},
+		{"404 текст заявки", 404, "/commandapi/warptrans/TRADE/v2/client/orders/123", `{"code":"OrderNotFound","message":"Order not found"}`, []error{ErrNotFound, ErrOrderNotFound}},
+		{"429", 429, "/md/v2/time", `Too many requests`, []error{ErrRateLimited}},
+		{"500", 500, "/md/v2/time", ``, []error{ErrServerError}},
+		{"503", 503, "/md/v2/time", `<html>Service Unavailable</html>`, []error{ErrServerError}},
+		{"400", 400, "/commandapi/warptrans/TRADE/v2/client/orders/actions/limit", `{"code":"BadRequest","message":"Price is invalid"}`, []error{ErrValidation}},
+		{"400 недостаточно средств", 400, "/commandapi/warptrans/TRADE/v2/client/orders/actions/limit",
+			`{"code":"OrderRejected","message":"Недостаточно средств для открытия позиции"}`, []error{ErrInsufficientFunds}},
+		{"400 insufficient funds", 400, "/commandapi/warptrans/TRADE/v2/client/orders/actions/limit",
+			`{"code":"NotEnoughMoney","message":"Insufficient funds"}`, []error{ErrInsufficientFunds}},
+		{"400 торги приостановлены", 400, "/commandapi/warptrans/TRADE/v2/client/orders/actions/market",
+			`{"code":"OrderRejected","message":"Торги по инструменту приостановлены"}`, []error{ErrInstrumentHalted}},
+		{"400 session is closed", 400, "/commandapi/warptrans/TRADE/v2/client/orders/actions/market",
+			`{"message":"Trading session is closed"}`, []error{ErrInstrumentHalted}},
+		{"400 заявка не найдена", 400, "/commandapi/warptrans/TRADE/v2/client/orders/123",
+			`{"code":"OrderRejected","message":"Заявка не найдена"}`, []error{ErrNotFound, ErrOrderNotFound}},
+		{"400 текст не json", 400, "/md/v2/history", `bad request`, []error{ErrValidation}},
+		{"302", 302, "/md/v2/time", ``, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &request{method: http.MethodPost, endpoint: tt.endpoint}
+			r.setHeader("X-ALOR-REQID", "req-1")
+			var err error = newAPIError(r, tt.status, http.Header{}, []byte(tt.body))
+			checkKinds(t, err, tt.want...)
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("errors.As(%v, *APIError) = false", err)
+			}
+			if apiErr.Status != tt.status || apiErr.HTTPStatus() != tt.status || apiErr.Endpoint != tt.endpoint ||
+				apiErr.Method != http.MethodPost || apiErr.RequestID != "req-1" || string(apiErr.Body) != tt.body {
+				t.Fatalf("APIError %+v", apiErr)
+			}
+			if apiErr.Message == "" {
+				t.Fatalf("пустой текст ошибки: %+v", apiErr)
+			}
+		})
+	}
+}
+
+func TestNewAPIErrorBody(t *testing.T) {
+	r := &request{method: http.MethodGet, endpoint: "/md/v2/time"}
+	tests := []struct {
+		body        string
+		code, msg   string
+		orderNumber string
+	}{
+		{`{"code":"OrderRejected","message":"Price is invalid","orderNumber":"42"}`, "OrderRejected", "Price is invalid", "42"},
+		{`  plain text  `, "400", "plain text", ""},
+		{``, "400", "Bad Request", ""},
+		{`{}`, "400", "{}", ""},
+	}
+	for _, tt := range tests {
+		e := newAPIError(r, http.StatusBadRequest, http.Header{}, []byte(tt.body))
+		if e.Code != tt.code || e.Message != tt.msg || e.OrderNumber != tt.orderNumber {
+			t.Errorf("%q: code=%q msg=%q orderNumber=%q", tt.body, e.Code, e.Message, e.OrderNumber)
+		}
+	}
+}
+
+func TestWsMessageErr(t *testing.T) {
+	tests := []struct {
+		msg  WsMessage
+		want []error
+	}{
+		{WsMessage{HttpCode: 200, Message: "Handled successfully"}, nil},
+		{WsMessage{HttpCode: 0}, nil},
+		{WsMessage{HttpCode: 401, Message: "Invalid JWT token"}, []error{ErrUnauthorized}},
+		{WsMessage{HttpCode: 429, Message: "Too many requests"}, []error{ErrRateLimited}},
+		{WsMessage{HttpCode: 400, Message: "Недостаточно средств"}, []error{ErrInsufficientFunds}},
+		{WsMessage{HttpCode: 400, Message: "Invalid opcode"}, []error{ErrValidation}},
+		{WsMessage{HttpCode: 404, Message: "Order not found"}, []error{ErrNotFound, ErrOrderNotFound}},
+		{WsMessage{HttpCode: 500, Message: "Internal error"}, []error{ErrServerError}},
+	}
+	for _, tt := range tests {
+		tt.msg.RequestGuid = "guid-1"
+		err := tt.msg.Err()
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("httpCode %d: ошибка %v", tt.msg.HttpCode, err)
+			}
+			continue
+		}
+		checkKinds(t, err, tt.want...)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Status != tt.msg.HttpCode || apiErr.RequestID != "guid-1" || apiErr.Method != "WS" {
+			t.Errorf("httpCode %d: ошибка %#v", tt.msg.HttpCode, err)
+		}
+	}
+}
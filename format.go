@@ -0,0 +1,283 @@
+package alor
+
+import (
+	"encoding/json"
+)
+
+// Format формат возвращаемого сервером JSON
+type Format string
+
+const (
+	FormatSimple Format = "Simple" // Простой формат (по умолчанию)
+	FormatSlim   Format = "Slim"   // Сокращенные названия полей (меньше трафик)
+	FormatHeavy  Format = "Heavy"  // Полный формат с дополнительными полями (change, change_percent, accruedInt и т.д.)
+)
+
+// WithFormat формат ответа сервера (GetQuotes, GetQuote, GetHistory, GetCandles, GetOrderBooks)
+// данные в любом формате разбираются в те же структуры
+func WithFormat(format Format) Option {
+	return func(opts *Options) {
+		opts.Format = format
+	}
+}
+
+// WithWsFormat формат данных подписки websocket
+// данные в любом формате разбираются в те же структуры
+func WithWsFormat(format Format) WSRequestOption {
+	return func(r *WSRequestBase) {
+		r.Format = format
+	}
+}
+
+// slimModel структура с сокращенными названиями полей (формат Slim)
+type slimModel[T any] interface {
+	model() T
+}
+
+// decodeFormat разберем данные в формате format в структуру v
+// Simple и Heavy используют одинаковые названия полей (в Heavy их больше)
+func decodeFormat[S slimModel[T], T any](data []byte, format Format, v *T) error {
+	if format != FormatSlim {
+		return json.Unmarshal(data, v)
+	}
+	var s S
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*v = s.model()
+	return nil
+}
+
+// decodeFormatSlice разберем массив данных в формате format
+func decodeFormatSlice[S slimModel[T], T any](data []byte, format Format) ([]T, error) {
+	result := make([]T, 0)
+	if format != FormatSlim {
+		err := json.Unmarshal(data, &result)
+		return result, err
+	}
+	slim := make([]S, 0)
+	if err := json.Unmarshal(data, &slim); err != nil {
+		return result, err
+	}
+	result = make([]T, 0, len(slim))
+	for _, s := range slim {
+		result = append(result, s.model())
+	}
+	return result, nil
+}
+
+// quoteSlim котировка в формате Slim
+type quoteSlim struct {
+	Symbol               string  `json:"sym"`
+	Exchanges            string  `json:"ex"`
+	Description          string  `json:"desc"`
+	PrevClosePrice       float64 `json:"pcp"`
+	LastPrice            float64 `json:"c"`
+	OpenPrice            float64 `json:"o"`
+	HighPrice            float64 `json:"h"`
+	LowPrice             float64 `json:"l"`
+	Ask                  float64 `json:"ak"`
+	Bid                  float64 `json:"bd"`
+	AskVol               float32 `json:"akv"`
+	BidVol               float32 `json:"bdv"`
+	AskVolumeTotal       int32   `json:"tak"`
+	BidVolumeTotal       int32   `json:"tbd"`
+	LastPriceTimestamp   int64   `json:"lpt"`
+	LotSize              float64 `json:"ls"`
+	LotValue             float64 `json:"lv"`
+	FaceValue            float64 `json:"fv"`
+	OpenInterest         int64   `json:"oi"`
+	OrderBookMSTimestamp int64   `json:"obts"`
+	Type                 string  `json:"t"`
+	Change               float64 `json:"ch"`
+	ChangePercent        float64 `json:"chp"`
+	AccruedInt           float64 `json:"ai"`
+}
+
+func (s quoteSlim) model() Quote {
+	return Quote(s)
+}
+
+// candleSlim свеча в формате Slim
+type candleSlim struct {
+	Time   int64   `json:"t"`
+	Close  float64 `json:"c"`
+	Open   float64 `json:"o"`
+	High   float64 `json:"h"`
+	Low    float64 `json:"l"`
+	Volume int32   `json:"v"`
+}
+
+func (s candleSlim) model() Candle {
+	return Candle{
+		Time:   s.Time,
+		Close:  s.Close,
+		Open:   s.Open,
+		High:   s.High,
+		Low:    s.Low,
+		Volume: s.Volume,
+	}
+}
+
+// historySlim история свечей в формате Slim
+type historySlim struct {
+	Candles []candleSlim `json:"history"`
+	Next    int64        `json:"next"`
+	Prev    int64        `json:"prev"`
+}
+
+func (s historySlim) model() History {
+	h := History{
+		Candles: make([]Candle, 0, len(s.Candles)),
+		Next:    s.Next,
+		Prev:    s.Prev,
+	}
+	for _, c := range s.Candles {
+		h.Candles = append(h.Candles, c.model())
+	}
+	return h
+}
+
+// priceVolumeSlim строка стакана в формате Slim
+type priceVolumeSlim struct {
+	Price  float64 `json:"p"`
+	Volume int64   `json:"v"`
+}
+
+// orderBookSlim биржевой стакан в формате Slim
+type orderBookSlim struct {
+	Bids        []priceVolumeSlim `json:"b"`
+	Asks        []priceVolumeSlim `json:"a"`
+	MsTimestamp int64             `json:"t"`
+	Existing    bool              `json:"h"`
+}
+
+func (s orderBookSlim) model() OrderBook {
+	b := OrderBook{
+		Bids:        make(PriceVolumeSlice, 0, len(s.Bids)),
+		Asks:        make(PriceVolumeSlice, 0, len(s.Asks)),
+		MsTimestamp: s.MsTimestamp,
+		Existing:    s.Existing,
+	}
+	for _, pv := range s.Bids {
+		b.Bids = append(b.Bids, PriceVolume(pv))
+	}
+	for _, pv := range s.Asks {
+		b.Asks = append(b.Asks, PriceVolume(pv))
+	}
+	return b
+}
+
+// allTradeSlim сделка ленты всех сделок в формате Slim
+type allTradeSlim struct {
+	ID           int64    `json:"id"`
+	OrderNo      int64    `json:"ono"`
+	Symbol       string   `json:"sym"`
+	Board        string   `json:"b"`
+	Qty          int32    `json:"q"`
+	Price        float64  `json:"px"`
+	Timestamp    int64    `json:"t"`
+	Side         SideType `json:"s"`
+	OpenInterest int64    `json:"oi"`
+	Existing     bool     `json:"h"`
+}
+
+func (s allTradeSlim) model() AllTrade {
+	return AllTrade(s)
+}
+
+// orderSlim заявка в формате Slim
+type orderSlim struct {
+	ID             string      `json:"id"`
+	Symbol         string      `json:"sym"`
+	BrokerSymbol   string      `json:"tic"`
+	Exchange       string      `json:"ex"`
+	Portfolio      string      `json:"p"`
+	Comment        string      `json:"cmt"`
+	Type           OrderType   `json:"t"`
+	Side           SideType    `json:"s"`
+	Status         OrderStatus `json:"st"`
+	TransitionTime Time        `json:"tt"`
+	UpdateTime     Time        `json:"ut"`
+	EndTime        Time        `json:"et"`
+	QtyUnits       int32       `json:"qu"`
+	QtyBatch       int32       `json:"qb"`
+	Qty            int32       `json:"q"`
+	FilledQtyUnits int32       `json:"fqu"`
+	FilledQtyBatch int32       `json:"fqb"`
+	Filled         int32       `json:"fq"`
+	Price          float64     `json:"px"`
+	Existing       bool        `json:"h"`
+	TimeInForce    TimeInForce `json:"tf"`
+	Volume         float64     `json:"v"`
+}
+
+func (s orderSlim) model() Order {
+	return Order{
+		ID:             s.ID,
+		Symbol:         s.Symbol,
+		BrokerSymbol:   s.BrokerSymbol,
+		Exchange:       s.Exchange,
+		Portfolio:      s.Portfolio,
+		Comment:        s.Comment,
+		Type:           s.Type,
+		Side:           s.Side,
+		Status:         s.Status,
+		TransitionTime: s.TransitionTime,
+		UpdateTime:     s.UpdateTime,
+		EndTime:        s.EndTime,
+		QtyUnits:       s.QtyUnits,
+		QtyBatch:       s.QtyBatch,
+		Qty:            s.Qty,
+		FilledQtyUnits: s.FilledQtyUnits,
+		FilledQtyBatch: s.FilledQtyBatch,
+		Filled:         s.Filled,
+		Price:          s.Price,
+		Existing:       s.Existing,
+		TimeInForce:    s.TimeInForce,
+		Volume:         s.Volume,
+	}
+}
+
+// tradeSlim сделка по портфелю в формате Slim
+type tradeSlim struct {
+	Id           string  `json:"id"`
+	OrderNo      string  `json:"ono"`
+	Comment      string  `json:"cmt"`
+	Symbol       string  `json:"sym"`
+	BrokerSymbol string  `json:"tic"`
+	Exchange     string  `json:"ex"`
+	Date         Time    `json:"d"`
+	Board        string  `json:"b"`
+	QtyUnits     int32   `json:"qu"`
+	QtyBatch     int     `json:"qb"`
+	Qty          int     `json:"q"`
+	Price        float64 `json:"px"`
+	AccruedInt   int     `json:"ai"`
+	Side         string  `json:"s"`
+	Existing     bool    `json:"h"`
+	Commission   float64 `json:"c"`
+	Volume       float64 `json:"v"`
+}
+
+func (s tradeSlim) model() Trade {
+	return Trade{
+		Id:           s.Id,
+		OrderNo:      s.OrderNo,
+		Comment:      s.Comment,
+		Symbol:       s.Symbol,
+		BrokerSymbol: s.BrokerSymbol,
+		Exchange:     s.Exchange,
+		Date:         s.Date,
+		Board:        s.Board,
+		QtyUnits:     s.QtyUnits,
+		QtyBatch:     s.QtyBatch,
+		Qty:          s.Qty,
+		Price:        s.Price,
+		AccruedInt:   s.AccruedInt,
+		Side:         s.Side,
+		Existing:     s.Existing,
+		Commission:   s.Commission,
+		Volume:       s.Volume,
+	}
+}
@@ -0,0 +1,361 @@
+package alor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics приемник метрик клиента
+// Можно подключить свою реализацию (например поверх prometheus.Client или OpenTelemetry metric)
+// или встроенный MetricsRegistry. Методы вызываются из разных горутин
+type Metrics interface {
+	// ObserveRequest REST запрос: endpoint шаблон адреса (например /md/v2/Clients/{exchange}/{portfolio}/orders),
+	// status = 0 при сетевой ошибке
+	ObserveRequest(method, endpoint string, status int, duration time.Duration)
+	// TokenRefresh получение access токена (err != nil при ошибке)
+	TokenRefresh(err error)
+	// WsConnection изменение кол-ва открытых websocket соединений (+1 / -1). opcode = "command" для соединения команд
+	WsConnection(opcode string, delta int)
+	// WsReconnect переподключение websocket
+	WsReconnect(opcode string)
+	// WsMessage входящее websocket сообщение
+	WsMessage(opcode string)
+	// WsDecodeError ошибка разбора websocket сообщения
+	WsDecodeError(opcode string)
+	// ObserveCallback время работы пользовательских функций обработки (OnQuote, OnOrder и т.д.)
+	ObserveCallback(opcode string, duration time.Duration)
+	// OrderResult результат операции с заявкой (operation: CreateOrder, ModifyOrder, ws.order и т.д.)
+	OrderResult(operation string, err error)
+}
+
+// WithMetrics подключим метрики (по умолчанию метрики не собираются)
+func WithMetrics(metrics Metrics) ClientOption {
+	return func(c *Client) {
+		c.metrics = metrics
+	}
+}
+
+// nopMetrics метрики выключены
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveRequest(string, string, int, time.Duration) {}
+func (nopMetrics) TokenRefresh(error)                                {}
+func (nopMetrics) WsConnection(string, int)                          {}
+func (nopMetrics) WsReconnect(string)                                {}
+func (nopMetrics) WsMessage(string)                                  {}
+func (nopMetrics) WsDecodeError(string)                              {}
+func (nopMetrics) ObserveCallback(string, time.Duration)             {}
+func (nopMetrics) OrderResult(string, error)                         {}
+
+// результат операции с заявкой
+const (
+	OrderOutcomeOK       = "ok"       // Заявка принята
+	OrderOutcomeRejected = "rejected" // Отклонена сервером или не прошла проверку
+	OrderOutcomeError    = "error"    // Сетевая ошибка, отмена и т.д. (результат неизвестен)
+)
+
+// OrderOutcome классифицируем результат операции с заявкой
+func OrderOutcome(err error) string {
+	var apiErr *APIError
+	switch {
+	case err == nil:
+		return OrderOutcomeOK
+	case errors.As(err, &apiErr), errors.Is(err, ErrValidation):
+		return OrderOutcomeRejected
+	default:
+		return OrderOutcomeError
+	}
+}
+
+// metricsRoute шаблон адреса запроса для метки endpoint
+// шаблон задается при создании запроса; если его нет, адрес обезличивается через MetricsEndpoint
+func (r *request) metricsRoute() string {
+	if r.route != "" {
+		return r.route
+	}
+	return MetricsEndpoint(r.endpoint)
+}
+
+// MetricsEndpoint адрес запроса без параметров (для метки endpoint запросов без шаблона)
+// биржа и следующий за ней код (тикер, портфель) заменяются на {exchange} и {code},
+// номера (заявок и т.д.) на {id}, логин пользователя на {login}
+func MetricsEndpoint(endpoint string) string {
+	if n := strings.IndexByte(endpoint, '?'); n >= 0 {
+		endpoint = endpoint[:n]
+	}
+	parts := strings.Split(endpoint, "/")
+	for n := 0; n < len(parts); n++ {
+		switch {
+		case parts[n] == "MOEX" || parts[n] == "SPBX":
+			parts[n] = "{exchange}"
+			if n+1 < len(parts) {
+				n++
+				parts[n] = "{code}"
+			}
+		case parts[n] == "users" && n+1 < len(parts):
+			n++
+			parts[n] = "{login}"
+		case isDigits(parts[n]):
+			parts[n] = "{id}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// границы корзин гистограмм (секунды)
+var (
+	requestBuckets  = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	callbackBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+)
+
+// MetricsRegistry встроенный сборщик метрик с выдачей в текстовом формате Prometheus
+//
+//	metrics := alor.NewMetricsRegistry()
+//	client := alor.NewClient(refreshToken, alor.WithMetrics(metrics))
+//	http.Handle("/metrics", metrics.Handler())
+type MetricsRegistry struct {
+	requestDuration  *metricFamily
+	tokenRefresh     *metricFamily
+	tokenErrors      *metricFamily
+	wsConnections    *metricFamily
+	wsReconnects     *metricFamily
+	wsMessages       *metricFamily
+	wsDecodeErrors   *metricFamily
+	callbackDuration *metricFamily
+	orders           *metricFamily
+}
+
+// NewMetricsRegistry создать сборщик метрик
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{
+		requestDuration:  newMetricFamily("alor_request_duration_seconds", "Время выполнения REST запросов", metricHistogram, requestBuckets, "method", "endpoint", "status"),
+		tokenRefresh:     newMetricFamily("alor_token_refresh_total", "Получение access токена", metricCounter, nil),
+		tokenErrors:      newMetricFamily("alor_token_refresh_errors_total", "Ошибки получения access токена", metricCounter, nil),
+		wsConnections:    newMetricFamily("alor_ws_connections", "Открытые websocket соединения", metricGauge, nil, "opcode"),
+		wsReconnects:     newMetricFamily("alor_ws_reconnects_total", "Переподключения websocket", metricCounter, nil, "opcode"),
+		wsMessages:       newMetricFamily("alor_ws_messages_total", "Входящие websocket сообщения", metricCounter, nil, "opcode"),
+		wsDecodeErrors:   newMetricFamily("alor_ws_decode_errors_total", "Ошибки разбора websocket сообщений", metricCounter, nil, "opcode"),
+		callbackDuration: newMetricFamily("alor_callback_duration_seconds", "Время работы функций обработки данных", metricHistogram, callbackBuckets, "opcode"),
+		orders:           newMetricFamily("alor_orders_total", "Операции с заявками", metricCounter, nil, "operation", "outcome"),
+	}
+}
+
+func (m *MetricsRegistry) ObserveRequest(method, endpoint string, status int, duration time.Duration) {
+	code := "error"
+	if status != 0 {
+		code = strconv.Itoa(status)
+	}
+	m.requestDuration.observe(duration.Seconds(), method, endpoint, code)
+}
+
+func (m *MetricsRegistry) TokenRefresh(err error) {
+	m.tokenRefresh.add(1)
+	if err != nil {
+		m.tokenErrors.add(1)
+	}
+}
+
+func (m *MetricsRegistry) WsConnection(opcode string, delta int) {
+	m.wsConnections.add(float64(delta), opcode)
+}
+
+func (m *MetricsRegistry) WsReconnect(opcode string) {
+	m.wsReconnects.add(1, opcode)
+}
+
+func (m *MetricsRegistry) WsMessage(opcode string) {
+	m.wsMessages.add(1, opcode)
+}
+
+func (m *MetricsRegistry) WsDecodeError(opcode string) {
+	m.wsDecodeErrors.add(1, opcode)
+}
+
+func (m *MetricsRegistry) ObserveCallback(opcode string, duration time.Duration) {
+	m.callbackDuration.observe(duration.Seconds(), opcode)
+}
+
+func (m *MetricsRegistry) OrderResult(operation string, err error) {
+	m.orders.add(1, operation, OrderOutcome(err))
+}
+
+// WritePrometheus запишем метрики в текстовом формате Prometheus
+func (m *MetricsRegistry) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range []*metricFamily{
+		m.requestDuration,
+		m.tokenRefresh,
+		m.tokenErrors,
+		m.wsConnections,
+		m.wsReconnects,
+		m.wsMessages,
+		m.wsDecodeErrors,
+		m.callbackDuration,
+		m.orders,
+	} {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler http обработчик для Prometheus (GET /metrics)
+func (m *MetricsRegistry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := m.WritePrometheus(w); err != nil {
+			log.Error("MetricsRegistry.Handler", "err", err.Error())
+		}
+	})
+}
+
+// тип метрики
+type metricKind string
+
+const (
+	metricCounter   metricKind = "counter"
+	metricGauge     metricKind = "gauge"
+	metricHistogram metricKind = "histogram"
+)
+
+// metricFamily метрика со всеми наборами значений меток
+type metricFamily struct {
+	name    string
+	help    string
+	kind    metricKind
+	buckets []float64
+	labels  []string
+	mu      sync.Mutex
+	series  map[string]*metricSeries // ключ = значения меток через \xff
+}
+
+// metricSeries значение метрики для одного набора меток
+type metricSeries struct {
+	labels  []string
+	value   float64  // counter, gauge
+	counts  []uint64 // histogram: накопленные кол-ва по корзинам
+	sum     float64
+	counter uint64
+}
+
+func newMetricFamily(name, help string, kind metricKind, buckets []float64, labels ...string) *metricFamily {
+	return &metricFamily{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		buckets: buckets,
+		labels:  labels,
+		series:  make(map[string]*metricSeries),
+	}
+}
+
+// get вернем (создадим) набор по значениям меток. Вызывать под блокировкой f.mu
+func (f *metricFamily) get(values []string) *metricSeries {
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &metricSeries{labels: values}
+		if f.kind == metricHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *metricFamily) add(v float64, values ...string) {
+	f.mu.Lock()
+	f.get(values).value += v
+	f.mu.Unlock()
+}
+
+func (f *metricFamily) observe(v float64, values ...string) {
+	f.mu.Lock()
+	s := f.get(values)
+	for n, le := range f.buckets {
+		if v <= le {
+			s.counts[n]++
+		}
+	}
+	s.sum += v
+	s.counter++
+	f.mu.Unlock()
+}
+
+func (f *metricFamily) write(w *bufio.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+	// счетчики без меток показываем сразу (со значением 0)
+	if len(f.labels) == 0 {
+		f.get(nil)
+	}
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		labels := f.formatLabels(s.labels)
+		if f.kind != metricHistogram {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, wrapLabels(labels), formatFloat(s.value))
+			continue
+		}
+		for n, le := range f.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, wrapLabels(joinLabels(labels, `le="`+formatFloat(le)+`"`)), s.counts[n])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, wrapLabels(joinLabels(labels, `le="+Inf"`)), s.counter)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, wrapLabels(labels), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, wrapLabels(labels), s.counter)
+	}
+}
+
+// formatLabels метки в формате name="value",...
+func (f *metricFamily) formatLabels(values []string) string {
+	pairs := make([]string, 0, len(f.labels))
+	for n, name := range f.labels {
+		pairs = append(pairs, name+`="`+labelEscaper.Replace(values[n])+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func joinLabels(labels, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+func wrapLabels(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,131 @@
+package alor
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// routeMetrics запоминает метки endpoint REST запросов
+type routeMetrics struct {
+	nopMetrics
+	mu     sync.Mutex
+	routes []string
+}
+
+func (m *routeMetrics) ObserveRequest(method, endpoint string, status int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routes = append(m.routes, method+" "+endpoint)
+}
+
+func (m *routeMetrics) take() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	routes := m.routes
+	m.routes = nil
+	return routes
+}
+
+func TestMetricsRoutes(t *testing.T) {
+	m := &routeMetrics{}
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	},
+		WithMetrics(m),
+		WithRetryPolicy(NoRetry()),
+		WithTokenSource(StaticTokenSource(testJWT(`{"sub":"P039004"}`))),
+	)
+	ctx := context.Background()
+	const (
+		clients = "/md/v2/Clients/{exchange}/{portfolio}"
+		trade   = "/commandapi/warptrans/TRADE/v2/client/orders"
+	)
+
+	tests := []struct {
+		name  string
+		call  func()
+		route string
+	}{
+		{"GetPortfolio", func() { _, _ = c.GetPortfolio(ctx, "D39004") }, "GET " + clients + "/summary"},
+		{"GetPortfolioRisk", func() { _, _ = c.GetPortfolioRisk(ctx, "D39004") }, "GET " + clients + "/risk"},
+		{"GetPortfolioFortsRisk", func() { _, _ = c.GetPortfolioFortsRisk(ctx, "7500PST") }, "GET " + clients + "/fortsrisk"},
+		{"GetPositions", func() { _, _ = c.GetPositions(ctx, "D39004") }, "GET " + clients + "/positions"},
+		{"GetPosition", func() { _, _, _ = c.GetPosition(ctx, "D39004", "SBER") }, "GET " + clients + "/positions/{symbol}"},
+		{"GetLoginPositions", func() { _, _ = c.GetLoginPositions(ctx, "P039004") }, "GET /md/v2/Clients/{login}/positions"},
+		{"GetOrders", func() { _, _ = c.GetOrders(ctx, "D39004") }, "GET " + clients + "/orders"},
+		{"GetOrder", func() { _, _ = c.GetOrder(ctx, "D39004", "18995978560") }, "GET " + clients + "/orders/{orderId}"},
+		{"GetStopOrders", func() { _, _ = c.GetStopOrders(ctx, "D39004") }, "GET " + clients + "/stoporders"},
+		{"GetStopOrder", func() { _, _ = c.GetStopOrder(ctx, "D39004", "18995978560") }, "GET " + clients + "/stoporders/{orderId}"},
+		{"GetTime", func() { _, _ = c.GetTime(ctx) }, "GET /md/v2/time"},
+		{"GetHistory", func() { _, _ = c.GetHistory(ctx, "SBER", Interval_D1, 0, 1) }, "GET /md/v2/history"},
+		{"GetCandles", func() { _, _ = c.GetCandles(ctx, "SBER", Interval_D1, 0, 1) }, "GET /md/v2/history"},
+		{"GetOrderBooks", func() { _, _ = c.GetOrderBooks(ctx, "SBER") }, "GET /md/v2/orderbooks/{exchange}/{symbol}"},
+		{"GetQuotes", func() { _, _ = c.GetQuotes(ctx, "MOEX:SBER,MOEX:GAZP") }, "GET /md/v2/Securities/{symbols}/quotes"},
+		{"GetQuote", func() { _, _ = c.GetQuote(ctx, "SBER") }, "GET /md/v2/Securities/{symbols}/quotes"},
+		{"GetSecurity", func() { _, _, _ = c.GetSecurity(ctx, "", "SBER") }, "GET /md/v2/Securities/{exchange}/{symbol}"},
+		{"GetSecurities", func() { _, _ = c.GetSecurities(ctx) }, "GET /md/v2/Securities"},
+		{"ListPortfolios", func() { _, _ = c.ListPortfolios(ctx) }, "GET /client/v1.0/users/{login}/portfolios"},
+		{"CancelOrder", func() { _, _ = c.CancelOrder(ctx, "D39004", "18995978560") }, "DELETE " + trade + "/{orderId}"},
+		{"CancelStopOrder", func() { _, _ = c.CancelStopOrder(ctx, "D39004", "18995978560") }, "DELETE " + trade + "/{orderId}"},
+		{"CreateOrder market", func() {
+			_, _ = c.NewCreateOrderService().Symbol("SBER").Side(SideTypeBuy).OrderType(OrderTypeMarket).Qty(1).Do(ctx)
+		}, "POST " + trade + "/actions/market"},
+		{"CreateOrder limit", func() {
+			_, _ = c.NewCreateOrderService().Symbol("SBER").Side(SideTypeBuy).OrderType(OrderTypeLimit).Qty(1).Price(250).Do(ctx)
+		}, "POST " + trade + "/actions/limit"},
+		{"CreateOrderStop stop", func() {
+			_, _ = c.NewCreateOrderStopService().Symbol("SBER").Side(SideTypeSell).OrderType(OrderTypeStop).Qty(1).TriggerPrice(240).Do(ctx)
+		}, "POST " + trade + "/actions/stop"},
+		{"CreateOrderStop stopLimit", func() {
+			_, _ = c.NewCreateOrderStopService().Symbol("SBER").Side(SideTypeSell).OrderType(OrderTypeStopLimit).Qty(1).Price(240).TriggerPrice(240).Do(ctx)
+		}, "POST " + trade + "/actions/stopLimit"},
+		{"ModifyOrder", func() {
+			_, _ = c.NewModifyOrderService("18995978560").Symbol("SBER").Side(SideTypeBuy).OrderType(OrderTypeLimit).Qty(1).Price(250).Do(ctx)
+		}, "PUT " + trade + "/actions/limit/{orderId}"},
+		{"Estimate", func() {
+			_, _ = c.NewCreateOrderService().Symbol("SBER").Side(SideTypeBuy).OrderType(OrderTypeLimit).Qty(1).Price(250).Estimate(ctx)
+		}, "POST /commandapi/warptrans/FX1/v2/client/orders/estimate"},
+		{"GetOrderGroups", func() { _, _ = c.GetOrderGroups(ctx) }, "GET /commandapi/api/orderGroups"},
+		{"GetOrderGroup", func() { _, _ = c.GetOrderGroup(ctx, "0fd6e6d3-ec8a-4d9a-bb07-4e1e0e19e0f3") }, "GET /commandapi/api/orderGroups/{groupId}"},
+		{"UpdateOrderGroup", func() {
+			_, _ = c.UpdateOrderGroup(ctx, "0fd6e6d3-ec8a-4d9a-bb07-4e1e0e19e0f3", ExecutionPolicyIgnoreCancel, nil)
+		}, "PUT /commandapi/api/orderGroups/{groupId}"},
+		{"CancelOrderGroup", func() { _, _ = c.CancelOrderGroup(ctx, "0fd6e6d3-ec8a-4d9a-bb07-4e1e0e19e0f3") }, "DELETE /commandapi/api/orderGroups/{groupId}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.call()
+			routes := m.take()
+			if len(routes) == 0 {
+				t.Fatal("запрос не выполнен")
+			}
+			for _, got := range routes {
+				if got != tt.route {
+					t.Errorf("endpoint = %q, ожидали %q", got, tt.route)
+				}
+				for _, leak := range []string{"SBER", "GAZP", "D39004", "P039004", "18995978560", "MOEX"} {
+					if strings.Contains(got, leak) {
+						t.Errorf("в метке endpoint %q есть %s", got, leak)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMetricsRouteRefreshToken(t *testing.T) {
+	m := &routeMetrics{}
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"AccessToken":"a.b.c"}`))
+	}, WithMetrics(m))
+	src := NewRefreshTokenSource("refresh")
+	src.bindClient(c)
+	_, _ = src.Token(context.Background())
+	if routes := m.take(); len(routes) != 1 || routes[0] != "POST /refresh" {
+		t.Fatalf("endpoint = %v, ожидали [POST /refresh]", routes)
+	}
+}
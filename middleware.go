@@ -0,0 +1,102 @@
+package alor
+
+import (
+	"net/http"
+)
+
+// RoundTripFunc выполнение HTTP запроса
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware обертка выполнения REST запроса (логирование, аудит, метрики, заголовки)
+// Вызывается после подготовки запроса (адрес, заголовки, авторизация) вокруг HTTPClient.Do:
+// может изменить запрос до вызова next и ответ после него
+//
+//	func(next alor.RoundTripFunc) alor.RoundTripFunc {
+//		return func(req *http.Request) (*http.Response, error) {
+//			req.Header.Set("X-Trace", "1")
+//			return next(req)
+//		}
+//	}
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// WsFrameDirection направление websocket сообщения
+type WsFrameDirection string
+
+const (
+	WsFrameOutgoing WsFrameDirection = "out" // Сообщение серверу
+	WsFrameIncoming WsFrameDirection = "in"  // Сообщение от сервера
+)
+
+// WsFrame websocket сообщение
+// Исходящие сообщения содержат токен авторизации.
+// Буфер входящих сообщений подписок используется повторно: сохранять Data после возврата из обработчика можно только копией
+type WsFrame struct {
+	Direction WsFrameDirection // Направление
+	Guid      string           // guid подписки или команды (для входящих сообщений торговых команд пустой)
+	Command   bool             // Соединение для торговых команд (CommandConnection)
+	Data      []byte           // Сообщение. Можно изменить
+}
+
+// WsFrameHook обработчик websocket сообщений
+type WsFrameHook func(frame *WsFrame)
+
+// WithMiddleware добавим обертки REST запросов (первая добавленная - внешняя)
+func WithMiddleware(middleware ...Middleware) ClientOption {
+	return func(c *Client) {
+		c.Use(middleware...)
+	}
+}
+
+// WithWsFrameHook добавим обработчики websocket сообщений
+func WithWsFrameHook(hooks ...WsFrameHook) ClientOption {
+	return func(c *Client) {
+		c.UseWsFrameHook(hooks...)
+	}
+}
+
+// Use добавим обертки REST запросов (первая добавленная - внешняя)
+func (c *Client) Use(middleware ...Middleware) {
+	c.hooksMu.Lock()
+	defer c.hooksMu.Unlock()
+	c.middleware = append(c.middleware, middleware...)
+}
+
+// UseWsFrameHook добавим обработчики websocket сообщений (вызываются в порядке добавления)
+func (c *Client) UseWsFrameHook(hooks ...WsFrameHook) {
+	c.hooksMu.Lock()
+	defer c.hooksMu.Unlock()
+	c.wsHooks = append(c.wsHooks, hooks...)
+}
+
+// roundTrip выполним запрос через цепочку оберток
+func (c *Client) roundTrip(req *http.Request) (*http.Response, error) {
+	c.hooksMu.RLock()
+	middleware := c.middleware
+	c.hooksMu.RUnlock()
+
+	next := RoundTripFunc(c.HTTPClient.Do)
+	for n := len(middleware) - 1; n >= 0; n-- {
+		next = middleware[n](next)
+	}
+	return next(req)
+}
+
+// wsFrame пропустим websocket сообщение через обработчики
+func (c *Client) wsFrame(direction WsFrameDirection, guid string, command bool, data []byte) []byte {
+	c.hooksMu.RLock()
+	hooks := c.wsHooks
+	c.hooksMu.RUnlock()
+	if len(hooks) == 0 {
+		return data
+	}
+	frame := &WsFrame{
+		Direction: direction,
+		Guid:      guid,
+		Command:   command,
+		Data:      data,
+	}
+	for _, h := range hooks {
+		h(frame)
+	}
+	return frame.Data
+}
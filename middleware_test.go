@@ -0,0 +1,163 @@
+package alor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordMiddleware запишет вызовы обертки name до и после next
+func recordMiddleware(calls *[]string, name string) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			*calls = append(*calls, name+" before")
+			req.Header.Add("X-Middleware", name)
+			res, err := next(req)
+			*calls = append(*calls, name+" after")
+			return res, err
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var (
+		calls  []string
+		header http.Header
+		url    string
+	)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "server")
+		header, url = r.Header.Clone(), r.URL.String()
+		_, _ = w.Write([]byte(`{"message":"success","orderNumber":"1"}`))
+	}, WithMiddleware(recordMiddleware(&calls, "a"), recordMiddleware(&calls, "b")))
+	c.Use(recordMiddleware(&calls, "c"))
+
+	// запрос уже подготовлен: адрес, авторизация, ключ идемпотентности
+	var seen http.Header
+	c.Use(func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			seen = req.Header.Clone()
+			return next(req)
+		}
+	})
+	if _, err := c.NewCreateOrderService().Symbol("SBER").Side(SideTypeBuy).Qty(1).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a before", "b before", "c before", "server", "c after", "b after", "a after"}
+	if !equalSlices(calls, want) {
+		t.Fatalf("порядок вызовов %v, ожидали %v", calls, want)
+	}
+	if url != "/commandapi/warptrans/TRADE/v2/client/orders/actions/market" {
+		t.Errorf("адрес %s", url)
+	}
+	if got := header.Values("X-Middleware"); !equalSlices(got, []string{"a", "b", "c"}) {
+		t.Errorf("заголовки оберток %v", got)
+	}
+	if seen.Get("Authorization") != "Bearer a.b.c" || seen.Get("X-ALOR-REQID") == "" || seen.Get("Content-Type") != "application/json" {
+		t.Errorf("обертка получила не подготовленный запрос: %v", seen)
+	}
+	if seen.Get("X-ALOR-REQID") != header.Get("X-ALOR-REQID") {
+		t.Errorf("X-ALOR-REQID изменился: %s %s", seen.Get("X-ALOR-REQID"), header.Get("X-ALOR-REQID"))
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	errBlocked := errors.New("запрос заблокирован")
+	tests := []struct {
+		name    string
+		respond func(req *http.Request) (*http.Response, error)
+		want    string
+		wantErr error
+	}{
+		{name: "ответ без запроса к серверу", respond: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(`1718000000`)),
+				Request:    req,
+			}, nil
+		}, want: "1718000000"},
+		{name: "ошибка без запроса к серверу", respond: func(req *http.Request) (*http.Response, error) {
+			return nil, errBlocked
+		}, wantErr: errBlocked},
+		{name: "ответ с ошибкой", respond: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(`{"message":"not found"}`)),
+				Request:    req,
+			}, nil
+		}, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				t.Errorf("запрос дошел до сервера: %s", r.URL.Path)
+			}, WithRetryPolicy(NoRetry()), WithMiddleware(
+				recordMiddleware(&calls, "a"),
+				func(next RoundTripFunc) RoundTripFunc { return tt.respond },
+				recordMiddleware(&calls, "c"),
+			))
+			servTime, err := c.GetTime(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ошибка %v, ожидали %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && strconv.FormatInt(servTime.Unix(), 10) != tt.want {
+				t.Errorf("время %d, ожидали %s", servTime.Unix(), tt.want)
+			}
+			// внутренняя обертка не вызывается
+			if !equalSlices(calls, []string{"a before", "a after"}) {
+				t.Errorf("вызовы %v", calls)
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	c := NewClient("")
+	format := regexp.MustCompile(`^(\d+)-([0-9a-f]+)-(\d+)$`)
+
+	const n = 100
+	ids := make([]string, n)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i] = c.getRequestID()
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool, n)
+	seqs := make(map[string]bool, n)
+	for _, id := range ids {
+		m := format.FindStringSubmatch(id)
+		if m == nil {
+			t.Fatalf("формат %q, ожидали UnixNano-salt-seq", id)
+		}
+		if m[2] != requestSalt || len(requestSalt) != 8 {
+			t.Fatalf("случайная часть %q, ожидали %q (8 hex)", m[2], requestSalt)
+		}
+		if seen[id] || seqs[m[3]] {
+			t.Fatalf("повтор ключа %q", id)
+		}
+		seen[id], seqs[m[3]] = true, true
+	}
+
+	// счетчик растет
+	first := format.FindStringSubmatch(c.getRequestID())
+	second := format.FindStringSubmatch(NewClient("").getRequestID())
+	a, _ := strconv.ParseUint(first[3], 10, 64)
+	b, _ := strconv.ParseUint(second[3], 10, 64)
+	if b <= a {
+		t.Errorf("счетчик %d после %d: общий для всех клиентов процесса", b, a)
+	}
+}
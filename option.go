@@ -10,16 +10,26 @@ type Options struct {
 	Limit      int32  // Ограничение на количество выдаваемых результатов поиска
 	Offset     int32  // Смещение начала выборки (для пагинации)
 	IncludeOld bool   // Флаг загрузки устаревших инструментов
-	//Format   string // Format Формат возвращаемого сервером JSON
+	Format     Format // Формат возвращаемого сервером JSON: Simple, Slim, Heavy
 }
 
 type Option func(p *Options)
 
-func NewOptions() *Options {
+func NewOptions(opts ...Option) *Options {
 	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
 	return o
 }
 
+// setFormat передадим формат ответа в запросе (если задан)
+func (o *Options) setFormat(r *request) {
+	if o.Format != "" {
+		r.setParam("format", o.Format)
+	}
+}
+
 // WithSector Рынок на бирже FORTS, FOND, CURR
 func WithSector(param string) Option {
 	return func(opts *Options) {
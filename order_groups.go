@@ -0,0 +1,371 @@
+package alor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+/*
+	Группы заявок
+	POST   /commandapi/api/orderGroups Создание группы заявок
+	GET    /commandapi/api/orderGroups Получение всех групп заявок
+	GET    /commandapi/api/orderGroups/{orderGroupId} Получение информации о группе заявок
+	PUT    /commandapi/api/orderGroups/{orderGroupId} Изменение группы заявок
+	DELETE /commandapi/api/orderGroups/{orderGroupId} Снятие группы заявок
+
+{
+  "orders": [
+    {
+      "portfolio": "D39004",
+      "exchange": "MOEX",
+      "orderId": "18995978560",
+      "type": "Limit"
+    },
+    {
+      "portfolio": "D39004",
+      "exchange": "MOEX",
+      "orderId": "18995978561",
+      "type": "StopLimit"
+    }
+  ],
+  "executionPolicy": "OnExecuteOrCancel"
+}
+*/
+
+// ExecutionPolicy политика исполнения группы заявок
+type ExecutionPolicy string
+
+const (
+	// ExecutionPolicyOnExecuteOrCancel при исполнении или отмене одной заявки снимаются остальные (one-cancels-other)
+	ExecutionPolicyOnExecuteOrCancel ExecutionPolicy = "OnExecuteOrCancel"
+	// ExecutionPolicyIgnoreCancel при исполнении одной заявки снимаются остальные. Отмена заявки игнорируется
+	ExecutionPolicyIgnoreCancel ExecutionPolicy = "IgnoreCancel"
+	// ExecutionPolicyTriggerBracketOrders при исполнении первой заявки активируются остальные (bracket)
+	ExecutionPolicyTriggerBracketOrders ExecutionPolicy = "TriggerBracketOrders"
+)
+
+// OrderGroupStatus статус группы заявок
+type OrderGroupStatus string
+
+const (
+	OrderGroupStatusActive   OrderGroupStatus = "Active"   // Группа активна
+	OrderGroupStatusCanceled OrderGroupStatus = "Canceled" // Группа снята
+	OrderGroupStatusFilled   OrderGroupStatus = "Filled"   // Группа исполнена
+)
+
+// OrderGroupOrder заявка в составе группы
+type OrderGroupOrder struct {
+	Portfolio string `json:"portfolio"` // Идентификатор клиентского портфеля
+	Exchange  string `json:"exchange"`  // Биржа
+	OrderID   string `json:"orderId"`   // Номер заявки
+	Type      string `json:"type"`      // Тип заявки: Market, Limit, Stop, StopLimit
+}
+
+// OrderGroup группа заявок
+type OrderGroup struct {
+	ID              string            `json:"id"`              // Идентификатор группы
+	Orders          []OrderGroupOrder `json:"orders"`          // Заявки в группе
+	ExecutionPolicy ExecutionPolicy   `json:"executionPolicy"` // Политика исполнения
+	Status          OrderGroupStatus  `json:"status"`          // Статус группы
+	CreatedAt       Time              `json:"createdAt"`       // Дата и время создания (UTC)
+	ClosedAt        Time              `json:"closedAt"`        // Дата и время закрытия (UTC)
+}
+
+// запрос на создание/изменение группы
+type orderGroupRequest struct {
+	Orders          []OrderGroupOrder `json:"orders"`
+	ExecutionPolicy ExecutionPolicy   `json:"executionPolicy"`
+}
+
+// ответ на команду по группе
+type orderGroupResponse struct {
+	Message string `json:"message"`
+	GroupID string `json:"groupId"`
+}
+
+// orderGroupType тип заявки в формате группы заявок
+func orderGroupType(orderType OrderType) string {
+	switch orderType {
+	case OrderTypeLimit:
+		return "Limit"
+	case OrderTypeStop:
+		return "Stop"
+	case OrderTypeStopLimit:
+		return "StopLimit"
+	default:
+		return "Market"
+	}
+}
+
+// элемент группы: заявка, которую нужно создать, или уже созданная заявка
+type orderGroupLeg struct {
+	order    *CreateOrderService
+	stop     *CreateOrderStopService
+	existing *OrderGroupOrder
+}
+
+// OrderGroupService создать группу связанных заявок
+type OrderGroupService struct {
+	c      *Client
+	policy ExecutionPolicy
+	legs   []orderGroupLeg
+}
+
+// NewOrderGroupService создать группу связанных заявок
+// по умолчанию политика one-cancels-other (OnExecuteOrCancel)
+func (c *Client) NewOrderGroupService() *OrderGroupService {
+	return &OrderGroupService{
+		c:      c,
+		policy: ExecutionPolicyOnExecuteOrCancel,
+	}
+}
+
+// NewBracketOrderService создать bracket заявку: вход + тейк-профит + стоп-лосс
+// Тейк-профит и стоп-лосс создаются не активными и активируются при исполнении входа
+func (c *Client) NewBracketOrderService(entry *CreateOrderService, takeProfit, stopLoss *CreateOrderStopService) *OrderGroupService {
+	return c.NewOrderGroupService().
+		ExecutionPolicy(ExecutionPolicyTriggerBracketOrders).
+		AddOrder(entry).
+		AddStopOrder(takeProfit).
+		AddStopOrder(stopLoss)
+}
+
+// ExecutionPolicy установим политику исполнения группы
+func (s *OrderGroupService) ExecutionPolicy(policy ExecutionPolicy) *OrderGroupService {
+	s.policy = policy
+	return s
+}
+
+// AddOrder добавим рыночную или лимитную заявку (будет создана при вызове Do)
+func (s *OrderGroupService) AddOrder(order *CreateOrderService) *OrderGroupService {
+	s.legs = append(s.legs, orderGroupLeg{order: order})
+	return s
+}
+
+// AddStopOrder добавим stop/stopLimit заявку (будет создана при вызове Do)
+func (s *OrderGroupService) AddStopOrder(order *CreateOrderStopService) *OrderGroupService {
+	s.legs = append(s.legs, orderGroupLeg{stop: order})
+	return s
+}
+
+// AddExistingOrder добавим уже созданную заявку
+func (s *OrderGroupService) AddExistingOrder(portfolio, orderID string, orderType OrderType) *OrderGroupService {
+	s.legs = append(s.legs, orderGroupLeg{existing: &OrderGroupOrder{
+		Portfolio: portfolio,
+		Exchange:  s.c.Exchange,
+		OrderID:   orderID,
+		Type:      orderGroupType(orderType),
+	}})
+	return s
+}
+
+// Do создадим заявки и объединим их в группу
+// Для TriggerBracketOrders все заявки кроме первой должны быть stop/stopLimit:
+// они создаются не активными и раньше входа, вход (первая заявка) создается последним,
+// непосредственно перед созданием группы. Рыночный вход для bracket не допускается.
+// Для остальных политик stop заявки создаются раньше обычных.
+// Если какую-то заявку создать не удалось, уже созданные заявки снимаются;
+// заявки, которые снять не удалось (например, уже исполненные), возвращаются в ошибке
+// возвращает ID созданной группы
+func (s *OrderGroupService) Do(ctx context.Context) (string, error) {
+	if len(s.legs) < 2 {
+		return "", fmt.Errorf("в группе должно быть не меньше двух заявок")
+	}
+	bracket := s.policy == ExecutionPolicyTriggerBracketOrders
+	if bracket {
+		if entry := s.legs[0].order; entry != nil && entry.order.OrderType == OrderTypeMarket {
+			return "", fmt.Errorf("bracket: вход не может быть рыночной заявкой")
+		}
+		for n, leg := range s.legs[1:] {
+			if leg.order != nil {
+				return "", fmt.Errorf("bracket: заявка %d должна быть stop/stopLimit", n+2)
+			}
+		}
+	}
+
+	// заявки в группе идут в исходном порядке, создаются в порядке createOrder
+	orders := make([]OrderGroupOrder, len(s.legs))
+	created := make([]int, 0, len(s.legs))
+	for _, n := range s.createOrder() {
+		leg := s.legs[n]
+		var (
+			o   OrderGroupOrder
+			err error
+		)
+		switch {
+		case leg.existing != nil:
+			o = *leg.existing
+		case leg.order != nil:
+			o.Portfolio = leg.order.order.User.Portfolio
+			o.Exchange = leg.order.order.Instrument.Exchange
+			o.Type = orderGroupType(leg.order.order.OrderType)
+			o.OrderID, err = leg.order.Do(ctx)
+		case leg.stop != nil:
+			if bracket && n > 0 {
+				leg.stop.Activate(false)
+			}
+			o.Portfolio = leg.stop.order.User.Portfolio
+			o.Exchange = leg.stop.order.Instrument.Exchange
+			o.Type = orderGroupType(leg.stop.order.OrderType)
+			o.OrderID, err = leg.stop.Do(ctx)
+		}
+		if err != nil {
+			log.Error("OrderGroupService: ошибка создания заявки", "n", n+1, "err", err.Error())
+			return "", s.rollback(ctx, orders, created, err)
+		}
+		orders[n] = o
+		if leg.existing == nil {
+			created = append(created, n)
+		}
+	}
+
+	r := &request{
+		method:   http.MethodPost,
+		endpoint: "/commandapi/api/orderGroups",
+		route:    "/commandapi/api/orderGroups",
+	}
+	groupID, err := s.c.sendOrderGroup(ctx, r, orderGroupRequest{Orders: orders, ExecutionPolicy: s.policy})
+	if err != nil {
+		return "", s.rollback(ctx, orders, created, err)
+	}
+	return groupID, nil
+}
+
+// createOrder порядок создания заявок (индексы s.legs)
+// сначала защитные stop заявки, затем обычные; для bracket вход создается последним
+func (s *OrderGroupService) createOrder() []int {
+	first := make([]int, 0, len(s.legs))
+	last := make([]int, 0, len(s.legs))
+	for n, leg := range s.legs {
+		entry := s.policy == ExecutionPolicyTriggerBracketOrders && n == 0
+		if entry || leg.order != nil {
+			last = append(last, n)
+			continue
+		}
+		first = append(first, n)
+	}
+	return append(first, last...)
+}
+
+// rollback снимем созданные заявки (в обратном порядке создания: сначала активные)
+// возвращает исходную ошибку cause вместе с заявками, которые снять не удалось
+func (s *OrderGroupService) rollback(ctx context.Context, orders []OrderGroupOrder, created []int, cause error) error {
+	errs := []error{cause}
+	for i := len(created) - 1; i >= 0; i-- {
+		o := orders[created[i]]
+		stop := o.Type == "Stop" || o.Type == "StopLimit"
+		exchange := o.Exchange
+		if exchange == "" {
+			exchange = s.c.Exchange
+		}
+		if _, err := s.c.cancelOrder(ctx, exchange, o.Portfolio, o.OrderID, stop); err != nil {
+			log.Error("OrderGroupService: ошибка снятия заявки", "orderID", o.OrderID, "err", err.Error())
+			errs = append(errs, fmt.Errorf("заявка %s (%s %s) не снята, возможно исполнена: %w", o.OrderID, o.Portfolio, o.Type, err))
+		}
+	}
+	if len(errs) == 1 {
+		return cause
+	}
+	return errors.Join(errs...)
+}
+
+// sendOrderGroup послать запрос на создание/изменение группы
+func (c *Client) sendOrderGroup(ctx context.Context, r *request, group orderGroupRequest) (string, error) {
+	buf := new(bytes.Buffer)
+	_ = json.NewEncoder(buf).Encode(&group)
+	r.body = buf
+	// установим заголовок json
+	r.setHeader("Content-Type", "application/json")
+
+	result := orderGroupResponse{}
+	data, err := c.callAPI(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	log.Debug("OrderGroup", slog.Any("response body", string(data)))
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return "", err
+	}
+	return result.GroupID, nil
+}
+
+// GetOrderGroups получение информации о всех группах заявок
+func (c *Client) GetOrderGroups(ctx context.Context) ([]OrderGroup, error) {
+	r := &request{
+		method:   http.MethodGet,
+		endpoint: "/commandapi/api/orderGroups",
+		route:    "/commandapi/api/orderGroups",
+	}
+	result := make([]OrderGroup, 0)
+	data, err := c.callAPI(ctx, r)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// GetOrderGroup получение информации о выбранной группе заявок
+func (c *Client) GetOrderGroup(ctx context.Context, groupID string) (OrderGroup, error) {
+	queryURL, _ := url.Parse("/commandapi/api/orderGroups")
+	queryURL.Path = path.Join(queryURL.Path, groupID)
+	r := &request{
+		method:   http.MethodGet,
+		endpoint: queryURL.String(),
+		route:    "/commandapi/api/orderGroups/{groupId}",
+	}
+	result := OrderGroup{}
+	data, err := c.callAPI(ctx, r)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// UpdateOrderGroup изменить состав и политику исполнения группы заявок
+func (c *Client) UpdateOrderGroup(ctx context.Context, groupID string, policy ExecutionPolicy, orders []OrderGroupOrder) (bool, error) {
+	queryURL, _ := url.Parse("/commandapi/api/orderGroups")
+	queryURL.Path = path.Join(queryURL.Path, groupID)
+	r := &request{
+		method:   http.MethodPut,
+		endpoint: queryURL.String(),
+		route:    "/commandapi/api/orderGroups/{groupId}",
+	}
+	_, err := c.sendOrderGroup(ctx, r, orderGroupRequest{Orders: orders, ExecutionPolicy: policy})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CancelOrderGroup снять группу заявок (вместе с заявками)
+func (c *Client) CancelOrderGroup(ctx context.Context, groupID string) (bool, error) {
+	queryURL, _ := url.Parse("/commandapi/api/orderGroups")
+	queryURL.Path = path.Join(queryURL.Path, groupID)
+	r := &request{
+		method:   http.MethodDelete,
+		endpoint: queryURL.String(),
+		route:    "/commandapi/api/orderGroups/{groupId}",
+	}
+	data, err := c.callAPI(ctx, r)
+	if err != nil {
+		return false, err
+	}
+	log.Debug("CancelOrderGroup", slog.Any("response body", string(data)))
+	return true, nil
+}
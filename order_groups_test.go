@@ -0,0 +1,113 @@
+package alor
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// orderGroupServer заглушка торгового API: запоминает порядок запросов
+type orderGroupServer struct {
+	mu        sync.Mutex
+	calls     []string
+	groupCode int // код ответа на создание группы
+	cancel    map[string]int
+}
+
+func (s *orderGroupServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := r.URL.Path
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(p, "/actions/limit"):
+		s.calls = append(s.calls, "limit")
+		_, _ = w.Write([]byte(`{"message":"success","orderNumber":"1"}`))
+	case r.Method == http.MethodPost && strings.HasSuffix(p, "/actions/stop"):
+		s.calls = append(s.calls, "stop")
+		_, _ = w.Write([]byte(`{"message":"success","orderNumber":"2"}`))
+	case r.Method == http.MethodPost && strings.HasSuffix(p, "/actions/stopLimit"):
+		s.calls = append(s.calls, "stopLimit")
+		_, _ = w.Write([]byte(`{"message":"success","orderNumber":"3"}`))
+	case r.Method == http.MethodPost && p == "/commandapi/api/orderGroups":
+		s.calls = append(s.calls, "group")
+		if s.groupCode != 0 {
+			w.WriteHeader(s.groupCode)
+			_, _ = w.Write([]byte(`{"message":"bad group"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"message":"success","groupId":"g1"}`))
+	case r.Method == http.MethodDelete:
+		id := p[strings.LastIndex(p, "/")+1:]
+		s.calls = append(s.calls, "cancel "+id)
+		if code := s.cancel[id]; code != 0 {
+			w.WriteHeader(code)
+			_, _ = w.Write([]byte(`{"message":"order is filled"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"message":"success"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newBracket(c *Client, entryType OrderType) *OrderGroupService {
+	entry := c.NewCreateOrderService().Symbol("SBER").Side(SideTypeBuy).OrderType(entryType).Qty(1).Price(250)
+	take := c.NewCreateOrderStopService().Symbol("SBER").Side(SideTypeSell).OrderType(OrderTypeStopLimit).Qty(1).Price(260).TriggerPrice(260)
+	stop := c.NewCreateOrderStopService().Symbol("SBER").Side(SideTypeSell).OrderType(OrderTypeStop).Qty(1).TriggerPrice(240)
+	return c.NewBracketOrderService(entry, take, stop)
+}
+
+func TestBracketOrderCreatesEntryLast(t *testing.T) {
+	srv := &orderGroupServer{}
+	c := newTestClient(t, srv.handle, WithRetryPolicy(NoRetry()))
+
+	groupID, err := newBracket(c, OrderTypeLimit).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groupID != "g1" {
+		t.Fatalf("groupID = %q", groupID)
+	}
+	want := "stopLimit,stop,limit,group"
+	if got := strings.Join(srv.calls, ","); got != want {
+		t.Fatalf("порядок запросов %s, ожидали %s", got, want)
+	}
+}
+
+func TestBracketOrderRejectsMarketEntry(t *testing.T) {
+	srv := &orderGroupServer{}
+	c := newTestClient(t, srv.handle, WithRetryPolicy(NoRetry()))
+
+	if _, err := newBracket(c, OrderTypeMarket).Do(context.Background()); err == nil {
+		t.Fatal("ожидали ошибку для рыночного входа")
+	}
+	if len(srv.calls) != 0 {
+		t.Fatalf("заявки не должны создаваться: %v", srv.calls)
+	}
+}
+
+func TestBracketOrderRollback(t *testing.T) {
+	srv := &orderGroupServer{
+		groupCode: http.StatusBadRequest,
+		cancel:    map[string]int{"1": http.StatusBadRequest}, // вход уже исполнен
+	}
+	c := newTestClient(t, srv.handle, WithRetryPolicy(NoRetry()))
+
+	_, err := newBracket(c, OrderTypeLimit).Do(context.Background())
+	if err == nil {
+		t.Fatal("ожидали ошибку создания группы")
+	}
+	// вход снимается первым
+	want := "stopLimit,stop,limit,group,cancel 1,cancel 2,cancel 3"
+	if got := strings.Join(srv.calls, ","); got != want {
+		t.Fatalf("порядок запросов %s, ожидали %s", got, want)
+	}
+	if !strings.Contains(err.Error(), "заявка 1") {
+		t.Fatalf("в ошибке нет не снятой заявки: %v", err)
+	}
+	if !strings.Contains(err.Error(), "bad group") {
+		t.Fatalf("в ошибке нет исходной причины: %v", err)
+	}
+}
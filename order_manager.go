@@ -0,0 +1,329 @@
+package alor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrOrderFinalState = errors.New("заявка в конечном состоянии")
+	ErrOrderNotFilled  = errors.New("заявка завершена без полного исполнения")
+)
+
+// OrderState состояние заявки в OrderManager
+type OrderState string
+
+const (
+	OrderStateNew             OrderState = "new"              // Заявка отправлена, статуса от сервера еще нет
+	OrderStateWorking         OrderState = "working"          // На исполнении
+	OrderStatePartiallyFilled OrderState = "partially_filled" // На исполнении, частично исполнена
+	OrderStateFilled          OrderState = "filled"           // Полностью исполнена
+	OrderStateCanceled        OrderState = "canceled"         // Отменена
+	OrderStateRejected        OrderState = "rejected"         // Отклонена
+)
+
+// IsFinal заявка в конечном состоянии (больше не изменится)
+func (s OrderState) IsFinal() bool {
+	return s == OrderStateFilled || s == OrderStateCanceled || s == OrderStateRejected
+}
+
+// допустимые переходы между состояниями заявки
+var orderTransitions = map[OrderState][]OrderState{
+	OrderStateNew:             {OrderStateWorking, OrderStatePartiallyFilled, OrderStateFilled, OrderStateCanceled, OrderStateRejected},
+	OrderStateWorking:         {OrderStatePartiallyFilled, OrderStateFilled, OrderStateCanceled, OrderStateRejected},
+	OrderStatePartiallyFilled: {OrderStatePartiallyFilled, OrderStateFilled, OrderStateCanceled},
+}
+
+// canTransition разрешен ли переход из состояния from в состояние to
+func canTransition(from, to OrderState) bool {
+	for _, s := range orderTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// orderStateOf определим состояние по данным заявки
+func orderStateOf(o Order) OrderState {
+	switch o.Status {
+	case OrderStatusWorking:
+		if o.Filled > 0 {
+			return OrderStatePartiallyFilled
+		}
+		return OrderStateWorking
+	case OrderStatusFilled:
+		return OrderStateFilled
+	case OrderStatusCanceled:
+		return OrderStateCanceled
+	case OrderStatusRejected:
+		return OrderStateRejected
+	default:
+		return OrderStateNew
+	}
+}
+
+// OrderEvent событие изменения заявки
+type OrderEvent struct {
+	Time  time.Time  // Время получения события
+	From  OrderState // Предыдущее состояние
+	To    OrderState // Новое состояние
+	Delta int32      // Сколько лот исполнилось с прошлого события
+	Order Order      // Данные заявки
+}
+
+type OrderEventFunc func(event OrderEvent)
+type OrderFillFunc func(order Order, delta int32)
+
+// отслеживаемая заявка
+type trackedOrder struct {
+	order   Order
+	state   OrderState
+	history []OrderEvent
+	changed chan struct{} // закрывается при каждом изменении (для ожидающих)
+}
+
+// OrderManager отслеживает состояние заявок портфеля
+// Начальное состояние берется из GetOrders, дальше обновляется через SubscribeOrders
+type OrderManager struct {
+	c             *Client
+	portfolio     string
+	mu            sync.Mutex
+	orders        map[string]*trackedOrder
+	onStateChange OrderEventFunc     // Функция обработки изменения состояния заявки
+	onPartialFill OrderFillFunc      // Функция обработки исполнения части заявки
+	unregister    func()             // отмена подписки на заявки клиента (RegisterOnOrder)
+	cancel        context.CancelFunc // остановка подписки SubscribeOrders (nil, если не запущен)
+}
+
+// NewOrderManager создать менеджер заявок по портфелю
+func (c *Client) NewOrderManager(portfolio string) *OrderManager {
+	return &OrderManager{
+		c:         c,
+		portfolio: portfolio,
+		orders:    make(map[string]*trackedOrder),
+	}
+}
+
+// SetOnStateChange регистрирует функцию на изменение состояния заявки
+func (m *OrderManager) SetOnStateChange(f OrderEventFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onStateChange = f
+}
+
+// SetOnPartialFill регистрирует функцию на каждое увеличение исполненного кол-ва (Filled)
+// delta = сколько лот исполнилось с прошлого обновления
+func (m *OrderManager) SetOnPartialFill(f OrderFillFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onPartialFill = f
+}
+
+// Start загрузим текущие заявки и подпишемся на их изменения
+// Загруженные заявки становятся начальным состоянием: OnStateChange и OnPartialFill по ним не вызываются.
+// Повторный вызов Start (до Stop) ничего не делает: заявки не загружаются и подписка не создается второй раз
+func (m *OrderManager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	if m.cancel != nil {
+		m.mu.Unlock()
+		return nil
+	}
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.unregister = m.c.RegisterOnOrder(m.Update)
+	m.mu.Unlock()
+
+	orders, err := m.c.GetOrders(ctx, m.portfolio)
+	if err != nil {
+		m.Stop()
+		return err
+	}
+	for _, o := range orders {
+		m.update(o, false)
+	}
+	if err = m.c.SubscribeOrders(ctx, m.portfolio); err != nil {
+		m.Stop()
+		return err
+	}
+	return nil
+}
+
+// Stop перестанем получать изменения заявок: отменим обработчик и подписку SubscribeOrders
+// Отслеживаемые заявки сохраняются: после Stop можно снова вызвать Start
+func (m *OrderManager) Stop() {
+	m.mu.Lock()
+	cancel, unregister := m.cancel, m.unregister
+	m.cancel, m.unregister = nil, nil
+	m.mu.Unlock()
+	if unregister != nil {
+		unregister()
+	}
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// Track начать отслеживать только что отправленную заявку (состояние new)
+func (m *OrderManager) Track(orderID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.orders[orderID]; ok {
+		return
+	}
+	m.orders[orderID] = &trackedOrder{
+		order:   Order{ID: orderID, Portfolio: m.portfolio},
+		state:   OrderStateNew,
+		changed: make(chan struct{}),
+	}
+}
+
+// Update обработаем новые данные по заявке
+// Переходы между состояниями проверяются: устаревшие данные (например filled -> working) игнорируются
+func (m *OrderManager) Update(order Order) {
+	m.update(order, true)
+}
+
+// update обработаем новые данные по заявке
+// notify = false: изменение записывается без вызова OnStateChange и OnPartialFill (начальное состояние)
+func (m *OrderManager) update(order Order, notify bool) {
+	if order.Portfolio != "" && order.Portfolio != m.portfolio {
+		return
+	}
+	m.mu.Lock()
+	t, ok := m.orders[order.ID]
+	if !ok {
+		t = &trackedOrder{
+			state:   OrderStateNew,
+			changed: make(chan struct{}),
+		}
+		m.orders[order.ID] = t
+	}
+	from := t.state
+	to := orderStateOf(order)
+	delta := order.Filled - t.order.Filled
+
+	// ничего не изменилось
+	if ok && from == to && delta == 0 {
+		m.mu.Unlock()
+		return
+	}
+	if delta < 0 || !canTransition(from, to) {
+		m.mu.Unlock()
+		log.Warn("OrderManager: недопустимый переход", "orderID", order.ID, "from", from, "to", to, "filled", order.Filled)
+		return
+	}
+
+	event := OrderEvent{
+		Time:  time.Now(),
+		From:  from,
+		To:    to,
+		Delta: delta,
+		Order: order,
+	}
+	t.order = order
+	t.state = to
+	t.history = append(t.history, event)
+	close(t.changed)
+	t.changed = make(chan struct{})
+	onStateChange := m.onStateChange
+	onPartialFill := m.onPartialFill
+	m.mu.Unlock()
+
+	if !notify {
+		return
+	}
+	if onStateChange != nil && from != to {
+		onStateChange(event)
+	}
+	if onPartialFill != nil && delta > 0 {
+		onPartialFill(order, delta)
+	}
+}
+
+// Order вернем данные и состояние заявки
+func (m *OrderManager) Order(orderID string) (Order, OrderState, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.orders[orderID]
+	if !ok {
+		return Order{}, "", false
+	}
+	return t.order, t.state, true
+}
+
+// Orders вернем все отслеживаемые заявки
+func (m *OrderManager) Orders() []Order {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]Order, 0, len(m.orders))
+	for _, t := range m.orders {
+		result = append(result, t.order)
+	}
+	return result
+}
+
+// ActiveOrders вернем заявки, которые еще не в конечном состоянии
+func (m *OrderManager) ActiveOrders() []Order {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]Order, 0)
+	for _, t := range m.orders {
+		if !t.state.IsFinal() {
+			result = append(result, t.order)
+		}
+	}
+	return result
+}
+
+// History вернем историю изменений заявки
+func (m *OrderManager) History(orderID string) []OrderEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.orders[orderID]
+	if !ok {
+		return nil
+	}
+	history := make([]OrderEvent, len(t.history))
+	copy(history, t.history)
+	return history
+}
+
+// WaitForStatus ждем пока заявка перейдет в одно из указанных состояний
+// Если заявка перешла в конечное состояние, не входящее в список, вернем ErrOrderFinalState
+func (m *OrderManager) WaitForStatus(ctx context.Context, orderID string, states ...OrderState) (Order, error) {
+	m.Track(orderID)
+	for {
+		m.mu.Lock()
+		t := m.orders[orderID]
+		order, state, changed := t.order, t.state, t.changed
+		m.mu.Unlock()
+
+		for _, s := range states {
+			if s == state {
+				return order, nil
+			}
+		}
+		if state.IsFinal() {
+			return order, fmt.Errorf("заявка %s в состоянии %s: %w", orderID, state, ErrOrderFinalState)
+		}
+
+		select {
+		case <-ctx.Done():
+			return order, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// WaitForFill ждем полного исполнения заявки
+// Если заявка отменена или отклонена, вернем ErrOrderNotFilled
+func (m *OrderManager) WaitForFill(ctx context.Context, orderID string) (Order, error) {
+	order, err := m.WaitForStatus(ctx, orderID, OrderStateFilled)
+	if errors.Is(err, ErrOrderFinalState) {
+		return order, fmt.Errorf("заявка %s: %w", orderID, ErrOrderNotFilled)
+	}
+	return order, err
+}
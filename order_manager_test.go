@@ -0,0 +1,259 @@
+package alor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testOrder(status OrderStatus, filled int32) Order {
+	return Order{ID: "1", Portfolio: "D39004", Status: status, Qty: 10, Filled: filled}
+}
+
+func TestOrderManagerTransitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		updates []Order
+		state   OrderState
+		filled  int32
+		events  []OrderState // состояния в OnStateChange
+		fills   []int32      // delta в OnPartialFill
+	}{
+		{
+			name:    "working -> partially filled -> filled",
+			updates: []Order{testOrder(OrderStatusWorking, 0), testOrder(OrderStatusWorking, 3), testOrder(OrderStatusWorking, 7), testOrder(OrderStatusFilled, 10)},
+			state:   OrderStateFilled, filled: 10,
+			events: []OrderState{OrderStateWorking, OrderStatePartiallyFilled, OrderStateFilled},
+			fills:  []int32{3, 4, 3},
+		},
+		{
+			name:    "повтор обновления",
+			updates: []Order{testOrder(OrderStatusWorking, 3), testOrder(OrderStatusWorking, 3), testOrder(OrderStatusWorking, 3)},
+			state:   OrderStatePartiallyFilled, filled: 3,
+			events: []OrderState{OrderStatePartiallyFilled},
+			fills:  []int32{3},
+		},
+		{
+			name:    "устаревшее обновление после filled",
+			updates: []Order{testOrder(OrderStatusFilled, 10), testOrder(OrderStatusWorking, 5)},
+			state:   OrderStateFilled, filled: 10,
+			events: []OrderState{OrderStateFilled},
+			fills:  []int32{10},
+		},
+		{
+			name:    "исполненное кол-во уменьшилось",
+			updates: []Order{testOrder(OrderStatusWorking, 5), testOrder(OrderStatusWorking, 3)},
+			state:   OrderStatePartiallyFilled, filled: 5,
+			events: []OrderState{OrderStatePartiallyFilled},
+			fills:  []int32{5},
+		},
+		{
+			name:    "working после partially filled",
+			updates: []Order{testOrder(OrderStatusWorking, 2), testOrder(OrderStatusWorking, 0)},
+			state:   OrderStatePartiallyFilled, filled: 2,
+			events: []OrderState{OrderStatePartiallyFilled},
+			fills:  []int32{2},
+		},
+		{
+			name:    "canceled после частичного исполнения",
+			updates: []Order{testOrder(OrderStatusWorking, 4), testOrder(OrderStatusCanceled, 4), testOrder(OrderStatusWorking, 4)},
+			state:   OrderStateCanceled, filled: 4,
+			events: []OrderState{OrderStatePartiallyFilled, OrderStateCanceled},
+			fills:  []int32{4},
+		},
+		{
+			name:    "rejected",
+			updates: []Order{testOrder(OrderStatusRejected, 0), testOrder(OrderStatusWorking, 0)},
+			state:   OrderStateRejected,
+			events:  []OrderState{OrderStateRejected},
+		},
+		{
+			name:    "чужой портфель",
+			updates: []Order{{ID: "1", Portfolio: "7500PST", Status: OrderStatusFilled, Qty: 10, Filled: 10}},
+			state:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewClient("").NewOrderManager("D39004")
+			var (
+				events []OrderState
+				fills  []int32
+			)
+			m.SetOnStateChange(func(e OrderEvent) { events = append(events, e.To) })
+			m.SetOnPartialFill(func(o Order, delta int32) { fills = append(fills, delta) })
+			for _, o := range tt.updates {
+				m.Update(o)
+			}
+
+			order, state, _ := m.Order("1")
+			if state != tt.state || order.Filled != tt.filled {
+				t.Errorf("состояние %s (filled %d), ожидали %s (filled %d)", state, order.Filled, tt.state, tt.filled)
+			}
+			if !equalSlices(events, tt.events) {
+				t.Errorf("OnStateChange %v, ожидали %v", events, tt.events)
+			}
+			if !equalSlices(fills, tt.fills) {
+				t.Errorf("OnPartialFill %v, ожидали %v", fills, tt.fills)
+			}
+		})
+	}
+}
+
+func equalSlices[T comparable](a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOrderManagerWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		wait    func(m *OrderManager, ctx context.Context) (Order, error)
+		updates []Order
+		err     error
+	}{
+		{
+			name:    "WaitForFill исполнена",
+			wait:    func(m *OrderManager, ctx context.Context) (Order, error) { return m.WaitForFill(ctx, "1") },
+			updates: []Order{testOrder(OrderStatusWorking, 5), testOrder(OrderStatusFilled, 10)},
+		},
+		{
+			name:    "WaitForFill отменена",
+			wait:    func(m *OrderManager, ctx context.Context) (Order, error) { return m.WaitForFill(ctx, "1") },
+			updates: []Order{testOrder(OrderStatusWorking, 5), testOrder(OrderStatusCanceled, 5)},
+			err:     ErrOrderNotFilled,
+		},
+		{
+			name:    "WaitForFill таймаут",
+			wait:    func(m *OrderManager, ctx context.Context) (Order, error) { return m.WaitForFill(ctx, "1") },
+			updates: []Order{testOrder(OrderStatusWorking, 5)},
+			err:     context.DeadlineExceeded,
+		},
+		{
+			name: "WaitForStatus working",
+			wait: func(m *OrderManager, ctx context.Context) (Order, error) {
+				return m.WaitForStatus(ctx, "1", OrderStateWorking, OrderStatePartiallyFilled)
+			},
+			updates: []Order{testOrder(OrderStatusWorking, 0)},
+		},
+		{
+			name: "WaitForStatus конечное состояние",
+			wait: func(m *OrderManager, ctx context.Context) (Order, error) {
+				return m.WaitForStatus(ctx, "1", OrderStatePartiallyFilled)
+			},
+			updates: []Order{testOrder(OrderStatusRejected, 0)},
+			err:     ErrOrderFinalState,
+		},
+		{
+			name: "WaitForStatus таймаут",
+			wait: func(m *OrderManager, ctx context.Context) (Order, error) {
+				return m.WaitForStatus(ctx, "1", OrderStateFilled)
+			},
+			err: context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewClient("").NewOrderManager("D39004")
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			go func() {
+				for _, o := range tt.updates {
+					time.Sleep(5 * time.Millisecond)
+					m.Update(o)
+				}
+			}()
+			_, err := tt.wait(m, ctx)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ошибка %v, ожидали %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestOrderManagerStart(t *testing.T) {
+	var loads, dials atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		loads.Add(1)
+		_, _ = w.Write([]byte(`[{"id":"1","portfolio":"D39004","status":"working","qty":10,"filled":4}]`))
+	})
+	// подписка не подключится (404): считаем попытки подключения SubscribeOrders
+	ws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dials.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ws.Close()
+	c.wsURL = "ws" + strings.TrimPrefix(ws.URL, "http")
+	waitDials := func(n int32) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for dials.Load() < n && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if got := dials.Load(); got != n {
+			t.Fatalf("подключений SubscribeOrders %d, ожидали %d", got, n)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := c.NewOrderManager("D39004")
+	var (
+		events []OrderState
+		fills  []int32
+	)
+	m.SetOnStateChange(func(e OrderEvent) { events = append(events, e.To) })
+	m.SetOnPartialFill(func(o Order, delta int32) { fills = append(fills, delta) })
+
+	for i := 0; i < 2; i++ {
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// повторный Start не загружает заявки и не подписывается второй раз
+	waitDials(1)
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("загрузок заявок %d, ожидали 1", n)
+	}
+	// начальное состояние без вызова обработчиков
+	if _, state, _ := m.Order("1"); state != OrderStatePartiallyFilled {
+		t.Fatalf("состояние %s, ожидали %s", state, OrderStatePartiallyFilled)
+	}
+	if len(events) != 0 || len(fills) != 0 {
+		t.Fatalf("обработчики вызваны при загрузке: %v %v", events, fills)
+	}
+
+	// обработчик зарегистрирован один раз
+	c.PublishOrder(Order{ID: "1", Portfolio: "D39004", Status: OrderStatusWorking, Qty: 10, Filled: 6})
+	if !equalSlices(fills, []int32{2}) {
+		t.Fatalf("OnPartialFill %v, ожидали [2]", fills)
+	}
+
+	m.Stop()
+	c.PublishOrder(Order{ID: "1", Portfolio: "D39004", Status: OrderStatusFilled, Qty: 10, Filled: 10})
+	if _, state, _ := m.Order("1"); state != OrderStatePartiallyFilled {
+		t.Fatalf("после Stop состояние изменилось: %s", state)
+	}
+
+	// после Stop можно запустить снова
+	if err := m.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitDials(2)
+	if n := loads.Load(); n != 2 {
+		t.Fatalf("загрузок заявок после перезапуска %d, ожидали 2", n)
+	}
+	m.Stop()
+}
@@ -2,7 +2,6 @@ package alor
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -15,15 +14,19 @@ import (
 // https://apidev.alor.ru/md/v2/orderbooks/MOEX/LKOH?depth=20&format=Simple
 
 // GetOrderBooks Получение информации о биржевом стакане
-func (c *Client) GetOrderBooks(ctx context.Context, symbol string) (OrderBook, error) {
+// Формат ответа можно выбрать опцией WithFormat
+func (c *Client) GetOrderBooks(ctx context.Context, symbol string, opts ...Option) (OrderBook, error) {
+	params := NewOptions(opts...)
 	queryURL, _ := url.Parse("/md/v2/orderbooks")
 	queryURL.Path = path.Join(queryURL.Path, c.Exchange, symbol)
 	r := &request{
 		method:   http.MethodGet,
 		endpoint: queryURL.String(),
+		route:    "/md/v2/orderbooks/{exchange}/{symbol}",
 	}
 	// выставим максимальное
 	r.setParam("depth", 20)
+	params.setFormat(r)
 
 	result := OrderBook{}
 	data, err := c.callAPI(ctx, r)
@@ -31,7 +34,7 @@ func (c *Client) GetOrderBooks(ctx context.Context, symbol string) (OrderBook, e
 		return result, err
 	}
 
-	err = json.Unmarshal(data, &result)
+	err = decodeFormat[orderBookSlim](data, params.Format, &result)
 	if err != nil {
 		return result, err
 	}
@@ -98,6 +101,11 @@ func (b *OrderBook) LastTime() time.Time {
 	return time.UnixMilli(b.MsTimestamp)
 }
 
+// Time вернем время состояния стакана (UTC)
+func (b *OrderBook) Time() Time {
+	return UnixTime(b.MsTimestamp)
+}
+
 func (b *OrderBook) BestBid() (PriceVolume, bool) {
 	if len(b.Bids) == 0 {
 		return PriceVolume{}, false
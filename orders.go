@@ -12,11 +12,16 @@ import (
 
 // GetOrders получение информации о всех заявках
 func (c *Client) GetOrders(ctx context.Context, portfolio string) ([]Order, error) {
+	return c.getOrders(ctx, c.Exchange, portfolio)
+}
+
+func (c *Client) getOrders(ctx context.Context, exchange, portfolio string) ([]Order, error) {
 	queryURL, _ := url.Parse("/md/v2/Clients")
-	queryURL.Path = path.Join(queryURL.Path, c.Exchange, portfolio, "orders")
+	queryURL.Path = path.Join(queryURL.Path, exchange, portfolio, "orders")
 	r := &request{
 		method:   http.MethodGet,
 		endpoint: queryURL.String(),
+		route:    "/md/v2/Clients/{exchange}/{portfolio}/orders",
 	}
 	result := make([]Order, 0)
 	data, err := c.callAPI(ctx, r)
@@ -32,11 +37,16 @@ func (c *Client) GetOrders(ctx context.Context, portfolio string) ([]Order, erro
 
 // GetOrder получение информации о выбранной заявке
 func (c *Client) GetOrder(ctx context.Context, portfolio, orderId string) (Order, error) {
+	return c.getOrder(ctx, c.Exchange, portfolio, orderId)
+}
+
+func (c *Client) getOrder(ctx context.Context, exchange, portfolio, orderId string) (Order, error) {
 	queryURL, _ := url.Parse("/md/v2/Clients")
-	queryURL.Path = path.Join(queryURL.Path, c.Exchange, portfolio, "orders", orderId)
+	queryURL.Path = path.Join(queryURL.Path, exchange, portfolio, "orders", orderId)
 	r := &request{
 		method:   http.MethodGet,
 		endpoint: queryURL.String(),
+		route:    "/md/v2/Clients/{exchange}/{portfolio}/orders/{orderId}",
 	}
 	result := Order{}
 	data, err := c.callAPI(ctx, r)
@@ -50,6 +60,56 @@ func (c *Client) GetOrder(ctx context.Context, portfolio, orderId string) (Order
 	return result, nil
 }
 
+// GetStopOrders получение информации о всех стоп-заявках
+func (c *Client) GetStopOrders(ctx context.Context, portfolio string) ([]StopOrder, error) {
+	return c.getStopOrders(ctx, c.Exchange, portfolio)
+}
+
+func (c *Client) getStopOrders(ctx context.Context, exchange, portfolio string) ([]StopOrder, error) {
+	queryURL, _ := url.Parse("/md/v2/Clients")
+	queryURL.Path = path.Join(queryURL.Path, exchange, portfolio, "stoporders")
+	r := &request{
+		method:   http.MethodGet,
+		endpoint: queryURL.String(),
+		route:    "/md/v2/Clients/{exchange}/{portfolio}/stoporders",
+	}
+	result := make([]StopOrder, 0)
+	data, err := c.callAPI(ctx, r)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// GetStopOrder получение информации о выбранной стоп-заявке
+func (c *Client) GetStopOrder(ctx context.Context, portfolio, orderId string) (StopOrder, error) {
+	return c.getStopOrder(ctx, c.Exchange, portfolio, orderId)
+}
+
+func (c *Client) getStopOrder(ctx context.Context, exchange, portfolio, orderId string) (StopOrder, error) {
+	queryURL, _ := url.Parse("/md/v2/Clients")
+	queryURL.Path = path.Join(queryURL.Path, exchange, portfolio, "stoporders", orderId)
+	r := &request{
+		method:   http.MethodGet,
+		endpoint: queryURL.String(),
+		route:    "/md/v2/Clients/{exchange}/{portfolio}/stoporders/{orderId}",
+	}
+	result := StopOrder{}
+	data, err := c.callAPI(ctx, r)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
 // структура ответа
 type OrderResponse struct {
 	Code        string `json:"code"`
@@ -64,14 +124,26 @@ type OrderResponse struct {
 // CancelOrder отменить заявку
 // TODO решить что возвращать
 func (c *Client) CancelOrder(ctx context.Context, portfolio, orderId string) (bool, error) {
+	return c.cancelOrder(ctx, c.Exchange, portfolio, orderId, false)
+}
+
+// CancelStopOrder отменить стоп-заявку
+func (c *Client) CancelStopOrder(ctx context.Context, portfolio, orderId string) (bool, error) {
+	return c.cancelOrder(ctx, c.Exchange, portfolio, orderId, true)
+}
+
+// cancelOrder отменить заявку на бирже exchange
+func (c *Client) cancelOrder(ctx context.Context, exchange, portfolio, orderId string, stop bool) (bool, error) {
 	queryURL, _ := url.Parse("/commandapi/warptrans/TRADE/v2/client/orders")
 	queryURL.Path = path.Join(queryURL.Path, orderId)
 	r := &request{
 		method:   http.MethodDelete,
 		endpoint: queryURL.String(),
+		route:    "/commandapi/warptrans/TRADE/v2/client/orders/{orderId}",
 	}
-	r.setParam("exchange", c.Exchange)
+	r.setParam("exchange", exchange)
 	r.setParam("portfolio", portfolio)
+	r.setParam("stop", stop)
 	r.setParam("jsonResponse", "true")
 
 	//result := OrderResponse{}
@@ -94,7 +166,7 @@ func (c *Client) CancelOrder(ctx context.Context, portfolio, orderId string) (bo
 // NewCreateOrderService создать новый ордер
 func (c *Client) NewCreateOrderService() *CreateOrderService {
 	user := User{
-		Portfolio: c.portfolioID, // проставим по умолчанию
+		Portfolio: c.defaultPortfolio(), // проставим по умолчанию
 	}
 	ticker := Instrument{
 		Symbol:   "",
@@ -116,14 +188,23 @@ func (c *Client) NewCreateOrderService() *CreateOrderService {
 }
 
 // послать команду на создание нового ордера
+// при сетевой ошибке или сбое сервера запрос повторяется с тем же X-ALOR-REQID (RetryPolicy)
 // возвращает ID созданной заявки
-func (s *CreateOrderService) Do(ctx context.Context) (string, error) {
+func (s *CreateOrderService) Do(ctx context.Context) (orderID string, err error) {
+	ctx, span := s.c.startSpan(ctx, "alor.CreateOrder", orderAttributes(s.order.OrderType, s.order.Instrument.Symbol, s.order.Side, s.order.Quantity, s.order.User.Portfolio)...)
+	defer func() {
+		endOrderSpan(span, orderID, err)
+		s.c.metrics.OrderResult("CreateOrder", err)
+	}()
+
 	r := &request{
 		method:   http.MethodPost,
 		endpoint: "/commandapi/warptrans/TRADE/v2/client/orders/actions/market",
+		route:    "/commandapi/warptrans/TRADE/v2/client/orders/actions/market",
 	}
 	if s.order.OrderType == OrderTypeLimit {
 		r.endpoint = "/commandapi/warptrans/TRADE/v2/client/orders/actions/limit"
+		r.route = r.endpoint
 	}
 
 	// в request.body надо записать order
@@ -135,8 +216,13 @@ func (s *CreateOrderService) Do(ctx context.Context) (string, error) {
 	// Требуется уникальная случайная строка в качестве идентификатора запроса.
 	// Если уже приходил запрос с таким идентификатором, то заявка не будет исполнена повторно,
 	// а в качестве ответа будет возвращена копия ответа на первый запрос с таким значением идентификатора
-	// Текущее время в наносекундах, прошедших с 01.01.1970 в UTC
-	r.setHeader("X-ALOR-REQID", s.c.getRequestID())
+	// Сгенерированный ключ действует в пределах одного вызова Do (повторы RetryPolicy идут с ним),
+	// ключ, заданный через IdempotencyKey, используется во всех вызовах Do
+	requestID := s.requestID
+	if requestID == "" {
+		requestID = s.c.getRequestID()
+	}
+	r.setHeader("X-ALOR-REQID", requestID)
 	// установим заголовок json
 	r.setHeader("Content-Type", "application/json")
 
@@ -193,8 +279,10 @@ type OrderRequest struct {
 
 // CreateOrderService создать новую заявку (ордер)
 type CreateOrderService struct {
-	c     *Client
-	order OrderRequest
+	c          *Client
+	order      OrderRequest
+	roundPrice bool   // при проверке (Validate) округлять цену до шага цены
+	requestID  string // ключ идемпотентности (X-ALOR-REQID)
 }
 
 // Side установим направление ордера
@@ -215,6 +303,14 @@ func (s *CreateOrderService) Symbol(symbol string) *CreateOrderService {
 	return s
 }
 
+// Exchange установим биржу (по умолчанию биржа клиента)
+func (s *CreateOrderService) Exchange(exchange string) *CreateOrderService {
+	if exchange != "" {
+		s.order.Instrument.Exchange = exchange
+	}
+	return s
+}
+
 // Board установим Код режима торгов
 func (s *CreateOrderService) Board(board string) *CreateOrderService {
 	s.order.Instrument.InstrumentGroup = board
@@ -250,3 +346,18 @@ func (s *CreateOrderService) OrderType(orderType OrderType) *CreateOrderService
 	s.order.OrderType = orderType
 	return s
 }
+
+// IdempotencyKey установим ключ идемпотентности (X-ALOR-REQID)
+// Если сервер уже получал запрос с таким ключом, то заявка не будет создана повторно,
+// а вернется копия ответа на первый запрос. Ключ нужен, чтобы безопасно повторить Do после ошибки;
+// для другой заявки задайте новый ключ. По умолчанию ключ генерируется на каждый вызов Do
+func (s *CreateOrderService) IdempotencyKey(key string) *CreateOrderService {
+	s.requestID = key
+	return s
+}
+
+// Request вернем сформированный запрос на создание заявки
+// (например для отправки через CommandConnection)
+func (s *CreateOrderService) Request() OrderRequest {
+	return s.order
+}
@@ -0,0 +1,262 @@
+package alor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// CancelStatus результат отмены заявки
+type CancelStatus string
+
+const (
+	CancelStatusCanceled    CancelStatus = "canceled" // Заявка отменена
+	CancelStatusAlreadyDone CancelStatus = "done"     // Заявка уже исполнена или снята (отменять нечего)
+	CancelStatusFailed      CancelStatus = "failed"   // Ошибка отмены
+)
+
+// по умолчанию одновременно отправляем не больше 5 команд на отмену
+const cancelConcurrency = 5
+
+// CancelFilter фильтр заявок для массовой отмены
+type CancelFilter struct {
+	Symbol        string      // Код инструмента
+	Side          SideType    // Направление сделки
+	OrderTypes    []OrderType // Типы заявок (limit market stop stopLimit). Пусто = все
+	CommentPrefix string      // Начало комментария к заявке
+	Exchanges     []string    // Биржи, по которым получаем список заявок. Пусто = Client.Exchange
+	Concurrency   int         // Сколько команд отправлять одновременно
+}
+
+type CancelOption func(f *CancelFilter)
+
+// CancelBySymbol отменять заявки только по инструменту
+func CancelBySymbol(symbol string) CancelOption {
+	return func(f *CancelFilter) {
+		f.Symbol = symbol
+	}
+}
+
+// CancelBySide отменять заявки только указанного направления
+func CancelBySide(side SideType) CancelOption {
+	return func(f *CancelFilter) {
+		f.Side = side
+	}
+}
+
+// CancelByOrderType отменять заявки только указанных типов
+func CancelByOrderType(orderTypes ...OrderType) CancelOption {
+	return func(f *CancelFilter) {
+		f.OrderTypes = append(f.OrderTypes, orderTypes...)
+	}
+}
+
+// CancelByCommentPrefix отменять заявки, комментарий которых начинается с prefix
+func CancelByCommentPrefix(prefix string) CancelOption {
+	return func(f *CancelFilter) {
+		f.CommentPrefix = prefix
+	}
+}
+
+// CancelOnExchanges отменять заявки на указанных биржах (по умолчанию только Client.Exchange)
+func CancelOnExchanges(exchanges ...string) CancelOption {
+	return func(f *CancelFilter) {
+		f.Exchanges = append(f.Exchanges, exchanges...)
+	}
+}
+
+// CancelConcurrency сколько команд на отмену отправлять одновременно
+func CancelConcurrency(n int) CancelOption {
+	return func(f *CancelFilter) {
+		f.Concurrency = n
+	}
+}
+
+// CancelResult результат отмены одной заявки
+type CancelResult struct {
+	OrderID     string       // Номер заявки
+	Exchange    string       // Биржа заявки
+	Symbol      string       // Код инструмента
+	Type        OrderType    // Тип заявки
+	Stop        bool         // Стоп-заявка
+	Status      CancelStatus // Результат отмены
+	OrderStatus OrderStatus  // Статус заявки (после отмены)
+	Err         error        // Ошибка отмены
+}
+
+// CancelReport отчет по массовой отмене заявок
+type CancelReport struct {
+	Results []CancelResult
+}
+
+// Canceled отмененные заявки
+func (r CancelReport) Canceled() []CancelResult {
+	return r.byStatus(CancelStatusCanceled)
+}
+
+// AlreadyDone заявки, которые к моменту отмены уже исполнились или были сняты
+func (r CancelReport) AlreadyDone() []CancelResult {
+	return r.byStatus(CancelStatusAlreadyDone)
+}
+
+// Failed заявки, которые не удалось отменить
+func (r CancelReport) Failed() []CancelResult {
+	return r.byStatus(CancelStatusFailed)
+}
+
+// Err вернем объединенную ошибку по всем не отмененным заявкам (или nil)
+func (r CancelReport) Err() error {
+	var errs []error
+	for _, res := range r.Failed() {
+		errs = append(errs, res.Err)
+	}
+	return errors.Join(errs...)
+}
+
+func (r CancelReport) byStatus(status CancelStatus) []CancelResult {
+	result := make([]CancelResult, 0)
+	for _, res := range r.Results {
+		if res.Status == status {
+			result = append(result, res)
+		}
+	}
+	return result
+}
+
+// match заявка подходит под фильтр
+func (f *CancelFilter) match(o Order) bool {
+	if o.Status != OrderStatusWorking {
+		return false
+	}
+	if f.Symbol != "" && o.Symbol != f.Symbol {
+		return false
+	}
+	if f.Side != "" && o.Side != f.Side {
+		return false
+	}
+	if f.CommentPrefix != "" && !strings.HasPrefix(o.Comment, f.CommentPrefix) {
+		return false
+	}
+	if len(f.OrderTypes) == 0 {
+		return true
+	}
+	for _, t := range f.OrderTypes {
+		// для стоп-заявок сервер возвращает тип в нижнем регистре (stoplimit)
+		if strings.EqualFold(string(t), string(o.Type)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CancelAllOrders отменить все активные заявки (в том числе стоп-заявки) по портфелю
+// Заявки можно отфильтровать по инструменту, направлению, типу и комментарию
+// Заявка снимается на той бирже, которую вернул сервер в данных заявки.
+// Если не удалось получить какой-то список заявок (например стоп-заявок), остальные заявки все равно снимаются,
+// а ошибка получения списка возвращается вместе с ошибками отмены (CancelReport.Err).
+// Если все списки получены, ошибка = nil: результат отмены по каждой заявке смотрите в CancelReport
+func (c *Client) CancelAllOrders(ctx context.Context, portfolio string, opts ...CancelOption) (CancelReport, error) {
+	filter := &CancelFilter{
+		Concurrency: cancelConcurrency,
+	}
+	// обработаем входящие параметры
+	for _, opt := range opts {
+		opt(filter)
+	}
+	if filter.Concurrency <= 0 {
+		filter.Concurrency = 1
+	}
+	exchanges := filter.Exchanges
+	if len(exchanges) == 0 {
+		exchanges = []string{c.Exchange}
+	}
+
+	report := CancelReport{}
+	var listErrs []error
+	for _, exchange := range exchanges {
+		orders, err := c.getOrders(ctx, exchange, portfolio)
+		if err != nil {
+			log.Error("CancelAllOrders: ошибка получения заявок", "exchange", exchange, "err", err.Error())
+			listErrs = append(listErrs, fmt.Errorf("список заявок %s: %w", exchange, err))
+		}
+		for _, o := range orders {
+			if filter.match(o) {
+				report.Results = append(report.Results, CancelResult{OrderID: o.ID, Exchange: orderExchange(o, exchange), Symbol: o.Symbol, Type: o.Type})
+			}
+		}
+		stopOrders, err := c.getStopOrders(ctx, exchange, portfolio)
+		if err != nil {
+			log.Error("CancelAllOrders: ошибка получения стоп-заявок", "exchange", exchange, "err", err.Error())
+			listErrs = append(listErrs, fmt.Errorf("список стоп-заявок %s: %w", exchange, err))
+		}
+		for _, o := range stopOrders {
+			if filter.match(o.Order) {
+				report.Results = append(report.Results, CancelResult{OrderID: o.ID, Exchange: orderExchange(o.Order, exchange), Symbol: o.Symbol, Type: o.Type, Stop: true})
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, filter.Concurrency)
+	for i := range report.Results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(res *CancelResult) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			c.cancelWithReport(ctx, portfolio, res)
+		}(&report.Results[i])
+	}
+	wg.Wait()
+
+	if len(listErrs) > 0 {
+		return report, errors.Join(append(listErrs, report.Err())...)
+	}
+	return report, nil
+}
+
+// orderExchange биржа заявки (если сервер ее не вернул = биржа, по которой получали список)
+func orderExchange(o Order, exchange string) string {
+	if o.Exchange != "" {
+		return o.Exchange
+	}
+	return exchange
+}
+
+// cancelWithReport отменим заявку. При ошибке проверим: может заявка уже исполнена или снята
+func (c *Client) cancelWithReport(ctx context.Context, portfolio string, res *CancelResult) {
+	_, err := c.cancelOrder(ctx, res.Exchange, portfolio, res.OrderID, res.Stop)
+	if err == nil {
+		res.Status = CancelStatusCanceled
+		res.OrderStatus = OrderStatusCanceled
+		return
+	}
+
+	var status OrderStatus
+	if res.Stop {
+		o, getErr := c.getStopOrder(ctx, res.Exchange, portfolio, res.OrderID)
+		status = o.Status
+		if getErr != nil {
+			status = ""
+		}
+	} else {
+		o, getErr := c.getOrder(ctx, res.Exchange, portfolio, res.OrderID)
+		status = o.Status
+		if getErr != nil {
+			status = ""
+		}
+	}
+	if status != "" && status != OrderStatusWorking {
+		res.Status = CancelStatusAlreadyDone
+		res.OrderStatus = status
+		return
+	}
+	log.Error("CancelAllOrders", "orderID", res.OrderID, "err", err.Error())
+	res.Status = CancelStatusFailed
+	res.OrderStatus = status
+	res.Err = err
+}
@@ -0,0 +1,143 @@
+package alor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// cancelServer списки заявок по биржам и отмена заявок
+type cancelServer struct {
+	mu         sync.Mutex
+	stopStatus int               // статус ответа на список стоп-заявок (0 = 200)
+	lists      []string          // запрошенные списки заявок
+	canceled   map[string]string // номер заявки -> параметры отмены
+}
+
+func (s *cancelServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	const clients = "/md/v2/Clients/"
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/orders") && strings.HasPrefix(r.URL.Path, clients):
+		s.lists = append(s.lists, r.URL.Path)
+		if strings.HasPrefix(r.URL.Path, clients+"SPBX/") {
+			_, _ = w.Write([]byte(`[{"id":"20","symbol":"AAPL","exchange":"SPBX","type":"limit","side":"buy","status":"working"}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[
+			{"id":"1","symbol":"SBER","exchange":"MOEX","type":"limit","side":"buy","status":"working"},
+			{"id":"2","symbol":"AAPL","exchange":"SPBX","type":"limit","side":"sell","status":"working"},
+			{"id":"3","symbol":"SBER","exchange":"MOEX","type":"limit","side":"buy","status":"working"},
+			{"id":"4","symbol":"GAZP","exchange":"MOEX","type":"limit","side":"sell","status":"working"},
+			{"id":"5","symbol":"SBER","exchange":"MOEX","type":"limit","side":"buy","status":"filled"}
+		]`))
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/stoporders"):
+		s.lists = append(s.lists, r.URL.Path)
+		if s.stopStatus != 0 {
+			w.WriteHeader(s.stopStatus)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, clients+"SPBX/") {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id":"10","symbol":"SBER","exchange":"MOEX","type":"stoplimit","side":"sell","status":"working","stopPrice":250}]`))
+	case r.Method == http.MethodDelete:
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		s.canceled[id] = r.URL.Query().Get("exchange") + " stop=" + r.URL.Query().Get("stop")
+		if id == "3" || id == "4" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":"OrderRejected","message":"Invalid order state"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"message":"success"}`))
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/orders/3"):
+		// заявка исполнилась до отмены
+		_, _ = w.Write([]byte(`{"id":"3","exchange":"MOEX","status":"filled"}`))
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/orders/4"):
+		_, _ = w.Write([]byte(`{"id":"4","exchange":"MOEX","status":"working"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func resultIDs(results []CancelResult) string {
+	ids := make([]string, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.OrderID)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func TestCancelAllOrders(t *testing.T) {
+	tests := []struct {
+		name       string
+		stopStatus int
+		opts       []CancelOption
+		canceled   string
+		done       string
+		failed     string
+		wantErr    []error
+		lists      []string
+	}{
+		{name: "частичная ошибка отмены",
+			canceled: "1,10,2", done: "3", failed: "4",
+			lists: []string{"/md/v2/Clients/MOEX/D39004/orders", "/md/v2/Clients/MOEX/D39004/stoporders"}},
+		{name: "ошибка списка стоп-заявок", stopStatus: http.StatusInternalServerError,
+			canceled: "1,2", done: "3", failed: "4",
+			wantErr: []error{ErrServerError, ErrValidation},
+			lists:   []string{"/md/v2/Clients/MOEX/D39004/orders", "/md/v2/Clients/MOEX/D39004/stoporders"}},
+		{name: "фильтр", opts: []CancelOption{CancelBySymbol("SBER"), CancelByOrderType(OrderTypeStopLimit)},
+			canceled: "10",
+			lists:    []string{"/md/v2/Clients/MOEX/D39004/orders", "/md/v2/Clients/MOEX/D39004/stoporders"}},
+		{name: "несколько бирж", opts: []CancelOption{CancelOnExchanges("MOEX", "SPBX"), CancelBySide(SideTypeBuy)},
+			canceled: "1,20", done: "3",
+			lists: []string{"/md/v2/Clients/MOEX/D39004/orders", "/md/v2/Clients/MOEX/D39004/stoporders",
+				"/md/v2/Clients/SPBX/D39004/orders", "/md/v2/Clients/SPBX/D39004/stoporders"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &cancelServer{stopStatus: tt.stopStatus, canceled: make(map[string]string)}
+			c := newTestClient(t, srv.handle, WithRetryPolicy(NoRetry()))
+			report, err := c.CancelAllOrders(context.Background(), "D39004", tt.opts...)
+			if len(tt.wantErr) == 0 && err != nil {
+				t.Fatalf("ошибка %v", err)
+			}
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Errorf("ошибка %v, ожидали %v", err, want)
+				}
+			}
+			if got := resultIDs(report.Canceled()); got != tt.canceled {
+				t.Errorf("отменены %s, ожидали %s", got, tt.canceled)
+			}
+			if got := resultIDs(report.AlreadyDone()); got != tt.done {
+				t.Errorf("уже исполнены %s, ожидали %s", got, tt.done)
+			}
+			if got := resultIDs(report.Failed()); got != tt.failed {
+				t.Errorf("ошибка отмены %s, ожидали %s", got, tt.failed)
+			}
+			if (tt.failed != "") != (report.Err() != nil) {
+				t.Errorf("report.Err() = %v", report.Err())
+			}
+			if !equalSlices(srv.lists, tt.lists) {
+				t.Errorf("списки заявок %v, ожидали %v", srv.lists, tt.lists)
+			}
+			// заявка снимается на своей бирже, стоп-заявка с stop=true
+			for id, want := range map[string]string{"1": "MOEX stop=false", "2": "SPBX stop=false", "20": "SPBX stop=false", "10": "MOEX stop=true"} {
+				if got, ok := srv.canceled[id]; ok && got != want {
+					t.Errorf("заявка %s: отмена %q, ожидали %q", id, got, want)
+				}
+			}
+			if _, ok := srv.canceled["5"]; ok {
+				t.Error("отменена исполненная заявка")
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package alor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+/*
+	Расчет стоимости заявки (до отправки)
+	POST /commandapi/warptrans/FX1/v2/client/orders/estimate
+{
+  "portfolio": "D39004",
+  "ticker": "SBER",
+  "exchange": "MOEX",
+  "price": 290,
+  "lotQuantity": 1,
+  "board": "TQBR",
+  "includeLimitOrders": false
+}
+
+{
+  "portfolio": "D39004",
+  "ticker": "SBER",
+  "exchange": "MOEX",
+  "quantityToSell": 0,
+  "quantityToBuy": 3,
+  "notMarginQuantityToSell": 0,
+  "notMarginQuantityToBuy": 1,
+  "orderEvaluation": 2900,
+  "commission": 1.45
+}
+*/
+
+// запрос на расчет стоимости заявки
+type orderEstimateRequest struct {
+	Portfolio          string  `json:"portfolio"`             // Идентификатор клиентского портфеля
+	Ticker             string  `json:"ticker"`                // Тикер (Код финансового инструмента)
+	Exchange           string  `json:"exchange"`              // Биржа
+	Price              float64 `json:"price"`                 // Цена
+	LotQuantity        int32   `json:"lotQuantity,omitempty"` // Количество (лоты)
+	Board              string  `json:"board,omitempty"`       // Код режима торгов
+	IncludeLimitOrders bool    `json:"includeLimitOrders"`    // Учитывать ли выставленные заявки
+}
+
+// OrderEstimate расчет стоимости заявки
+type OrderEstimate struct {
+	Portfolio               string  `json:"portfolio"`               // Идентификатор клиентского портфеля
+	Ticker                  string  `json:"ticker"`                  // Тикер (Код финансового инструмента)
+	Exchange                string  `json:"exchange"`                // Биржа
+	QuantityToSell          int64   `json:"quantityToSell"`          // Максимальное кол-во лот на продажу (с учетом маржи)
+	QuantityToBuy           int64   `json:"quantityToBuy"`           // Максимальное кол-во лот на покупку (с учетом маржи)
+	NotMarginQuantityToSell int64   `json:"notMarginQuantityToSell"` // Максимальное кол-во лот на продажу без маржи
+	NotMarginQuantityToBuy  int64   `json:"notMarginQuantityToBuy"`  // Максимальное кол-во лот на покупку без маржи
+	OrderEvaluation         float64 `json:"orderEvaluation"`         // Стоимость заявки (на сколько уменьшится покупательская способность)
+	Commission              float64 `json:"commission"`              // Комиссия по заявке
+	Price                   float64 `json:"-"`                       // Цена, по которой сделан расчет
+	MaxQuantity             int64   `json:"-"`                       // Максимальное кол-во лот по направлению заявки
+}
+
+// Affordable хватает ли средств на заявку в qty лот
+func (e OrderEstimate) Affordable(qty int32) bool {
+	return int64(qty) <= e.MaxQuantity
+}
+
+// estimateOrder расчет стоимости заявки
+// если цена не указана (рыночная заявка), то берем цену последней сделки на бирже заявки
+func (c *Client) estimateOrder(ctx context.Context, req orderEstimateRequest, side SideType) (OrderEstimate, error) {
+	result := OrderEstimate{}
+	if req.Exchange == "" {
+		req.Exchange = c.Exchange
+	}
+	if req.Price == 0 {
+		quotes, err := c.GetQuotes(ctx, req.Exchange+":"+req.Ticker)
+		if err != nil {
+			return result, err
+		}
+		if len(quotes) == 0 || quotes[0].LastPrice == 0 {
+			return result, fmt.Errorf("%s:%s: нет цены последней сделки для расчета заявки", req.Exchange, req.Ticker)
+		}
+		req.Price = quotes[0].LastPrice
+	}
+	r := &request{
+		method:   http.MethodPost,
+		endpoint: "/commandapi/warptrans/FX1/v2/client/orders/estimate",
+		route:    "/commandapi/warptrans/FX1/v2/client/orders/estimate",
+	}
+	buf := new(bytes.Buffer)
+	_ = json.NewEncoder(buf).Encode(&req)
+	r.body = buf
+	// установим заголовок json
+	r.setHeader("Content-Type", "application/json")
+
+	data, err := c.callAPI(ctx, r)
+	if err != nil {
+		return result, err
+	}
+	log.Debug("EstimateOrder", slog.Any("response body", string(data)))
+	if err = json.Unmarshal(data, &result); err != nil {
+		return result, err
+	}
+	result.Price = req.Price
+	result.MaxQuantity = result.QuantityToBuy
+	if side == SideTypeSell {
+		result.MaxQuantity = result.QuantityToSell
+	}
+	return result, nil
+}
+
+// Estimate расчет стоимости заявки: комиссия, стоимость и максимальное доступное кол-во лот
+// Для рыночной заявки расчет делается по цене последней сделки
+func (s *CreateOrderService) Estimate(ctx context.Context) (OrderEstimate, error) {
+	req := orderEstimateRequest{
+		Portfolio:   s.order.User.Portfolio,
+		Ticker:      s.order.Instrument.Symbol,
+		Exchange:    s.order.Instrument.Exchange,
+		Board:       s.order.Instrument.InstrumentGroup,
+		LotQuantity: s.order.Quantity,
+	}
+	if s.order.OrderType == OrderTypeLimit {
+		req.Price = s.order.Price
+	}
+	return s.c.estimateOrder(ctx, req, s.order.Side)
+}
+
+// Estimate расчет стоимости stop/stopLimit заявки: комиссия, стоимость и максимальное доступное кол-во лот
+// Расчет делается по цене стоп-лимитной заявки, а для стоп-заявки по стоп-цене
+func (s *CreateOrderStopService) Estimate(ctx context.Context) (OrderEstimate, error) {
+	req := orderEstimateRequest{
+		Portfolio:   s.order.User.Portfolio,
+		Ticker:      s.order.Instrument.Symbol,
+		Exchange:    s.order.Instrument.Exchange,
+		Board:       s.order.Instrument.InstrumentGroup,
+		LotQuantity: s.order.Quantity,
+		Price:       s.order.TriggerPrice,
+	}
+	if s.order.OrderType == OrderTypeStopLimit && s.order.Price != 0 {
+		req.Price = s.order.Price
+	}
+	return s.c.estimateOrder(ctx, req, s.order.Side)
+}
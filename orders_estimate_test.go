@@ -0,0 +1,99 @@
+package alor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestEstimateOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		service   func(c *Client) *CreateOrderService
+		lastPrice string // ответ котировки (цена последней сделки)
+		wantQuote string // путь запроса котировки ("" = котировка не нужна)
+		wantBody  orderEstimateRequest
+		wantMax   int64
+		wantErr   bool
+	}{
+		{
+			name: "лимитная покупка",
+			service: func(c *Client) *CreateOrderService {
+				return c.NewCreateOrderService().Symbol("SBER").Board("TQBR").Side(SideTypeBuy).
+					OrderType(OrderTypeLimit).Price(250).Qty(2)
+			},
+			wantBody: orderEstimateRequest{Portfolio: "D39004", Ticker: "SBER", Exchange: "MOEX", Price: 250, LotQuantity: 2, Board: "TQBR"},
+			wantMax:  3,
+		},
+		{
+			name: "рыночная продажа на бирже заявки",
+			service: func(c *Client) *CreateOrderService {
+				return c.NewCreateOrderService().Exchange("SPBX").Symbol("AAPL").Side(SideTypeSell).
+					OrderType(OrderTypeMarket).Qty(1)
+			},
+			lastPrice: "251.5",
+			wantQuote: "/md/v2/Securities/SPBX:AAPL/quotes",
+			wantBody:  orderEstimateRequest{Portfolio: "D39004", Ticker: "AAPL", Exchange: "SPBX", Price: 251.5, LotQuantity: 1},
+			wantMax:   5,
+		},
+		{
+			name: "рыночная заявка без цены последней сделки",
+			service: func(c *Client) *CreateOrderService {
+				return c.NewCreateOrderService().Symbol("SBER").Side(SideTypeBuy).OrderType(OrderTypeMarket).Qty(1)
+			},
+			lastPrice: "0",
+			wantQuote: "/md/v2/Securities/MOEX:SBER/quotes",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu        sync.Mutex
+				quotePath string
+				body      *orderEstimateRequest
+			)
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				if r.URL.Path == "/commandapi/warptrans/FX1/v2/client/orders/estimate" {
+					body = &orderEstimateRequest{}
+					_ = json.NewDecoder(r.Body).Decode(body)
+					_, _ = w.Write([]byte(`{"quantityToBuy":3,"quantityToSell":5,"orderEvaluation":500,"commission":0.25}`))
+					return
+				}
+				quotePath = r.URL.Path
+				_, _ = w.Write([]byte(`[{"symbol":"SBER","last_price":` + tt.lastPrice + `}]`))
+			})
+			estimate, err := tt.service(c).Estimate(context.Background())
+			mu.Lock()
+			defer mu.Unlock()
+			if quotePath != tt.wantQuote {
+				t.Errorf("запрос котировки %q, ожидали %q", quotePath, tt.wantQuote)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("ожидали ошибку")
+				}
+				if body != nil {
+					t.Fatalf("расчет запрошен с ценой %v", body.Price)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body == nil || *body != tt.wantBody {
+				t.Fatalf("тело запроса %+v, ожидали %+v", body, tt.wantBody)
+			}
+			if estimate.MaxQuantity != tt.wantMax || estimate.Price != tt.wantBody.Price {
+				t.Errorf("MaxQuantity %d цена %v, ожидали %d %v", estimate.MaxQuantity, estimate.Price, tt.wantMax, tt.wantBody.Price)
+			}
+			if !estimate.Affordable(int32(tt.wantMax)) || estimate.Affordable(int32(tt.wantMax+1)) {
+				t.Errorf("Affordable не соответствует MaxQuantity %d", estimate.MaxQuantity)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package alor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+/*
+	Изменение (замена) заявки
+	PUT /commandapi/warptrans/TRADE/v2/client/orders/actions/market/{orderId}
+	PUT /commandapi/warptrans/TRADE/v2/client/orders/actions/limit/{orderId}
+	PUT /commandapi/warptrans/TRADE/v2/client/orders/actions/stop/{orderId}
+	PUT /commandapi/warptrans/TRADE/v2/client/orders/actions/stopLimit/{orderId}
+
+	Старая заявка снимается, вместо нее выставляется новая. В ответе номер новой заявки
+{
+  "side": "buy",
+  "quantity": 1,
+  "price": 191.33,
+  "instrument": {
+    "symbol": "SBER",
+    "exchange": "MOEX"
+  },
+  "user": {
+    "portfolio": "D39004"
+  },
+  "timeInForce": "oneday"
+}
+*/
+
+// ModifyOrderRequest запрос на изменение market/limit/stop/stopLimit заявки
+type ModifyOrderRequest struct {
+	OrderType       OrderType     `json:"-"`
+	Side            SideType      `json:"side"`                      // Направление сделки: buy — Купля sell — Продажа
+	Condition       ConditionType `json:"condition,omitempty"`       // Условие срабатывания стоп/стоп-лимитной заявки
+	Quantity        int32         `json:"quantity"`                  // Количество (лоты)
+	TriggerPrice    float64       `json:"triggerPrice,omitempty"`    // Стоп-цена (только stop/stopLimit)
+	Price           float64       `json:"price,omitempty"`           // Цена (limit/stopLimit)
+	StopEndUnixTime int64         `json:"stopEndUnixTime,omitempty"` // Срок действия (UTC) в формате Unix Time seconds (только stop/stopLimit)
+	Comment         string        `json:"comment,omitempty"`         // Пользовательский комментарий к заявке
+	Instrument      Instrument    `json:"instrument"`                // тикер
+	User            User          `json:"user"`                      // данные portfolio
+	TimeInForce     TimeInForce   `json:"timeInForce,omitempty"`     // Условие по времени действия заявки
+	IcebergFixed    int32         `json:"icebergFixed,omitempty"`    // Видимая постоянная часть айсберг-заявки в лотах
+	IcebergVariance float64       `json:"icebergVariance,omitempty"` // Амплитуда отклонения (в % от icebergFixed) случайной надбавки к видимой части айсберг-заявки. Только срочный рынок
+	Activate        *bool         `json:"activate,omitempty"`        // Флаг активной заявки (только stop/stopLimit). По умолчанию true
+}
+
+// ModifyOrderService изменить существующую заявку
+type ModifyOrderService struct {
+	c         *Client
+	orderID   string
+	order     ModifyOrderRequest
+	requestID string // ключ идемпотентности (X-ALOR-REQID)
+}
+
+// NewModifyOrderService изменить существующую заявку с номером orderID
+// по умолчанию лимитная заявка
+func (c *Client) NewModifyOrderService(orderID string) *ModifyOrderService {
+	user := User{
+		Portfolio: c.defaultPortfolio(), // проставим по умолчанию
+	}
+	ticker := Instrument{
+		Symbol:   "",
+		Exchange: c.Exchange,
+	}
+	return &ModifyOrderService{
+		c:       c,
+		orderID: orderID,
+		order: ModifyOrderRequest{
+			OrderType:   OrderTypeLimit,
+			TimeInForce: TimeInForceDAY, // сразу проставим "До конца дня"
+			Instrument:  ticker,
+			User:        user,
+		},
+	}
+}
+
+// OrderType установим тип изменяемой заявки (market limit stop stopLimit)
+func (s *ModifyOrderService) OrderType(orderType OrderType) *ModifyOrderService {
+	s.order.OrderType = orderType
+	return s
+}
+
+// Side установим направление ордера
+func (s *ModifyOrderService) Side(side SideType) *ModifyOrderService {
+	s.order.Side = side
+	return s
+}
+
+// Condition Условие срабатывания стоп/стоп-лимитной заявки
+func (s *ModifyOrderService) Condition(condition ConditionType) *ModifyOrderService {
+	s.order.Condition = condition
+	return s
+}
+
+// Comment установим комментарий
+func (s *ModifyOrderService) Comment(comment string) *ModifyOrderService {
+	s.order.Comment = comment
+	return s
+}
+
+// Symbol установим символ
+func (s *ModifyOrderService) Symbol(symbol string) *ModifyOrderService {
+	s.order.Instrument.Symbol = symbol
+	return s
+}
+
+// Board установим Код режима торгов
+func (s *ModifyOrderService) Board(board string) *ModifyOrderService {
+	s.order.Instrument.InstrumentGroup = board
+	return s
+}
+
+// Qty установим кол-во лот (Quantity)
+func (s *ModifyOrderService) Qty(quantity int32) *ModifyOrderService {
+	s.order.Quantity = quantity
+	return s
+}
+
+// Price установить цену. Для limit/stopLimit заявки
+func (s *ModifyOrderService) Price(price float64) *ModifyOrderService {
+	s.order.Price = price
+	return s
+}
+
+// TriggerPrice Стоп-цена
+func (s *ModifyOrderService) TriggerPrice(price float64) *ModifyOrderService {
+	s.order.TriggerPrice = price
+	return s
+}
+
+// StopEndUnixTime Срок действия stop/stopLimit заявки (UTC) в формате Unix Time seconds
+func (s *ModifyOrderService) StopEndUnixTime(stopEnd int64) *ModifyOrderService {
+	s.order.StopEndUnixTime = stopEnd
+	return s
+}
+
+// TimeInForce установим Условие по времени действия заявки
+func (s *ModifyOrderService) TimeInForce(timeInForce TimeInForce) *ModifyOrderService {
+	s.order.TimeInForce = timeInForce
+	return s
+}
+
+// Portfolio установим номер торгового счета
+func (s *ModifyOrderService) Portfolio(portfolio string) *ModifyOrderService {
+	s.order.User.Portfolio = portfolio
+	return s
+}
+
+// Activate флаг активной заявки (только stop/stopLimit)
+func (s *ModifyOrderService) Activate(activate bool) *ModifyOrderService {
+	s.order.Activate = &activate
+	return s
+}
+
+// IdempotencyKey установим ключ идемпотентности (X-ALOR-REQID)
+// Ключ используется во всех вызовах Do (для другой заявки задайте новый ключ).
+// По умолчанию ключ генерируется на каждый вызов Do
+func (s *ModifyOrderService) IdempotencyKey(key string) *ModifyOrderService {
+	s.requestID = key
+	return s
+}
+
+// Do послать команду на изменение заявки
+// при сетевой ошибке или сбое сервера запрос повторяется с тем же X-ALOR-REQID (RetryPolicy)
+// возвращает ID новой заявки
+func (s *ModifyOrderService) Do(ctx context.Context) (orderID string, err error) {
+	ctx, span := s.c.startSpan(ctx, "alor.ModifyOrder", orderAttributes(s.order.OrderType, s.order.Instrument.Symbol, s.order.Side, s.order.Quantity, s.order.User.Portfolio)...)
+	span.SetAttributes(orderIDAttribute(s.orderID))
+	defer func() {
+		endOrderSpan(span, orderID, err)
+		s.c.metrics.OrderResult("ModifyOrder", err)
+	}()
+
+	// значения по умолчанию проставим в копии: сервис можно использовать повторно
+	order := s.order
+	switch order.OrderType {
+	case OrderTypeMarket, OrderTypeLimit:
+	case OrderTypeStop, OrderTypeStopLimit:
+		if order.Activate == nil {
+			activate := true
+			order.Activate = &activate
+		}
+	default:
+		return "", fmt.Errorf("изменение заявки типа %q не поддерживается", order.OrderType)
+	}
+	queryURL, _ := url.Parse("/commandapi/warptrans/TRADE/v2/client/orders/actions")
+	queryURL.Path = path.Join(queryURL.Path, string(order.OrderType), s.orderID)
+	r := &request{
+		method:   http.MethodPut,
+		endpoint: queryURL.String(),
+		route:    "/commandapi/warptrans/TRADE/v2/client/orders/actions/" + string(order.OrderType) + "/{orderId}",
+	}
+
+	// в request.body надо записать order
+	buf := new(bytes.Buffer)
+	_ = json.NewEncoder(buf).Encode(&order)
+	r.body = buf
+
+	// Требуется уникальная случайная строка в качестве идентификатора запроса.
+	// Сгенерированный ключ действует в пределах одного вызова Do
+	requestID := s.requestID
+	if requestID == "" {
+		requestID = s.c.getRequestID()
+	}
+	r.setHeader("X-ALOR-REQID", requestID)
+	// установим заголовок json
+	r.setHeader("Content-Type", "application/json")
+
+	result := OrderResponse{}
+	data, err := s.c.callAPI(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	log.Debug("ModifyOrder", slog.Any("response body", string(data)))
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return "", err
+	}
+
+	return result.OrderNumber, nil
+}
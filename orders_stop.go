@@ -72,8 +72,10 @@ type OrderStopRequest struct {
 
 // CreateOrderStopService создать новую stop/stopLimit заявку
 type CreateOrderStopService struct {
-	c     *Client
-	order OrderStopRequest
+	c          *Client
+	order      OrderStopRequest
+	roundPrice bool   // при проверке (Validate) округлять цены до шага цены
+	requestID  string // ключ идемпотентности (X-ALOR-REQID)
 }
 
 // OrderType установим тип заявки
@@ -141,10 +143,24 @@ func (s *CreateOrderStopService) Activate(activate bool) *CreateOrderStopService
 	return s
 }
 
+// IdempotencyKey установим ключ идемпотентности (X-ALOR-REQID)
+// Ключ используется во всех вызовах Do (для другой заявки задайте новый ключ).
+// По умолчанию ключ генерируется на каждый вызов Do
+func (s *CreateOrderStopService) IdempotencyKey(key string) *CreateOrderStopService {
+	s.requestID = key
+	return s
+}
+
+// Request вернем сформированный запрос на создание stop/stopLimit заявки
+// (например для отправки через CommandConnection)
+func (s *CreateOrderStopService) Request() OrderStopRequest {
+	return s.order
+}
+
 // NewCreateOrderStopService создать новую stop/stopLimit заявку
 func (c *Client) NewCreateOrderStopService() *CreateOrderStopService {
 	user := User{
-		Portfolio: c.portfolioID, // проставим по умолчанию
+		Portfolio: c.defaultPortfolio(), // проставим по умолчанию
 	}
 	ticker := Instrument{
 		Symbol:   "",
@@ -166,14 +182,23 @@ func (c *Client) NewCreateOrderStopService() *CreateOrderStopService {
 }
 
 // Do послать команду на создание новой stop/stopLimit
+// при сетевой ошибке или сбое сервера запрос повторяется с тем же X-ALOR-REQID (RetryPolicy)
 // возвращает ID созданной заявки
-func (s *CreateOrderStopService) Do(ctx context.Context) (string, error) {
+func (s *CreateOrderStopService) Do(ctx context.Context) (orderID string, err error) {
+	ctx, span := s.c.startSpan(ctx, "alor.CreateOrderStop", orderAttributes(s.order.OrderType, s.order.Instrument.Symbol, s.order.Side, s.order.Quantity, s.order.User.Portfolio)...)
+	defer func() {
+		endOrderSpan(span, orderID, err)
+		s.c.metrics.OrderResult("CreateOrderStop", err)
+	}()
+
 	r := &request{
 		method:   http.MethodPost,
 		endpoint: "/commandapi/warptrans/TRADE/v2/client/orders/actions/stopLimit",
+		route:    "/commandapi/warptrans/TRADE/v2/client/orders/actions/stopLimit",
 	}
 	if s.order.OrderType == OrderTypeStop {
 		r.endpoint = "/commandapi/warptrans/TRADE/v2/client/orders/actions/stop"
+		r.route = r.endpoint
 	}
 
 	// в request.body надо записать order
@@ -182,7 +207,12 @@ func (s *CreateOrderStopService) Do(ctx context.Context) (string, error) {
 	r.body = buf
 
 	// Требуется уникальная случайная строка в качестве идентификатора запроса.
-	r.setHeader("X-ALOR-REQID", s.c.getRequestID())
+	// Сгенерированный ключ действует в пределах одного вызова Do
+	requestID := s.requestID
+	if requestID == "" {
+		requestID = s.c.getRequestID()
+	}
+	r.setHeader("X-ALOR-REQID", requestID)
 	// установим заголовок json
 	r.setHeader("Content-Type", "application/json")
 
@@ -0,0 +1,148 @@
+package alor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// reqIDServer отвечает 503 на каждый нечетный запрос и запоминает X-ALOR-REQID
+type reqIDServer struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (s *reqIDServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids = append(s.ids, r.Header.Get("X-ALOR-REQID"))
+	if len(s.ids)%2 == 1 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = w.Write([]byte(`{"message":"success","orderNumber":"18995978560"}`))
+}
+
+func TestOrderRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		// do создаст заявку; price меняется между вызовами Do, key задает IdempotencyKey
+		do  func(c *Client) func(price float64) error
+		key string
+	}{
+		{name: "CreateOrder", do: func(c *Client) func(float64) error {
+			s := c.NewCreateOrderService().Symbol("SBER").Side(SideTypeBuy).OrderType(OrderTypeLimit).Qty(1)
+			return func(price float64) error { _, err := s.Price(price).Do(context.Background()); return err }
+		}},
+		{name: "CreateOrderStop", do: func(c *Client) func(float64) error {
+			s := c.NewCreateOrderStopService().Symbol("SBER").Side(SideTypeSell).OrderType(OrderTypeStop).Qty(1)
+			return func(price float64) error { _, err := s.TriggerPrice(price).Do(context.Background()); return err }
+		}},
+		{name: "ModifyOrder", do: func(c *Client) func(float64) error {
+			s := c.NewModifyOrderService("18995978560").Symbol("SBER").Side(SideTypeBuy).OrderType(OrderTypeLimit).Qty(1)
+			return func(price float64) error { _, err := s.Price(price).Do(context.Background()); return err }
+		}},
+		{name: "CreateOrder IdempotencyKey", key: "my-key", do: func(c *Client) func(float64) error {
+			s := c.NewCreateOrderService().Symbol("SBER").Side(SideTypeBuy).OrderType(OrderTypeLimit).Qty(1).IdempotencyKey("my-key")
+			return func(price float64) error { _, err := s.Price(price).Do(context.Background()); return err }
+		}},
+		{name: "CreateOrderStop IdempotencyKey", key: "my-key", do: func(c *Client) func(float64) error {
+			s := c.NewCreateOrderStopService().Symbol("SBER").Side(SideTypeSell).OrderType(OrderTypeStop).Qty(1).IdempotencyKey("my-key")
+			return func(price float64) error { _, err := s.TriggerPrice(price).Do(context.Background()); return err }
+		}},
+		{name: "ModifyOrder IdempotencyKey", key: "my-key", do: func(c *Client) func(float64) error {
+			s := c.NewModifyOrderService("18995978560").Symbol("SBER").Side(SideTypeBuy).OrderType(OrderTypeLimit).Qty(1).IdempotencyKey("my-key")
+			return func(price float64) error { _, err := s.Price(price).Do(context.Background()); return err }
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &reqIDServer{}
+			c := newTestClient(t, srv.handle, WithRetryPolicy(fastRetry(2)))
+			do := tt.do(c)
+			if err := do(250); err != nil {
+				t.Fatal(err)
+			}
+			if err := do(251); err != nil {
+				t.Fatal(err)
+			}
+			if len(srv.ids) != 4 {
+				t.Fatalf("запросов %d, ожидали 4 (по повтору на каждый Do)", len(srv.ids))
+			}
+			for _, id := range srv.ids {
+				if id == "" {
+					t.Fatal("нет заголовка X-ALOR-REQID")
+				}
+			}
+			// повтор внутри Do идет с тем же ключом
+			if srv.ids[0] != srv.ids[1] || srv.ids[2] != srv.ids[3] {
+				t.Fatalf("ключ изменился при повторе: %v", srv.ids)
+			}
+			if tt.key != "" {
+				if srv.ids[0] != tt.key || srv.ids[2] != tt.key {
+					t.Fatalf("ключ %v, ожидали %s", srv.ids, tt.key)
+				}
+				return
+			}
+			// новый вызов Do (измененная заявка) идет с новым ключом
+			if srv.ids[0] == srv.ids[2] {
+				t.Fatalf("повторный Do с тем же ключом: %v", srv.ids)
+			}
+		})
+	}
+}
+
+func TestModifyOrder(t *testing.T) {
+	tests := []struct {
+		orderType    OrderType
+		path         string
+		stop         bool // stop/stopLimit: в запросе activate = true
+		triggerPrice float64
+	}{
+		{OrderTypeMarket, "/commandapi/warptrans/TRADE/v2/client/orders/actions/market/18995978560", false, 0},
+		{OrderTypeLimit, "/commandapi/warptrans/TRADE/v2/client/orders/actions/limit/18995978560", false, 0},
+		{OrderTypeStop, "/commandapi/warptrans/TRADE/v2/client/orders/actions/stop/18995978560", true, 249},
+		{OrderTypeStopLimit, "/commandapi/warptrans/TRADE/v2/client/orders/actions/stopLimit/18995978560", true, 249},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.orderType), func(t *testing.T) {
+			var (
+				method, path string
+				body         ModifyOrderRequest
+			)
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				method, path = r.Method, r.URL.Path
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				_, _ = w.Write([]byte(`{"message":"success","orderNumber":"18995978561"}`))
+			})
+			s := c.NewModifyOrderService("18995978560").OrderType(tt.orderType).
+				Symbol("SBER").Side(SideTypeBuy).Qty(2).Price(250).TriggerPrice(tt.triggerPrice)
+			for i := 0; i < 2; i++ {
+				orderID, err := s.Do(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if orderID != "18995978561" {
+					t.Fatalf("номер новой заявки %s", orderID)
+				}
+				if method != http.MethodPut || path != tt.path {
+					t.Fatalf("запрос %s %s, ожидали PUT %s", method, path, tt.path)
+				}
+				if body.Instrument.Symbol != "SBER" || body.Quantity != 2 || body.TriggerPrice != tt.triggerPrice || body.User.Portfolio != "D39004" {
+					t.Fatalf("тело запроса %+v", body)
+				}
+				// stop/stopLimit по умолчанию активна
+				if tt.stop != (body.Activate != nil && *body.Activate) {
+					t.Fatalf("activate %v", body.Activate)
+				}
+				body = ModifyOrderRequest{}
+			}
+			// Do не меняет настройки сервиса
+			if s.order.Activate != nil {
+				t.Fatalf("Do изменил Activate сервиса: %v", *s.order.Activate)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package alor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ValidationError ошибка проверки параметра заявки
+type ValidationError struct {
+	Field  string // Параметр заявки
+	Value  any    // Значение параметра
+	Reason string // Причина ошибки
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s=%v: %s", e.Field, e.Value, e.Reason)
+}
+
+// ValidationErrors список ошибок проверки заявки
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msg := make([]string, 0, len(e))
+	for _, v := range e {
+		msg = append(msg, v.Error())
+	}
+	return "<ValidationError> " + strings.Join(msg, "; ")
+}
+
+// add добавим ошибку
+func (e *ValidationErrors) add(field string, value any, reason string) {
+	*e = append(*e, ValidationError{Field: field, Value: value, Reason: reason})
+}
+
+// err вернем ошибку, если список не пустой
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// RoundPrice при проверке (Validate) округлять цену до шага цены
+// покупка округляется вниз, продажа вверх (в сторону менее выгодного исполнения)
+func (s *CreateOrderService) RoundPrice(round bool) *CreateOrderService {
+	s.roundPrice = round
+	return s
+}
+
+// Validate проверим заявку по параметрам инструмента (до отправки на сервер)
+// Параметры инструмента (по бирже заявки) кэшируются до начала следующей сессии. Если включено RoundPrice, то цена будет округлена до шага цены
+// возвращает ValidationErrors
+func (s *CreateOrderService) Validate(ctx context.Context) error {
+	errs := ValidationErrors{}
+	validateCommon(&errs, s.order.Side, s.order.Quantity, s.order.Instrument.Symbol, s.order.User.Portfolio)
+	if s.order.Instrument.Symbol == "" {
+		return errs.err()
+	}
+	sec, ok, err := s.c.getSecurityCached(ctx, s.order.Instrument.Exchange, s.order.Instrument.InstrumentGroup, s.order.Instrument.Symbol)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		errs.add("symbol", s.order.Instrument.Symbol, "инструмент не найден")
+		return errs.err()
+	}
+	if s.order.OrderType == OrderTypeLimit {
+		s.order.Price = validatePrice(&errs, "price", s.order.Price, sec, s.order.Side, s.roundPrice)
+	}
+	return errs.err()
+}
+
+// RoundPrice при проверке (Validate) округлять цены до шага цены
+// цена стоп-лимитной заявки: покупка округляется вниз, продажа вверх
+// стоп-цена округляется до ближайшего шага
+func (s *CreateOrderStopService) RoundPrice(round bool) *CreateOrderStopService {
+	s.roundPrice = round
+	return s
+}
+
+// Validate проверим stop/stopLimit заявку по параметрам инструмента (до отправки на сервер)
+// Параметры инструмента (по бирже заявки) кэшируются до начала следующей сессии. Если включено RoundPrice, то цены будут округлены до шага цены
+// возвращает ValidationErrors
+func (s *CreateOrderStopService) Validate(ctx context.Context) error {
+	errs := ValidationErrors{}
+	validateCommon(&errs, s.order.Side, s.order.Quantity, s.order.Instrument.Symbol, s.order.User.Portfolio)
+	if s.order.Condition == "" {
+		errs.add("condition", s.order.Condition, "не указано условие срабатывания")
+	}
+	if s.order.Instrument.Symbol == "" {
+		return errs.err()
+	}
+	sec, ok, err := s.c.getSecurityCached(ctx, s.order.Instrument.Exchange, s.order.Instrument.InstrumentGroup, s.order.Instrument.Symbol)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		errs.add("symbol", s.order.Instrument.Symbol, "инструмент не найден")
+		return errs.err()
+	}
+	s.order.TriggerPrice = validatePrice(&errs, "triggerPrice", s.order.TriggerPrice, sec, "", s.roundPrice)
+	if s.order.OrderType == OrderTypeStopLimit {
+		s.order.Price = validatePrice(&errs, "price", s.order.Price, sec, s.order.Side, s.roundPrice)
+	}
+	return errs.err()
+}
+
+// validateCommon общие проверки для всех типов заявок
+func validateCommon(errs *ValidationErrors, side SideType, qty int32, symbol, portfolio string) {
+	if side != SideTypeBuy && side != SideTypeSell {
+		errs.add("side", side, "не верное направление сделки")
+	}
+	if qty <= 0 {
+		errs.add("quantity", qty, "кол-во лот должно быть больше нуля")
+	}
+	if symbol == "" {
+		errs.add("symbol", symbol, "не указан инструмент")
+	}
+	if portfolio == "" {
+		errs.add("portfolio", portfolio, "не указан номер счета")
+	}
+}
+
+// validatePrice проверим цену: больше нуля, кратна шагу цены, внутри лимитов PriceMin/PriceMax
+// если round = true, то цена округляется до шага цены. Вернем (возможно округленную) цену
+func validatePrice(errs *ValidationErrors, field string, price float64, sec Security, side SideType, round bool) float64 {
+	if price <= 0 {
+		errs.add(field, price, "цена должна быть больше нуля")
+		return price
+	}
+	if sec.MinStep > 0 && !onStep(price, sec.MinStep) {
+		if round {
+			price = roundToStep(price, sec.MinStep, side)
+		} else {
+			errs.add(field, price, fmt.Sprintf("цена не кратна шагу цены %v", sec.MinStep))
+		}
+	}
+	if sec.PriceMin > 0 && price < sec.PriceMin {
+		errs.add(field, price, fmt.Sprintf("цена меньше минимальной %v", sec.PriceMin))
+	}
+	if sec.PriceMax > 0 && price > sec.PriceMax {
+		errs.add(field, price, fmt.Sprintf("цена больше максимальной %v", sec.PriceMax))
+	}
+	return price
+}
+
+// onStep цена кратна шагу цены
+func onStep(price, step float64) bool {
+	n := price / step
+	return math.Abs(n-math.Round(n)) < 1e-9
+}
+
+// roundToStep округлим цену до шага цены
+// покупка вниз, продажа вверх, без направления до ближайшего
+func roundToStep(price, step float64, side SideType) float64 {
+	n := price / step
+	switch {
+	case math.Abs(n-math.Round(n)) < 1e-9:
+		n = math.Round(n)
+	case side == SideTypeBuy:
+		n = math.Floor(n)
+	case side == SideTypeSell:
+		n = math.Ceil(n)
+	default:
+		n = math.Round(n)
+	}
+	// уберем погрешность float (0.1*3 = 0.30000000000000004)
+	pow := math.Pow10(stepDecimals(step))
+	return math.Round(n*step*pow) / pow
+}
+
+// stepDecimals кол-во знаков после запятой у шага цены
+func stepDecimals(step float64) int {
+	s := strconv.FormatFloat(step, 'f', -1, 64)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
@@ -0,0 +1,184 @@
+package alor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// securityServer отдает параметры инструмента SBER (шаг 0.01, лимиты 200..300) на MOEX и SPBX
+// и считает запросы по пути
+type securityServer struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (s *securityServer) handler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.paths = append(s.paths, r.URL.Path)
+	s.mu.Unlock()
+	switch r.URL.Path {
+	case "/md/v2/Securities/MOEX/SBER":
+		_, _ = w.Write([]byte(`{"symbol":"SBER","exchange":"MOEX","lotsize":10,"minstep":0.01,"priceMin":200,"priceMax":300}`))
+	case "/md/v2/Securities/SPBX/SBER":
+		_, _ = w.Write([]byte(`{"symbol":"SBER","exchange":"SPBX","lotsize":1,"minstep":0.5}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *securityServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.paths...)
+}
+
+func TestCreateOrderValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		side      SideType
+		qty       int32
+		price     float64
+		round     bool
+		wantPrice float64
+		wantField []string // поля с ошибками
+	}{
+		{name: "ок", side: SideTypeBuy, qty: 1, price: 250.15, wantPrice: 250.15},
+		{name: "не кратна шагу", side: SideTypeBuy, qty: 1, price: 250.153, wantPrice: 250.153, wantField: []string{"price"}},
+		{name: "округление покупки вниз", side: SideTypeBuy, qty: 1, price: 250.159, round: true, wantPrice: 250.15},
+		{name: "округление продажи вверх", side: SideTypeSell, qty: 1, price: 250.151, round: true, wantPrice: 250.16},
+		{name: "погрешность float", side: SideTypeSell, qty: 1, price: 250.3, round: true, wantPrice: 250.3},
+		{name: "ниже минимальной", side: SideTypeBuy, qty: 1, price: 199.99, wantPrice: 199.99, wantField: []string{"price"}},
+		{name: "выше максимальной", side: SideTypeSell, qty: 1, price: 300.01, wantPrice: 300.01, wantField: []string{"price"}},
+		{name: "округление до лимита", side: SideTypeSell, qty: 1, price: 299.999, round: true, wantPrice: 300},
+		{name: "ноль лотов", side: SideTypeBuy, qty: 0, price: 250, wantPrice: 250, wantField: []string{"quantity"}},
+		{name: "отрицательное кол-во лотов", side: SideTypeSell, qty: -10, price: 250, wantPrice: 250, wantField: []string{"quantity"}},
+		{name: "нет направления", qty: 1, price: 250, wantPrice: 250, wantField: []string{"side"}},
+		{name: "несколько ошибок", side: SideTypeBuy, qty: 0, price: 0, wantField: []string{"quantity", "price"}},
+	}
+	srv := &securityServer{}
+	c := newTestClient(t, srv.handler)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := c.NewCreateOrderService().Portfolio("D39004").Exchange("MOEX").Symbol("SBER").
+				OrderType(OrderTypeLimit).Side(tt.side).Qty(tt.qty).Price(tt.price).RoundPrice(tt.round)
+			err := s.Validate(context.Background())
+			if got := s.Request().Price; got != tt.wantPrice {
+				t.Errorf("цена %v, ожидали %v", got, tt.wantPrice)
+			}
+			if len(tt.wantField) == 0 {
+				if err != nil {
+					t.Fatalf("ошибка: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrValidation) {
+				t.Fatalf("ошибка %v, ожидали ErrValidation", err)
+			}
+			var verrs ValidationErrors
+			if !errors.As(err, &verrs) {
+				t.Fatalf("ошибка %T, ожидали ValidationErrors", err)
+			}
+			fields := make([]string, 0, len(verrs))
+			for _, v := range verrs {
+				fields = append(fields, v.Field)
+			}
+			if !equalSlices(fields, tt.wantField) {
+				t.Errorf("поля с ошибками %v, ожидали %v", fields, tt.wantField)
+			}
+		})
+	}
+	// параметры инструмента запрошены один раз
+	if n := len(srv.requests()); n != 1 {
+		t.Errorf("запросов параметров инструмента %d, ожидали 1", n)
+	}
+}
+
+func TestCreateOrderStopValidate(t *testing.T) {
+	srv := &securityServer{}
+	c := newTestClient(t, srv.handler)
+	s := c.NewCreateOrderStopService().Portfolio("D39004").Symbol("SBER").
+		OrderType(OrderTypeStopLimit).Condition(ConditionMore).Side(SideTypeBuy).Qty(1).
+		TriggerPrice(250.126).Price(250.129).RoundPrice(true)
+	if err := s.Validate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// стоп-цена до ближайшего шага, цена покупки вниз
+	if got := s.Request(); got.TriggerPrice != 250.13 || got.Price != 250.12 {
+		t.Errorf("стоп-цена %v, цена %v, ожидали 250.13 и 250.12", got.TriggerPrice, got.Price)
+	}
+
+	err := c.NewCreateOrderStopService().Portfolio("D39004").Symbol("SBER").
+		OrderType(OrderTypeStop).Side(SideTypeSell).Qty(1).TriggerPrice(350).Validate(context.Background())
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 2 || verrs[0].Field != "condition" || verrs[1].Field != "triggerPrice" {
+		t.Errorf("ошибка %v, ожидали condition и triggerPrice", err)
+	}
+}
+
+func TestValidateSecurityCacheExchange(t *testing.T) {
+	srv := &securityServer{}
+	c := newTestClient(t, srv.handler)
+	ctx := context.Background()
+	validate := func(exchange string, price float64) error {
+		return c.NewCreateOrderService().Portfolio("D39004").Exchange(exchange).Symbol("SBER").
+			OrderType(OrderTypeLimit).Side(SideTypeBuy).Qty(1).Price(price).Validate(ctx)
+	}
+	// 250.01 на MOEX кратна шагу 0.01, на SPBX не кратна шагу 0.5
+	if err := validate("MOEX", 250.01); err != nil {
+		t.Fatalf("MOEX: %v", err)
+	}
+	if err := validate("SPBX", 250.01); !errors.Is(err, ErrValidation) {
+		t.Fatalf("SPBX: ошибка %v, ожидали ErrValidation", err)
+	}
+	if err := validate("MOEX", 250.01); err != nil {
+		t.Fatalf("MOEX повторно: %v", err)
+	}
+	want := []string{"/md/v2/Securities/MOEX/SBER", "/md/v2/Securities/SPBX/SBER"}
+	if got := srv.requests(); !equalSlices(got, want) {
+		t.Fatalf("запросы %v, ожидали %v", got, want)
+	}
+
+	// устаревшая запись запрашивается снова
+	c.secMu.Lock()
+	for key, cached := range c.securities {
+		cached.expires = time.Now().Add(-time.Second)
+		c.securities[key] = cached
+	}
+	c.secMu.Unlock()
+	if err := validate("MOEX", 250.01); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.requests(); len(got) != 3 || got[2] != want[0] {
+		t.Fatalf("запросы %v, ожидали повторный запрос %s", got, want[0])
+	}
+}
+
+func TestSecurityExpires(t *testing.T) {
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2024, time.March, day, hour, min, 0, 0, TzMsk)
+	}
+	tests := []struct {
+		loaded, want time.Time
+	}{
+		{at(4, 11, 0), at(4, 12, 0)},  // посреди сессии: TTL
+		{at(4, 13, 30), at(4, 14, 5)}, // промежуточный клиринг
+		{at(4, 18, 45), at(4, 19, 5)}, // основной клиринг
+		{at(4, 23, 30), at(5, 0, 30)}, // ночью: TTL
+		{at(5, 6, 20), at(5, 6, 50)},  // утренняя сессия следующего дня
+		{at(4, 19, 5), at(4, 20, 5)},  // ровно в начале сессии: TTL
+	}
+	for _, tt := range tests {
+		if got := securityExpires(tt.loaded); !got.Equal(tt.want) {
+			t.Errorf("securityExpires(%v) = %v, ожидали %v", tt.loaded, got.In(TzMsk), tt.want)
+		}
+	}
+	// время не в MSK
+	loaded := at(4, 18, 45).UTC()
+	if got := securityExpires(loaded); !got.Equal(at(4, 19, 5)) {
+		t.Errorf("securityExpires(%v) = %v, ожидали 19:05 MSK", loaded, got)
+	}
+}
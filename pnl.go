@@ -0,0 +1,435 @@
+package alor
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CostMethod метод учета стоимости позиции
+type CostMethod string
+
+const (
+	CostMethodFIFO    CostMethod = "fifo"    // Первым купил - первым продал
+	CostMethodAverage CostMethod = "average" // По средней цене
+)
+
+// время ожидания загрузки параметров инструмента при расчете P&L
+const pnlSecurityTimeout = 10 * time.Second
+
+// сколько хранить ID учтенных сделок (для пропуска повторов)
+const pnlTradeTTL = 24 * time.Hour
+
+// PnL прибыль/убыток по инструменту
+type PnL struct {
+	Symbol          string  // Тикер
+	Qty             float64 // Текущая позиция (штуки). Короткая позиция с минусом
+	AvgPrice        float64 // Средняя цена открытой позиции
+	LastPrice       float64 // Последняя цена
+	Realized        float64 // Зафиксированная прибыль в пунктах цены (разница цен * кол-во штук)
+	Unrealized      float64 // Текущая (не зафиксированная) прибыль в пунктах цены
+	RealizedMoney   float64 // Зафиксированная прибыль в валюте счета
+	UnrealizedMoney float64 // Текущая прибыль в валюте счета
+}
+
+// лот открытой позиции
+type pnlLot struct {
+	qty   float64 // кол-во штук (со знаком)
+	price float64 // цена открытия
+}
+
+// учет по одному инструменту
+type pnlBook struct {
+	lots       []pnlLot
+	realized   float64
+	lastPrice  float64
+	lotSize    float64 // размер лота
+	pointValue float64 // стоимость изменения цены на 1 для одной штуки (PriceStep / MinStep)
+}
+
+func (b *pnlBook) qty() float64 {
+	var qty float64
+	for _, l := range b.lots {
+		qty += l.qty
+	}
+	return qty
+}
+
+// apply учтем сделку: qty штук со знаком (покупка +, продажа -)
+func (b *pnlBook) apply(qty, price float64, method CostMethod) {
+	// закрываем открытые лоты (противоположного направления)
+	for len(b.lots) > 0 && qty != 0 && math.Signbit(b.lots[0].qty) != math.Signbit(qty) {
+		lot := &b.lots[0]
+		closed := math.Min(math.Abs(lot.qty), math.Abs(qty))
+		if lot.qty > 0 {
+			b.realized += (price - lot.price) * closed
+			lot.qty -= closed
+			qty += closed
+		} else {
+			b.realized += (lot.price - price) * closed
+			lot.qty += closed
+			qty -= closed
+		}
+		if lot.qty == 0 {
+			b.lots = b.lots[1:]
+		}
+	}
+	if qty == 0 {
+		return
+	}
+	b.lots = append(b.lots, pnlLot{qty: qty, price: price})
+	// по средней цене держим один лот
+	if method == CostMethodAverage && len(b.lots) > 1 {
+		var total, value float64
+		for _, l := range b.lots {
+			total += l.qty
+			value += l.qty * l.price
+		}
+		b.lots = []pnlLot{{qty: total, price: value / total}}
+	}
+}
+
+// reset заменим открытые лоты одной позицией (после сверки с сервером)
+func (b *pnlBook) reset(qty, price float64) {
+	b.lots = b.lots[:0]
+	if qty != 0 {
+		b.lots = append(b.lots, pnlLot{qty: qty, price: price})
+	}
+}
+
+func (b *pnlBook) snapshot(symbol string) PnL {
+	p := PnL{
+		Symbol:    symbol,
+		LastPrice: b.lastPrice,
+		Realized:  b.realized,
+	}
+	var value float64
+	for _, l := range b.lots {
+		p.Qty += l.qty
+		value += l.qty * l.price
+		if b.lastPrice != 0 {
+			p.Unrealized += (b.lastPrice - l.price) * l.qty
+		}
+	}
+	if p.Qty != 0 {
+		p.AvgPrice = value / p.Qty
+	}
+	p.RealizedMoney = p.Realized * b.pointValue
+	p.UnrealizedMoney = p.Unrealized * b.pointValue
+	return p
+}
+
+// PnLEngine расчет прибыли/убытка в реальном времени
+// Учитывает сделки по портфелю (SubscribeTrades) и котировки (SubscribeQuotes).
+// Периодически сверяет позиции с сервером (GetPositions)
+type PnLEngine struct {
+	c           *Client
+	portfolio   string
+	method      CostMethod
+	mu          sync.Mutex
+	books       map[string]*pnlBook
+	trades      map[string]time.Time // ID уже учтенных сделок и время их получения
+	pruned      time.Time            // время последней очистки trades
+	ctx         context.Context      // контекст для подписки на котировки новых инструментов
+	cancel      context.CancelFunc   // остановка подписок и сверки (Stop)
+	unregister  []func()             // отмена регистрации OnTrade и OnQuote в клиенте
+	subscribed  map[string]bool      // инструменты, на котировки которых подписались
+	reconcileMu sync.Mutex           // сверки с сервером выполняются по одной
+	pending     map[string][]pnlLot  // сделки, полученные во время сверки (nil = сверки нет)
+}
+
+// NewPnLEngine создать расчет прибыли/убытка по портфелю
+func (c *Client) NewPnLEngine(portfolio string, method CostMethod) *PnLEngine {
+	if method == "" {
+		method = CostMethodFIFO
+	}
+	return &PnLEngine{
+		c:          c,
+		portfolio:  portfolio,
+		method:     method,
+		books:      make(map[string]*pnlBook),
+		trades:     make(map[string]time.Time),
+		subscribed: make(map[string]bool),
+	}
+}
+
+// Start загрузим текущие позиции, подпишемся на сделки и котировки
+// reconcileInterval период сверки позиций с сервером (0 = без сверки)
+// Повторный вызов Start (до Stop) ничего не делает
+func (e *PnLEngine) Start(ctx context.Context, reconcileInterval time.Duration) error {
+	e.mu.Lock()
+	if e.cancel != nil {
+		e.mu.Unlock()
+		return nil
+	}
+	ctx, e.cancel = context.WithCancel(ctx)
+	e.ctx = ctx
+	e.unregister = []func(){
+		e.c.RegisterOnTrade(e.OnTrade),
+		e.c.RegisterOnQuote(e.OnQuote),
+	}
+	e.mu.Unlock()
+
+	if err := e.Reconcile(ctx); err != nil {
+		e.Stop()
+		return err
+	}
+	if err := e.c.SubscribeTrades(ctx, e.portfolio, WithSkipHistory(true)); err != nil {
+		e.Stop()
+		return err
+	}
+	if reconcileInterval > 0 {
+		go e.reconciler(ctx, reconcileInterval)
+	}
+	return nil
+}
+
+// Stop перестанем получать сделки и котировки, остановим сверку с сервером
+// Учет по инструментам сохраняется: после Stop можно снова вызвать Start
+func (e *PnLEngine) Stop() {
+	e.mu.Lock()
+	cancel, unregister := e.cancel, e.unregister
+	e.cancel, e.unregister, e.ctx = nil, nil, nil
+	e.subscribed = make(map[string]bool)
+	e.mu.Unlock()
+
+	for _, f := range unregister {
+		f()
+	}
+	if cancel != nil {
+		cancel()
+	}
+}
+
+func (e *PnLEngine) reconciler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Reconcile(ctx); err != nil {
+				log.Error("PnLEngine.Reconcile", "portfolio", e.portfolio, "err", err.Error())
+			}
+		}
+	}
+}
+
+// prepareBook создадим учет по инструменту, если его еще нет
+// параметры инструмента (GetSecurity) загружаются без блокировки
+func (e *PnLEngine) prepareBook(symbol string) {
+	e.mu.Lock()
+	_, ok := e.books[symbol]
+	e.mu.Unlock()
+	if ok {
+		return
+	}
+	b := newPnLBook(e.c, symbol)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.books[symbol]; ok {
+		return
+	}
+	e.books[symbol] = b
+	e.subscribeQuotes(symbol)
+}
+
+// newPnLBook учет по инструменту с параметрами инструмента (размер лота, стоимость шага цены)
+func newPnLBook(c *Client, symbol string) *pnlBook {
+	b := &pnlBook{lotSize: 1, pointValue: 1}
+	ctx, cancel := context.WithTimeout(context.Background(), pnlSecurityTimeout)
+	defer cancel()
+	sec, ok, err := c.getSecurityCached(ctx, "", "", symbol)
+	if err != nil {
+		log.Error("PnLEngine: ошибка получения параметров инструмента", "symbol", symbol, "err", err.Error())
+	}
+	if ok {
+		if sec.LotSize > 0 {
+			b.lotSize = sec.LotSize
+		}
+		// для фьючерсов стоимость шага цены отличается от шага цены
+		if sec.MinStep > 0 && sec.PriceStep > 0 {
+			b.pointValue = sec.PriceStep / sec.MinStep
+		}
+	}
+	return b
+}
+
+// book вернем учет по инструменту (создается заранее в prepareBook). Вызывать под блокировкой
+func (e *PnLEngine) book(symbol string) *pnlBook {
+	b, ok := e.books[symbol]
+	if !ok {
+		b = &pnlBook{lotSize: 1, pointValue: 1}
+		e.books[symbol] = b
+		e.subscribeQuotes(symbol)
+	}
+	return b
+}
+
+// pruneTrades удалим ID сделок старше pnlTradeTTL. Вызывать под блокировкой
+func (e *PnLEngine) pruneTrades(now time.Time) {
+	if now.Sub(e.pruned) < time.Hour {
+		return
+	}
+	e.pruned = now
+	for id, t := range e.trades {
+		if now.Sub(t) > pnlTradeTTL {
+			delete(e.trades, id)
+		}
+	}
+}
+
+// subscribeQuotes подпишемся на котировки нового инструмента. Вызывать под блокировкой
+func (e *PnLEngine) subscribeQuotes(symbol string) {
+	if e.ctx == nil || e.subscribed[symbol] {
+		return
+	}
+	e.subscribed[symbol] = true
+	go func() {
+		if err := e.c.SubscribeQuotes(e.ctx, symbol); err != nil {
+			log.Error("PnLEngine: ошибка подписки на котировки", "symbol", symbol, "err", err.Error())
+		}
+	}()
+}
+
+// OnTrade учтем сделку по портфелю (повторно присланные сделки пропускаются)
+func (e *PnLEngine) OnTrade(trade Trade) {
+	e.prepareBook(trade.Symbol)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if trade.Id != "" {
+		if _, ok := e.trades[trade.Id]; ok {
+			return
+		}
+		now := time.Now()
+		e.trades[trade.Id] = now
+		e.pruneTrades(now)
+	}
+	b := e.book(trade.Symbol)
+	qty := float64(trade.QtyUnits)
+	if qty == 0 {
+		qty = float64(trade.Qty) * b.lotSize
+	}
+	if trade.Side == string(SideTypeSell) {
+		qty = -qty
+	}
+	b.apply(qty, trade.Price, e.method)
+	b.lastPrice = trade.Price
+	// идет сверка: запомним сделку, чтобы не потерять ее при замене позиции серверной
+	if e.pending != nil {
+		e.pending[trade.Symbol] = append(e.pending[trade.Symbol], pnlLot{qty: qty, price: trade.Price})
+	}
+}
+
+// OnQuote обновим последнюю цену по инструменту
+func (e *PnLEngine) OnQuote(quote Quote) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	b, ok := e.books[quote.Symbol]
+	if !ok || quote.LastPrice == 0 {
+		return
+	}
+	b.lastPrice = quote.LastPrice
+}
+
+// Reconcile сверим позиции с сервером (GetPositions)
+// при расхождении открытая позиция заменяется серверной (по средней цене), зафиксированная прибыль сохраняется.
+// Сделки, полученные во время сверки, не теряются: позиция сверяется и с ними, и без них
+// (сервер мог учесть сделку или еще нет), а при замене позиции они учитываются поверх серверной
+func (e *PnLEngine) Reconcile(ctx context.Context) error {
+	e.reconcileMu.Lock()
+	defer e.reconcileMu.Unlock()
+
+	e.mu.Lock()
+	e.pending = make(map[string][]pnlLot)
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.pending = nil
+		e.mu.Unlock()
+	}()
+
+	positions, err := e.c.GetPositions(ctx, e.portfolio)
+	if err != nil {
+		return err
+	}
+	for _, p := range positions {
+		if !p.IsCurrency {
+			e.prepareBook(p.Symbol)
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pruneTrades(time.Now())
+
+	seen := make(map[string]bool)
+	for _, p := range positions {
+		if p.IsCurrency {
+			continue
+		}
+		seen[p.Symbol] = true
+		e.reconcileBook(p.Symbol, e.book(p.Symbol), p.QtyUnits, p.AvgPrice)
+	}
+	for symbol, b := range e.books {
+		if !seen[symbol] {
+			e.reconcileBook(symbol, b, 0, 0)
+		}
+	}
+	return nil
+}
+
+// reconcileBook сверим позицию по инструменту с серверной. Вызывать под блокировкой
+func (e *PnLEngine) reconcileBook(symbol string, b *pnlBook, qty, avgPrice float64) {
+	pending := e.pending[symbol]
+	before := b.qty()
+	for _, t := range pending {
+		before -= t.qty
+	}
+	if b.qty() == qty || before == qty {
+		return
+	}
+	log.Warn("PnLEngine: расхождение позиции с сервером", "symbol", symbol, "engine", b.qty(), "server", qty)
+	b.reset(qty, avgPrice)
+	for _, t := range pending {
+		b.apply(t.qty, t.price, e.method)
+	}
+}
+
+// PnL вернем прибыль/убыток по инструменту
+func (e *PnLEngine) PnL(symbol string) (PnL, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	b, ok := e.books[symbol]
+	if !ok {
+		return PnL{Symbol: symbol}, false
+	}
+	return b.snapshot(symbol), true
+}
+
+// All вернем прибыль/убыток по всем инструментам (сортировка по тикеру)
+func (e *PnLEngine) All() []PnL {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	result := make([]PnL, 0, len(e.books))
+	for symbol, b := range e.books {
+		result = append(result, b.snapshot(symbol))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Symbol < result[j].Symbol
+	})
+	return result
+}
+
+// Total вернем суммарную прибыль/убыток в валюте счета
+func (e *PnLEngine) Total() (realized, unrealized float64) {
+	for _, p := range e.All() {
+		realized += p.RealizedMoney
+		unrealized += p.UnrealizedMoney
+	}
+	return realized, unrealized
+}
@@ -0,0 +1,173 @@
+package alor
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPnLEngineSecurityLoadedWithoutLock(t *testing.T) {
+	release := make(chan struct{})
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/md/v2/Securities") {
+			<-release
+			_, _ = w.Write([]byte(`{"symbol":"SBER","lotsize":10,"minstep":0.01,"pricestep":0.01}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	e := c.NewPnLEngine("D39004", CostMethodFIFO)
+	e.books["GAZP"] = &pnlBook{lotSize: 1, pointValue: 1}
+
+	done := make(chan struct{})
+	go func() {
+		e.OnTrade(Trade{Id: "1", Symbol: "SBER", Qty: 1, Price: 100, Side: string(SideTypeBuy)})
+		close(done)
+	}()
+
+	// пока грузятся параметры SBER, остальные методы не блокируются
+	quoted := make(chan struct{})
+	go func() {
+		e.OnQuote(Quote{Symbol: "GAZP", LastPrice: 150})
+		e.PnL("GAZP")
+		close(quoted)
+	}()
+	select {
+	case <-quoted:
+	case <-time.After(time.Second):
+		t.Fatal("OnQuote заблокирован загрузкой параметров инструмента")
+	}
+
+	close(release)
+	<-done
+	p, ok := e.PnL("SBER")
+	if !ok || p.Qty != 10 {
+		t.Fatalf("позиция SBER = %+v, ожидали 10 штук", p)
+	}
+}
+
+func TestPnLEngineTradeDedupe(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	e := c.NewPnLEngine("D39004", CostMethodFIFO)
+	e.books["SBER"] = &pnlBook{lotSize: 10, pointValue: 1}
+
+	buy := Trade{Id: "1", Symbol: "SBER", Qty: 1, Price: 100, Side: string(SideTypeBuy)}
+	e.OnTrade(buy)
+	e.OnTrade(buy)
+	e.OnTrade(Trade{Id: "2", Symbol: "SBER", Qty: 1, Price: 110, Side: string(SideTypeSell)})
+
+	p, _ := e.PnL("SBER")
+	if p.Qty != 0 || p.Realized != 100 {
+		t.Fatalf("PnL = %+v, ожидали Qty 0, Realized 100", p)
+	}
+
+	// старые ID сделок удаляются
+	e.mu.Lock()
+	e.trades["old"] = time.Now().Add(-2 * pnlTradeTTL)
+	e.pruned = time.Time{}
+	e.pruneTrades(time.Now())
+	_, old := e.trades["old"]
+	_, recent := e.trades["2"]
+	e.mu.Unlock()
+	if old || !recent {
+		t.Fatalf("очистка trades: old=%v recent=%v", old, recent)
+	}
+}
+
+func TestPnLEngineStartStop(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/positions") {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}, WithWSURL("ws://127.0.0.1:1")) // подписки не подключатся: проверяем регистрацию обработчиков
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e := c.NewPnLEngine("D39004", CostMethodFIFO)
+	e.books["SBER"] = &pnlBook{lotSize: 1, pointValue: 1}
+
+	for i := 0; i < 2; i++ {
+		if err := e.Start(ctx, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.PublishTrade(Trade{Id: "1", Symbol: "SBER", QtyUnits: 10, Price: 100, Side: string(SideTypeBuy)})
+	c.PublishQuotes(Quote{Symbol: "SBER", LastPrice: 105})
+	if p, _ := e.PnL("SBER"); p.Qty != 10 || p.LastPrice != 105 {
+		t.Fatalf("после двух Start: %+v, ожидали Qty 10, LastPrice 105", p)
+	}
+
+	// после Stop сделки и котировки не учитываются
+	e.Stop()
+	c.PublishTrade(Trade{Id: "2", Symbol: "SBER", QtyUnits: 10, Price: 100, Side: string(SideTypeBuy)})
+	c.PublishQuotes(Quote{Symbol: "SBER", LastPrice: 110})
+	if p, _ := e.PnL("SBER"); p.Qty != 10 || p.LastPrice != 105 {
+		t.Fatalf("после Stop: %+v", p)
+	}
+
+	// повторный Start после Stop (позиции на сервере нет: учет сбрасывается сверкой)
+	if err := e.Start(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer e.Stop()
+	c.PublishTrade(Trade{Id: "3", Symbol: "SBER", QtyUnits: 5, Price: 100, Side: string(SideTypeBuy)})
+	if p, _ := e.PnL("SBER"); p.Qty != 5 {
+		t.Fatalf("после повторного Start: %+v, ожидали Qty 5", p)
+	}
+}
+
+func TestPnLEngineTradeDuringReconcile(t *testing.T) {
+	tests := []struct {
+		name   string
+		server string // позиция на сервере
+		want   float64
+	}{
+		{"сервер еще не учел сделку", `[{"symbol":"SBER","qtyUnits":0,"avgPrice":0}]`, 10},
+		{"сервер учел сделку", `[{"symbol":"SBER","qtyUnits":10,"avgPrice":100}]`, 10},
+		{"позиции нет на сервере", `[]`, 10},
+		{"расхождение", `[{"symbol":"SBER","qtyUnits":5,"avgPrice":90}]`, 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entered := make(chan struct{})
+			release := make(chan struct{})
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasSuffix(r.URL.Path, "/positions") {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				close(entered)
+				<-release
+				_, _ = w.Write([]byte(tt.server))
+			})
+			e := c.NewPnLEngine("D39004", CostMethodFIFO)
+			e.books["SBER"] = &pnlBook{lotSize: 1, pointValue: 1}
+
+			done := make(chan error, 1)
+			go func() { done <- e.Reconcile(context.Background()) }()
+			<-entered
+			// сделка пришла, пока сервер готовит ответ
+			e.OnTrade(Trade{Id: "1", Symbol: "SBER", QtyUnits: 10, Price: 100, Side: string(SideTypeBuy)})
+			close(release)
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+			if p, _ := e.PnL("SBER"); p.Qty != tt.want {
+				t.Fatalf("позиция %v, ожидали %v", p.Qty, tt.want)
+			}
+			// после сверки сделки больше не запоминаются
+			e.OnTrade(Trade{Id: "2", Symbol: "SBER", QtyUnits: 1, Price: 100, Side: string(SideTypeSell)})
+			e.mu.Lock()
+			pending := e.pending
+			e.mu.Unlock()
+			if pending != nil {
+				t.Fatalf("сделки во время сверки не очищены: %+v", pending)
+			}
+		})
+	}
+}
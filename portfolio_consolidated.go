@@ -0,0 +1,233 @@
+package alor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Market рынок (тип торгового счета)
+type Market string
+
+const (
+	MarketStock Market = "stock" // Фондовый рынок
+	MarketForts Market = "forts" // Срочный рынок (ФОРТС)
+	MarketFx    Market = "fx"    // Валютный рынок
+)
+
+// MarketPortfolio данные портфеля одного рынка
+type MarketPortfolio struct {
+	Market    Market              // Рынок
+	Portfolio string              // Номер счета
+	Summary   Portfolio           // Информация о портфеле
+	Positions []Position          // Позиции по инструментам
+	Cash      []Position          // Денежные остатки (IsCurrency)
+	FortsRisk *PortfolioFortsRisk // Риски срочного рынка (только для ФОРТС)
+	Err       error               // Ошибка получения данных
+}
+
+// ConsolidatedPortfolio сводный портфель по всем рынкам
+type ConsolidatedPortfolio struct {
+	Markets          []MarketPortfolio  // Данные по рынкам (stock, forts, fx)
+	Positions        []Position         // Позиции по инструментам всех рынков (одинаковые инструменты объединены)
+	Cash             map[string]float64 // Денежные остатки по валютам (сумма по всем рынкам)
+	Evaluation       float64            // Ликвидный портфель
+	LiquidationValue float64            // Оценка портфеля
+	BuyingPower      float64            // Покупательская способность
+	InitialMargin    float64            // Маржа
+	Profit           float64            // Прибыль за сегодня
+	Commission       float64            // Суммарная комиссия
+	UnrealisedPl     float64            // Прибыль/убыток по позициям в валюте расчетов
+	VarMargin        float64            // Вариационная маржа (ФОРТС)
+}
+
+// Market вернем данные выбранного рынка
+func (p ConsolidatedPortfolio) Market(market Market) (MarketPortfolio, bool) {
+	for _, m := range p.Markets {
+		if m.Market == market {
+			return m, true
+		}
+	}
+	return MarketPortfolio{}, false
+}
+
+// PortfolioErrors ошибки получения данных по отдельным рынкам
+type PortfolioErrors map[Market]error
+
+func (e PortfolioErrors) Error() string {
+	markets := make([]string, 0, len(e))
+	for m := range e {
+		markets = append(markets, string(m))
+	}
+	sort.Strings(markets)
+	s := make([]string, 0, len(markets))
+	for _, m := range markets {
+		s = append(s, fmt.Sprintf("%s: %v", m, e[Market(m)]))
+	}
+	return "ошибка получения портфеля: " + strings.Join(s, "; ")
+}
+
+// Unwrap для errors.Is / errors.As
+func (e PortfolioErrors) Unwrap() []error {
+	result := make([]error, 0, len(e))
+	for _, err := range e {
+		result = append(result, err)
+	}
+	return result
+}
+
+// markets вернем настроенные счета по рынкам (одинаковые номера счетов запрашиваются один раз)
+func (c *Client) markets() []MarketPortfolio {
+	c.portfolioMu.RLock()
+	portfolios := []MarketPortfolio{
+		{Market: MarketStock, Portfolio: c.stockPortfolio},
+		{Market: MarketForts, Portfolio: c.fortsPortfolio},
+		{Market: MarketFx, Portfolio: c.fxPortfolio},
+	}
+	c.portfolioMu.RUnlock()
+
+	result := make([]MarketPortfolio, 0, 3)
+	seen := make(map[string]bool)
+	for _, m := range portfolios {
+		if m.Portfolio == "" || seen[m.Portfolio] {
+			continue
+		}
+		seen[m.Portfolio] = true
+		result = append(result, m)
+	}
+	return result
+}
+
+// GetConsolidatedPortfolio получим сводный портфель по всем настроенным счетам
+// (SetStockPortfolio, SetFortsPortfolio, SetFxAPortfolio или DiscoverPortfolios). Счета запрашиваются параллельно.
+// Если часть рынков получить не удалось, вернем данные по остальным и ошибку PortfolioErrors
+func (c *Client) GetConsolidatedPortfolio(ctx context.Context) (ConsolidatedPortfolio, error) {
+	// номера счетов не установлены: найдем их по данным пользователя
+	if len(c.markets()) == 0 {
+		if _, err := c.DiscoverPortfolios(ctx); err != nil {
+			return ConsolidatedPortfolio{}, err
+		}
+	}
+	result := ConsolidatedPortfolio{
+		Markets: c.markets(),
+		Cash:    make(map[string]float64),
+	}
+	if len(result.Markets) == 0 {
+		return result, errors.New("не установлены номера счетов")
+	}
+
+	var wg sync.WaitGroup
+	for n := range result.Markets {
+		wg.Add(1)
+		go func(m *MarketPortfolio) {
+			defer wg.Done()
+			c.loadMarketPortfolio(ctx, m)
+		}(&result.Markets[n])
+	}
+	wg.Wait()
+
+	errs := make(PortfolioErrors)
+	positions := make(map[string]*Position)
+	for _, m := range result.Markets {
+		if m.Err != nil {
+			errs[m.Market] = m.Err
+			continue
+		}
+		result.Evaluation += m.Summary.PortfolioEvaluation
+		result.LiquidationValue += m.Summary.PortfolioLiquidationValue
+		result.BuyingPower += m.Summary.BuyingPower
+		result.InitialMargin += m.Summary.InitialMargin
+		result.Profit += m.Summary.Profit
+		result.Commission += m.Summary.Commission
+		if m.FortsRisk != nil {
+			result.VarMargin += m.FortsRisk.VarMargin
+		}
+		for _, p := range m.Cash {
+			result.Cash[p.Symbol] += p.QtyUnits
+		}
+		for _, p := range m.Positions {
+			result.UnrealisedPl += p.UnrealisedPl
+			key := p.Exchange + ":" + p.Symbol
+			merged, ok := positions[key]
+			if !ok {
+				p := p
+				positions[key] = &p
+				continue
+			}
+			mergePosition(merged, p)
+		}
+	}
+
+	result.Positions = make([]Position, 0, len(positions))
+	for _, p := range positions {
+		result.Positions = append(result.Positions, *p)
+	}
+	sort.Slice(result.Positions, func(i, j int) bool {
+		return result.Positions[i].Symbol < result.Positions[j].Symbol
+	})
+
+	if len(errs) > 0 {
+		return result, errs
+	}
+	return result, nil
+}
+
+// loadMarketPortfolio загрузим данные портфеля одного рынка
+func (c *Client) loadMarketPortfolio(ctx context.Context, m *MarketPortfolio) {
+	summary, err := c.GetPortfolio(ctx, m.Portfolio)
+	if err != nil {
+		m.Err = err
+		return
+	}
+	m.Summary = summary
+
+	positions, err := c.GetPositions(ctx, m.Portfolio)
+	if err != nil {
+		m.Err = err
+		return
+	}
+	for _, p := range positions {
+		if p.IsCurrency {
+			m.Cash = append(m.Cash, p)
+			continue
+		}
+		m.Positions = append(m.Positions, p)
+	}
+
+	if m.Market == MarketForts {
+		risk, err := c.GetPortfolioFortsRisk(ctx, m.Portfolio)
+		if err != nil {
+			m.Err = err
+			return
+		}
+		m.FortsRisk = &risk
+	}
+}
+
+// mergePosition добавим к позиции dst позицию по тому же инструменту с другого счета
+func mergePosition(dst *Position, p Position) {
+	dst.Portfolio = ""
+	dst.Volume += p.Volume
+	dst.CurrentVolume += p.CurrentVolume
+	dst.QtyUnits += p.QtyUnits
+	dst.OpenUnits += p.OpenUnits
+	dst.Qty += p.Qty
+	dst.QtyBatch += p.QtyBatch
+	dst.Open += p.Open
+	dst.OpenQtyBatch += p.OpenQtyBatch
+	dst.QtyT0 += p.QtyT0
+	dst.QtyT1 += p.QtyT1
+	dst.QtyT2 += p.QtyT2
+	dst.QtyTFuture += p.QtyTFuture
+	dst.QtyT0Batch += p.QtyT0Batch
+	dst.QtyT1Batch += p.QtyT1Batch
+	dst.QtyT2Batch += p.QtyT2Batch
+	dst.QtyTFutureBatch += p.QtyTFutureBatch
+	dst.UnrealisedPl += p.UnrealisedPl
+	if dst.QtyUnits != 0 {
+		dst.AvgPrice = dst.Volume / dst.QtyUnits
+	}
+}
@@ -0,0 +1,209 @@
+package alor
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+/*
+	Список портфелей пользователя
+	GET /client/v1.0/users/{login}/portfolios
+
+	login и список портфелей есть в claims JWT токена:
+	{
+	  "sub": "P039004",
+	  "portfolios": "D39004 G39004 7500PD4",
+	  "clientid": "...",
+	  "agreements": "..."
+	}
+*/
+
+// jwtClaims данные из JWT токена
+type jwtClaims struct {
+	Sub        string `json:"sub"`        // Логин пользователя
+	Portfolios string `json:"portfolios"` // Номера портфелей через пробел
+	ClientID   string `json:"clientid"`   // Идентификатор клиента
+	Agreements string `json:"agreements"` // Номера договоров через пробел
+	Exp        int64  `json:"exp"`        // Время окончания действия токена (Unix time seconds)
+}
+
+// parseJWTClaims разберем claims из JWT токена (без проверки подписи)
+func parseJWTClaims(token string) (jwtClaims, error) {
+	var claims jwtClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, errors.New("не корректный формат JWT токена")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return claims, fmt.Errorf("ошибка разбора JWT токена: %w", err)
+	}
+	if err = json.Unmarshal(data, &claims); err != nil {
+		return claims, fmt.Errorf("ошибка разбора JWT токена: %w", err)
+	}
+	return claims, nil
+}
+
+// PortfolioInfo информация о портфеле пользователя
+type PortfolioInfo struct {
+	Agreement string   `json:"agreement"` // Номер договора
+	Portfolio string   `json:"portfolio"` // Идентификатор клиентского портфеля
+	Tks       string   `json:"tks"`       // Торгово-клиринговый счет
+	Service   string   `json:"service"`   // Рынок (как его вернул сервер)
+	Market    Market   `json:"-"`         // Рынок: stock, forts, fx
+	Boards    []string `json:"-"`         // Основные коды режимов торгов рынка
+}
+
+// основные коды режимов торгов по рынкам
+var marketBoards = map[Market][]string{
+	MarketStock: {"TQBR", "TQCB", "TQOB", "TQTF"},
+	MarketForts: {"RFUD", "ROPD"},
+	MarketFx:    {"CETS"},
+}
+
+// MarketOfBoard определим рынок по коду режима торгов
+func MarketOfBoard(board string) Market {
+	switch board {
+	case "CETS":
+		return MarketFx
+	case "RFUD", "ROPD":
+		return MarketForts
+	default:
+		return MarketStock
+	}
+}
+
+// classifyPortfolio определим рынок портфеля
+// по описанию рынка от сервера, а если его нет - по формату номера:
+// D... фондовый рынок, G... валютный рынок, 7500... (цифры) срочный рынок
+func classifyPortfolio(portfolio, service string) (Market, bool) {
+	s := strings.ToLower(service)
+	switch {
+	case strings.Contains(s, "фонд"), strings.Contains(s, "stock"):
+		return MarketStock, true
+	case strings.Contains(s, "срочн"), strings.Contains(s, "forts"), strings.Contains(s, "deriv"):
+		return MarketForts, true
+	case strings.Contains(s, "валют"), strings.Contains(s, "fx"), strings.Contains(s, "currency"):
+		return MarketFx, true
+	}
+	if portfolio == "" {
+		return "", false
+	}
+	switch portfolio[0] {
+	case 'D':
+		return MarketStock, true
+	case 'G':
+		return MarketFx, true
+	}
+	if portfolio[0] >= '0' && portfolio[0] <= '9' {
+		return MarketForts, true
+	}
+	return "", false
+}
+
+// getLogin вернем логин пользователя из JWT токена
+func (c *Client) getLogin(ctx context.Context) (jwtClaims, error) {
+	token, err := c.getJWT(ctx)
+	if err != nil {
+		return jwtClaims{}, err
+	}
+	if token == "" {
+		return jwtClaims{}, errors.New("не задан refresh токен")
+	}
+	claims, err := parseJWTClaims(token)
+	if err != nil {
+		return claims, err
+	}
+	if claims.Sub == "" {
+		return claims, errors.New("в JWT токене нет логина пользователя")
+	}
+	return claims, nil
+}
+
+// ListPortfolios получим список портфелей пользователя (логин берется из JWT токена)
+// Если запрос списка не удался, используем список портфелей из JWT токена
+func (c *Client) ListPortfolios(ctx context.Context) ([]PortfolioInfo, error) {
+	claims, err := c.getLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queryURL, _ := url.Parse("/client/v1.0/users")
+	queryURL.Path = path.Join(queryURL.Path, claims.Sub, "portfolios")
+	r := &request{
+		method:   http.MethodGet,
+		endpoint: queryURL.String(),
+		route:    "/client/v1.0/users/{login}/portfolios",
+	}
+	result := make([]PortfolioInfo, 0)
+	data, err := c.callAPI(ctx, r)
+	if err == nil {
+		err = json.Unmarshal(data, &result)
+	}
+	if err != nil {
+		if claims.Portfolios == "" {
+			return nil, err
+		}
+		log.Warn("ListPortfolios: используем список портфелей из JWT токена", "err", err.Error())
+		result = result[:0]
+		for _, p := range strings.Fields(claims.Portfolios) {
+			result = append(result, PortfolioInfo{Portfolio: p})
+		}
+	}
+	for n := range result {
+		p := &result[n]
+		market, ok := classifyPortfolio(p.Portfolio, p.Service)
+		if !ok {
+			log.Warn("ListPortfolios: не удалось определить рынок портфеля", "portfolio", p.Portfolio, "service", p.Service)
+			continue
+		}
+		p.Market = market
+		p.Boards = marketBoards[market]
+	}
+	return result, nil
+}
+
+// DiscoverPortfolios найдем портфели пользователя и установим номера счетов по рынкам
+// Номера счетов, установленные вручную (SetStockPortfolio и т.д.), не изменяются.
+// Счет по умолчанию (SetPortfolioID) = счет фондового рынка
+// Клиент сам номера счетов не ищет (NewClient не делает запросов): DiscoverPortfolios нужно вызвать
+// после создания клиента до создания заявок и GetPortfolioID. Исключение: GetConsolidatedPortfolio вызывает его сам
+func (c *Client) DiscoverPortfolios(ctx context.Context) ([]PortfolioInfo, error) {
+	portfolios, err := c.ListPortfolios(ctx)
+	if err != nil {
+		return portfolios, err
+	}
+	// проверка и установка под одной блокировкой: номер, установленный вручную
+	// во время запроса списка (или другим DiscoverPortfolios), не перезапишем
+	c.portfolioMu.Lock()
+	for _, p := range portfolios {
+		switch p.Market {
+		case MarketStock:
+			if c.stockPortfolio == "" {
+				c.stockPortfolio = p.Portfolio
+			}
+		case MarketForts:
+			if c.fortsPortfolio == "" {
+				c.fortsPortfolio = p.Portfolio
+			}
+		case MarketFx:
+			if c.fxPortfolio == "" {
+				c.fxPortfolio = p.Portfolio
+			}
+		}
+	}
+	if c.portfolioID == "" {
+		c.portfolioID = c.stockPortfolio
+	}
+	stock, forts, fx := c.stockPortfolio, c.fortsPortfolio, c.fxPortfolio
+	c.portfolioMu.Unlock()
+
+	log.Debug("DiscoverPortfolios", "stock", stock, "forts", forts, "fx", fx)
+	return portfolios, nil
+}
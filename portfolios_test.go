@@ -0,0 +1,132 @@
+package alor
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// newPortfoliosClient клиент со списком портфелей P039004 (портфели из JWT токена, если list = "")
+func newPortfoliosClient(t *testing.T, list string) *Client {
+	t.Helper()
+	token := testJWT(`{"sub":"P039004","portfolios":"D39004 G39004 7500PD4"}`)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/client/v1.0/users/P039004/portfolios" || list == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(list))
+	}, WithTokenSource(StaticTokenSource(token)), WithRetryPolicy(NoRetry()))
+	c.SetPortfolioID("")
+	return c
+}
+
+func TestDiscoverPortfolios(t *testing.T) {
+	list := `[
+		{"agreement":"1","portfolio":"D39004","tks":"L01-00000F00","service":"Фондовый рынок"},
+		{"agreement":"1","portfolio":"7500PD4","tks":"7500PD4","service":"Срочный рынок"},
+		{"agreement":"1","portfolio":"G39004","tks":"MB0000000001","service":"Валютный рынок"}
+	]`
+	tests := []struct {
+		name                  string
+		list                  string
+		manual                func(c *Client)
+		stock, forts, fx, def string
+	}{
+		{name: "список с сервера", list: list,
+			stock: "D39004", forts: "7500PD4", fx: "G39004", def: "D39004"},
+		{name: "список из JWT токена",
+			stock: "D39004", forts: "7500PD4", fx: "G39004", def: "D39004"},
+		{name: "счета, установленные вручную, не меняются", list: list,
+			manual: func(c *Client) {
+				c.SetFortsPortfolio("7500XX1")
+				c.SetPortfolioID("D00001")
+			},
+			stock: "D39004", forts: "7500XX1", fx: "G39004", def: "D00001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newPortfoliosClient(t, tt.list)
+			if tt.manual != nil {
+				tt.manual(c)
+			}
+			portfolios, err := c.DiscoverPortfolios(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(portfolios) != 3 {
+				t.Fatalf("портфели %+v", portfolios)
+			}
+			if got := c.GetPortfolioID("TQBR"); got != tt.stock {
+				t.Errorf("фондовый рынок %q, ожидали %q", got, tt.stock)
+			}
+			if got := c.GetPortfolioID("RFUD"); got != tt.forts {
+				t.Errorf("срочный рынок %q, ожидали %q", got, tt.forts)
+			}
+			if got := c.GetPortfolioID("CETS"); got != tt.fx {
+				t.Errorf("валютный рынок %q, ожидали %q", got, tt.fx)
+			}
+			if got := c.NewCreateOrderService().order.User.Portfolio; got != tt.def {
+				t.Errorf("счет по умолчанию %q, ожидали %q", got, tt.def)
+			}
+			if markets := c.markets(); len(markets) != 3 {
+				t.Errorf("счета по рынкам %+v", markets)
+			}
+		})
+	}
+}
+
+// TestDiscoverPortfoliosConcurrent номера счетов читаются и меняются из разных горутин (go test -race)
+func TestDiscoverPortfoliosConcurrent(t *testing.T) {
+	c := newPortfoliosClient(t, "")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if _, err := c.DiscoverPortfolios(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			c.SetFxAPortfolio("G39004")
+			_ = c.GetPortfolioID("CETS")
+		}()
+		go func() {
+			defer wg.Done()
+			_ = c.markets()
+			_ = c.NewCreateOrderStopService()
+			_ = c.NewModifyOrderService("1")
+		}()
+	}
+	wg.Wait()
+	if got := c.GetPortfolioID("RFUD"); got != "7500PD4" {
+		t.Fatalf("срочный рынок %q", got)
+	}
+}
+
+// TestListPortfoliosContext токен запрашивается с ctx вызывающего (значения ctx доходят до TokenSource)
+func TestListPortfoliosContext(t *testing.T) {
+	type ctxKey struct{}
+	token := testJWT(`{"sub":"P039004","portfolios":"D39004"}`)
+	var got any
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}, WithRetryPolicy(NoRetry()), WithTokenSource(TokenSourceFunc(func(ctx context.Context) (string, error) {
+		got = ctx.Value(ctxKey{})
+		return token, nil
+	})))
+	ctx := context.WithValue(context.Background(), ctxKey{}, "caller")
+	portfolios, err := c.ListPortfolios(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "caller" {
+		t.Errorf("значение ctx в TokenSource %v, ожидали caller", got)
+	}
+	if len(portfolios) != 1 || portfolios[0].Portfolio != "D39004" {
+		t.Errorf("портфели %+v", portfolios)
+	}
+}
@@ -2,7 +2,6 @@ package alor
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
 	"net/url"
 	"path"
@@ -17,14 +16,18 @@ import (
 
 // GetQuotes Получение информации о котировках для выбранных инструментов.
 // Принимает несколько пар биржа-тикер. Пары отделены запятыми. Биржа и тикер разделены двоеточием
-func (c *Client) GetQuotes(ctx context.Context, symbols string) ([]Quote, error) {
+// Формат ответа можно выбрать опцией WithFormat
+func (c *Client) GetQuotes(ctx context.Context, symbols string, opts ...Option) ([]Quote, error) {
+	params := NewOptions(opts...)
 	queryURL, _ := url.Parse("/md/v2/Securities")
 	queryURL.Path = path.Join(queryURL.Path, symbols, "quotes")
 
 	r := &request{
 		method:   http.MethodGet,
 		endpoint: queryURL.String(),
+		route:    "/md/v2/Securities/{symbols}/quotes",
 	}
+	params.setFormat(r)
 
 	result := make([]Quote, 0)
 	data, err := c.callAPI(ctx, r)
@@ -33,7 +36,7 @@ func (c *Client) GetQuotes(ctx context.Context, symbols string) ([]Quote, error)
 		return result, err
 	}
 
-	err = json.Unmarshal(data, &result)
+	result, err = decodeFormatSlice[quoteSlim](data, params.Format)
 	if err != nil {
 		//c.Logger.Error("GetQuotes ", "err", err.Error())
 		return result, err
@@ -44,9 +47,9 @@ func (c *Client) GetQuotes(ctx context.Context, symbols string) ([]Quote, error)
 
 // GetQuote Получение информации о котировках для одного выбранного инструмента.
 // Указываем тикер без указания биржи. Название биржи берется по умолчанию
-func (c *Client) GetQuote(ctx context.Context, symbol string) (Quote, error) {
+func (c *Client) GetQuote(ctx context.Context, symbol string, opts ...Option) (Quote, error) {
 	ticker := c.Exchange + ":" + symbol
-	quotes, err := c.GetQuotes(ctx, ticker)
+	quotes, err := c.GetQuotes(ctx, ticker, opts...)
 	if err != nil {
 		return Quote{}, err
 	}
@@ -0,0 +1,242 @@
+package alor
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestClass класс REST запроса для ограничения частоты запросов
+type RequestClass string
+
+const (
+	RequestClassMarketData RequestClass = "market_data" // Рыночные данные: котировки, инструменты, история
+	RequestClassAccount    RequestClass = "account"     // Данные по счету: портфель, позиции, заявки, сделки
+	RequestClassTrading    RequestClass = "trading"     // Торговые команды: создание, изменение, снятие заявок
+)
+
+// BucketLimit параметры token bucket
+type BucketLimit struct {
+	Rate  float64 // Запросов в секунду (0 = без ограничения)
+	Burst int     // Максимальное кол-во запросов подряд без ожидания
+}
+
+// RateLimits ограничения частоты REST запросов
+// Каждый класс запросов ограничивается своим bucket, и все вместе - общим (Total).
+// Последние TradingReserve токенов общего bucket доступны только торговым командам:
+// сканеры рыночных данных не могут забрать весь лимит и задержать выставление заявок
+type RateLimits struct {
+	MarketData     BucketLimit
+	Account        BucketLimit
+	Trading        BucketLimit
+	Total          BucketLimit
+	TradingReserve int
+}
+
+// DefaultRateLimits ограничения по умолчанию
+func DefaultRateLimits() RateLimits {
+	return RateLimits{
+		MarketData:     BucketLimit{Rate: 10, Burst: 20},
+		Account:        BucketLimit{Rate: 5, Burst: 10},
+		Trading:        BucketLimit{Rate: 10, Burst: 10},
+		Total:          BucketLimit{Rate: 20, Burst: 30},
+		TradingReserve: 5,
+	}
+}
+
+// NoRateLimits без ограничения частоты запросов
+func NoRateLimits() RateLimits {
+	return RateLimits{}
+}
+
+// WithRateLimits ограничения частоты REST запросов (по умолчанию DefaultRateLimits)
+func WithRateLimits(limits RateLimits) ClientOption {
+	return func(c *Client) {
+		c.limiter = newRateLimiter(limits)
+	}
+}
+
+// RateLimitStats статистика ожидания лимита по классу запросов
+type RateLimitStats struct {
+	Requests  uint64        // Всего запросов
+	Waited    uint64        // Запросов, которые ждали лимит
+	TotalWait time.Duration // Суммарное время ожидания
+	MaxWait   time.Duration // Максимальное время ожидания
+}
+
+// AvgWait среднее время ожидания на запрос
+func (s RateLimitStats) AvgWait() time.Duration {
+	if s.Requests == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(s.Requests)
+}
+
+// tokenBucket ограничение частоты
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit BucketLimit) *tokenBucket {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// wait ждем токен. reserve = сколько токенов должно остаться в bucket после получения токена
+func (b *tokenBucket) wait(ctx context.Context, reserve float64) error {
+	if b == nil {
+		return nil
+	}
+	if reserve > b.burst-1 {
+		reserve = b.burst - 1
+	}
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+		need := 1 + reserve
+		if b.tokens >= need {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// release вернем токен, полученный зря (запрос не отправлен)
+func (b *tokenBucket) release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// rateLimiter ограничение частоты запросов клиента
+type rateLimiter struct {
+	buckets map[RequestClass]*tokenBucket
+	total   *tokenBucket
+	reserve float64
+	mu      sync.Mutex
+	stats   map[RequestClass]RateLimitStats
+}
+
+func newRateLimiter(limits RateLimits) *rateLimiter {
+	return &rateLimiter{
+		buckets: map[RequestClass]*tokenBucket{
+			RequestClassMarketData: newTokenBucket(limits.MarketData),
+			RequestClassAccount:    newTokenBucket(limits.Account),
+			RequestClassTrading:    newTokenBucket(limits.Trading),
+		},
+		total:   newTokenBucket(limits.Total),
+		reserve: float64(limits.TradingReserve),
+		stats:   make(map[RequestClass]RateLimitStats),
+	}
+}
+
+// wait ждем разрешения на запрос
+func (l *rateLimiter) wait(ctx context.Context, class RequestClass) error {
+	start := time.Now()
+	err := l.buckets[class].wait(ctx, 0)
+	if err == nil {
+		reserve := l.reserve
+		if class == RequestClassTrading {
+			reserve = 0
+		}
+		err = l.total.wait(ctx, reserve)
+		// запрос не будет отправлен: токен класса вернем
+		if err != nil {
+			l.buckets[class].release()
+		}
+	}
+	waited := time.Since(start)
+
+	l.mu.Lock()
+	s := l.stats[class]
+	s.Requests++
+	// время на блокировки не считаем ожиданием
+	if waited > time.Millisecond {
+		s.Waited++
+		s.TotalWait += waited
+		if waited > s.MaxWait {
+			s.MaxWait = waited
+		}
+	}
+	l.stats[class] = s
+	l.mu.Unlock()
+
+	if waited > time.Second {
+		log.Debug("rateLimiter: долгое ожидание лимита", "class", class, "wait", waited)
+	}
+	return err
+}
+
+// requestClass определим класс запроса по адресу
+func (r *request) requestClass() RequestClass {
+	switch {
+	case strings.HasPrefix(r.endpoint, "/commandapi"):
+		return RequestClassTrading
+	case strings.HasPrefix(r.endpoint, "/md/v2/Clients"),
+		strings.HasPrefix(r.endpoint, "/md/v2/Stats"),
+		strings.HasPrefix(r.endpoint, "/client/"):
+		return RequestClassAccount
+	default:
+		return RequestClassMarketData
+	}
+}
+
+// waitRateLimit ждем разрешения на запрос (запросы к серверу авторизации не ограничиваются)
+func (c *Client) waitRateLimit(ctx context.Context, r *request) error {
+	if c.limiter == nil || (r.baseURL != "" && r.baseURL == c.oauthURL) {
+		return nil
+	}
+	return c.limiter.wait(ctx, r.requestClass())
+}
+
+// RateLimitStats статистика ожидания лимита запросов по классам
+func (c *Client) RateLimitStats() map[RequestClass]RateLimitStats {
+	result := make(map[RequestClass]RateLimitStats)
+	if c.limiter == nil {
+		return result
+	}
+	c.limiter.mu.Lock()
+	defer c.limiter.mu.Unlock()
+	for class, s := range c.limiter.stats {
+		result[class] = s
+	}
+	return result
+}
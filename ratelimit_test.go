@@ -0,0 +1,164 @@
+package alor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRequestClass(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     RequestClass
+	}{
+		{"/commandapi/warptrans/TRADE/v2/client/orders/actions/limit", RequestClassTrading},
+		{"/commandapi/warptrans/TRADE/v2/client/orders/123?portfolio=D39004&exchange=MOEX", RequestClassTrading},
+		{"/commandapi/api/orderGroups", RequestClassTrading},
+		{"/md/v2/Clients/MOEX/D39004/summary", RequestClassAccount},
+		{"/md/v2/Clients/MOEX/D39004/orders", RequestClassAccount},
+		{"/md/v2/Clients/legacy/MOEX/D39004/money", RequestClassAccount},
+		{"/md/v2/Stats/MOEX/D39004/history/trades", RequestClassAccount},
+		{"/client/v1.0/users/P039004/portfolios", RequestClassAccount},
+		{"/md/v2/Securities/MOEX:SBER/quotes", RequestClassMarketData},
+		{"/md/v2/Securities/MOEX/SBER", RequestClassMarketData},
+		{"/md/v2/orderbooks/MOEX/SBER", RequestClassMarketData},
+		{"/md/v2/history", RequestClassMarketData},
+		{"/md/v2/time", RequestClassMarketData},
+		// префикс сравнивается с учетом регистра и начала адреса
+		{"/md/v2/Securities/Clients", RequestClassMarketData},
+		{"/api/commandapi", RequestClassMarketData},
+	}
+	for _, tt := range tests {
+		r := &request{endpoint: tt.endpoint}
+		if got := r.requestClass(); got != tt.want {
+			t.Errorf("%s: класс %s, ожидали %s", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+// tryWait получим разрешение на запрос, не дожидаясь пополнения bucket
+func tryWait(l *rateLimiter, class RequestClass) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	return l.wait(ctx, class)
+}
+
+func TestRateLimiterTradingReserve(t *testing.T) {
+	l := newRateLimiter(RateLimits{
+		Total:          BucketLimit{Rate: 0.01, Burst: 5},
+		TradingReserve: 2,
+	})
+	// рыночные данные и счет забирают общий bucket только до резерва
+	for i, class := range []RequestClass{RequestClassMarketData, RequestClassAccount, RequestClassMarketData} {
+		if err := tryWait(l, class); err != nil {
+			t.Fatalf("запрос %d (%s): %v", i+1, class, err)
+		}
+	}
+	for _, class := range []RequestClass{RequestClassMarketData, RequestClassAccount} {
+		if err := tryWait(l, class); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("%s: ошибка %v, ожидали ожидание лимита (резерв торговых команд)", class, err)
+		}
+	}
+	// резерв доступен торговым командам
+	for i := 0; i < 2; i++ {
+		if err := tryWait(l, RequestClassTrading); err != nil {
+			t.Fatalf("торговая команда %d: %v", i+1, err)
+		}
+	}
+	if err := tryWait(l, RequestClassTrading); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ошибка %v, ожидали исчерпание общего лимита", err)
+	}
+}
+
+func TestRateLimiterReserveLargerThanBurst(t *testing.T) {
+	// резерв больше общего bucket: последний токен все равно доступен рыночным данным
+	l := newRateLimiter(RateLimits{
+		Total:          BucketLimit{Rate: 0.01, Burst: 2},
+		TradingReserve: 10,
+	})
+	if err := tryWait(l, RequestClassMarketData); err != nil {
+		t.Fatal(err)
+	}
+	if err := tryWait(l, RequestClassMarketData); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ошибка %v, ожидали ожидание лимита", err)
+	}
+	if err := tryWait(l, RequestClassTrading); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRateLimiterReleaseOnCancel(t *testing.T) {
+	l := newRateLimiter(RateLimits{
+		MarketData: BucketLimit{Rate: 0.01, Burst: 2},
+		Total:      BucketLimit{Rate: 0.01, Burst: 1},
+	})
+	if err := tryWait(l, RequestClassMarketData); err != nil {
+		t.Fatal(err)
+	}
+	// токен класса получен, общий bucket пуст: запрос отменен
+	for i := 0; i < 3; i++ {
+		if err := tryWait(l, RequestClassMarketData); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("ошибка %v, ожидали ожидание общего лимита", err)
+		}
+	}
+	b := l.buckets[RequestClassMarketData]
+	b.mu.Lock()
+	tokens := b.tokens
+	b.mu.Unlock()
+	if tokens < 1 {
+		t.Fatalf("в bucket класса %.2f токенов, ожидали возврат токена отмененных запросов", tokens)
+	}
+}
+
+func TestRateLimitStats(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}, WithRateLimits(RateLimits{
+		MarketData: BucketLimit{Rate: 20, Burst: 1},
+	}))
+	if stats := c.RateLimitStats(); len(stats) != 0 {
+		t.Fatalf("статистика до запросов: %+v", stats)
+	}
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := c.callAPI(ctx, &request{method: http.MethodGet, endpoint: "/md/v2/time"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.callAPI(ctx, &request{method: http.MethodGet, endpoint: "/md/v2/Clients/MOEX/D39004/summary"}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := c.RateLimitStats()
+	md := stats[RequestClassMarketData]
+	if md.Requests != 3 {
+		t.Fatalf("запросов рыночных данных %d, ожидали 3", md.Requests)
+	}
+	// burst 1, 20 запросов в секунду: второй и третий ждут около 50ms
+	if md.Waited != 2 {
+		t.Fatalf("ждали лимит %d запросов, ожидали 2", md.Waited)
+	}
+	if md.MaxWait < 30*time.Millisecond || md.TotalWait < md.MaxWait {
+		t.Fatalf("время ожидания: max %v, total %v", md.MaxWait, md.TotalWait)
+	}
+	if avg := md.AvgWait(); avg != md.TotalWait/3 {
+		t.Fatalf("среднее ожидание %v", avg)
+	}
+	if acc := stats[RequestClassAccount]; acc.Requests != 1 || acc.Waited != 0 {
+		t.Fatalf("статистика по счету: %+v", acc)
+	}
+	if _, ok := stats[RequestClassTrading]; ok {
+		t.Fatal("статистика по торговым командам без запросов")
+	}
+	if (RateLimitStats{}).AvgWait() != 0 {
+		t.Fatal("AvgWait без запросов")
+	}
+
+	// без ограничителя статистика пустая
+	c.limiter = nil
+	if stats := c.RateLimitStats(); len(stats) != 0 {
+		t.Fatalf("статистика без ограничителя: %+v", stats)
+	}
+}
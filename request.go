@@ -13,6 +13,7 @@ type params map[string]interface{}
 type request struct {
 	method           string
 	endpoint         string
+	route            string // шаблон адреса без тикеров, портфелей и номеров (метка endpoint в метриках)
 	query            url.Values
 	form             url.Values
 	header           http.Header
@@ -0,0 +1,192 @@
+package alor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RetryPolicy политика повтора REST запросов
+// Повторяются только идемпотентные запросы: GET/HEAD и запросы с заголовком X-ALOR-REQID
+// (сервер не исполнит команду второй раз, а вернет копию первого ответа).
+// Остальные запросы (без X-ALOR-REQID) никогда не повторяются
+type RetryPolicy struct {
+	MaxAttempts   int           // Максимальное кол-во попыток (1 = без повторов)
+	BaseDelay     time.Duration // Пауза перед первым повтором. Дальше удваивается
+	MaxDelay      time.Duration // Максимальная пауза между попытками
+	Jitter        float64       // Случайное отклонение паузы (0.2 = ±20%)
+	MaxRetryAfter time.Duration // Максимальная пауза по заголовку Retry-After (если больше, не повторяем)
+	RetryStatuses []int         // HTTP статусы, при которых повторяем запрос
+}
+
+// DefaultRetryPolicy политика повтора по умолчанию
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:   3,
+		BaseDelay:     300 * time.Millisecond,
+		MaxDelay:      5 * time.Second,
+		Jitter:        0.2,
+		MaxRetryAfter: 30 * time.Second,
+		RetryStatuses: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// NoRetry политика без повторов
+func NoRetry() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 1}
+}
+
+// WithRetryPolicy политика повтора REST запросов (по умолчанию DefaultRetryPolicy)
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// backoff пауза перед попыткой attempt+1
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+// retryableStatus нужно ли повторять запрос с таким статусом ответа
+func (p RetryPolicy) retryableStatus(status int) bool {
+	for _, s := range p.RetryStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// isIdempotent можно ли безопасно повторить запрос
+func (r *request) isIdempotent() bool {
+	switch r.method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	}
+	return r.header.Get("X-ALOR-REQID") != ""
+}
+
+// callAPI запрос к REST API с повтором по RetryPolicy
+func (c *Client) callAPI(ctx context.Context, r *request, opts ...RequestOption) (data []byte, err error) {
+	ctx, span := c.startSpan(ctx, "alor.callAPI",
+		attribute.String("http.request.method", r.method),
+		attribute.String("alor.endpoint", r.endpoint),
+		attribute.String("http.route", r.metricsRoute()),
+	)
+	defer func() { endSpan(span, err) }()
+
+	policy := c.retry
+	if policy.MaxAttempts <= 1 || !r.isIdempotent() {
+		return c.doAPI(ctx, r, opts...)
+	}
+	// тело запроса прочитаем один раз, чтобы послать его при каждой попытке
+	var body []byte
+	if r.body != nil {
+		if body, err = io.ReadAll(r.body); err != nil {
+			return []byte{}, err
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		req := *r
+		req.header = r.header.Clone()
+		if body != nil {
+			req.body = bytes.NewReader(body)
+		}
+		data, err = c.doAPI(ctx, &req, opts...)
+		if err == nil || attempt >= policy.MaxAttempts || ctx.Err() != nil {
+			return data, err
+		}
+		delay, ok := policy.retryDelay(err, attempt)
+		if !ok {
+			return data, err
+		}
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("alor.attempt", attempt),
+			attribute.Int64("alor.delay_ms", delay.Milliseconds()),
+		))
+		log.Warn("callAPI: повтор запроса", "attempt", attempt, "delay", delay, "url", req.fullURL, "X-ALOR-REQID", r.header.Get("X-ALOR-REQID"), "err", err.Error())
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return data, err
+		case <-timer.C:
+		}
+	}
+}
+
+// retryDelay нужно ли повторять запрос после ошибки и с какой паузой
+func (p RetryPolicy) retryDelay(err error, attempt int) (time.Duration, bool) {
+	delay := p.backoff(attempt)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if !p.retryableStatus(apiErr.Status) {
+			return 0, false
+		}
+		if apiErr.retryAfter > 0 {
+			if p.MaxRetryAfter > 0 && apiErr.retryAfter > p.MaxRetryAfter {
+				return 0, false
+			}
+			if apiErr.retryAfter > delay {
+				delay = apiErr.retryAfter
+			}
+		}
+		return delay, true
+	}
+	if isNetworkError(err) {
+		return delay, true
+	}
+	return 0, false
+}
+
+// isNetworkError ошибка сети (ответ от сервера не получен)
+func isNetworkError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// parseRetryAfter разбор заголовка Retry-After (секунды или HTTP дата)
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,181 @@
+package alor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// retryServer отвечает статусами из statuses по очереди (дальше 200)
+type retryServer struct {
+	mu         sync.Mutex
+	statuses   []int
+	retryAfter string
+	hangup     int // кол-во первых запросов, на которые соединение закрывается без ответа
+	attempts   int
+	bodies     []string
+}
+
+func (s *retryServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts++
+	body, _ := io.ReadAll(r.Body)
+	s.bodies = append(s.bodies, string(body))
+	if s.attempts <= s.hangup {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+		return
+	}
+	if n := s.attempts - s.hangup - 1; n < len(s.statuses) {
+		if s.retryAfter != "" {
+			w.Header().Set("Retry-After", s.retryAfter)
+		}
+		w.WriteHeader(s.statuses[n])
+		return
+	}
+	_, _ = w.Write([]byte(`{}`))
+}
+
+// result кол-во попыток и тела запросов
+// обработчик может еще работать после ответа клиенту (закрытое соединение), поэтому под блокировкой
+func (s *retryServer) result() (int, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts, append([]string(nil), s.bodies...)
+}
+
+func TestCallAPIRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		reqID      bool
+		statuses   []int
+		retryAfter string
+		hangup     int
+		attempts   int
+		wantErr    bool
+	}{
+		{name: "GET 503", method: http.MethodGet, statuses: []int{503}, attempts: 2},
+		{name: "GET 429", method: http.MethodGet, statuses: []int{429}, attempts: 2},
+		{name: "GET 500 502 504: лимит попыток", method: http.MethodGet, statuses: []int{500, 502, 504}, attempts: 3, wantErr: true},
+		{name: "GET 400 не повторяется", method: http.MethodGet, statuses: []int{400}, attempts: 1, wantErr: true},
+		{name: "GET 404 не повторяется", method: http.MethodGet, statuses: []int{404}, attempts: 1, wantErr: true},
+		{name: "GET сетевая ошибка", method: http.MethodGet, hangup: 1, attempts: 2},
+		{name: "POST без X-ALOR-REQID", method: http.MethodPost, statuses: []int{503}, attempts: 1, wantErr: true},
+		{name: "POST без X-ALOR-REQID, сетевая ошибка", method: http.MethodPost, hangup: 1, attempts: 1, wantErr: true},
+		{name: "POST с X-ALOR-REQID", method: http.MethodPost, reqID: true, statuses: []int{503}, attempts: 2},
+		{name: "POST с X-ALOR-REQID, 429", method: http.MethodPost, reqID: true, statuses: []int{429}, attempts: 2},
+		{name: "POST с X-ALOR-REQID, сетевая ошибка", method: http.MethodPost, reqID: true, hangup: 1, attempts: 2},
+		{name: "DELETE без X-ALOR-REQID", method: http.MethodDelete, statuses: []int{503}, attempts: 1, wantErr: true},
+		{name: "Retry-After 0", method: http.MethodGet, statuses: []int{429}, retryAfter: "0", attempts: 2},
+		{name: "Retry-After больше MaxRetryAfter", method: http.MethodGet, statuses: []int{429}, retryAfter: "120", attempts: 1, wantErr: true},
+		{name: "Retry-After дата в прошлом", method: http.MethodGet, statuses: []int{503},
+			retryAfter: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), attempts: 2},
+		{name: "Retry-After дата больше MaxRetryAfter", method: http.MethodGet, statuses: []int{503},
+			retryAfter: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), attempts: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &retryServer{statuses: tt.statuses, retryAfter: tt.retryAfter, hangup: tt.hangup}
+			c := newTestClient(t, srv.handle, WithRetryPolicy(fastRetry(3)))
+			r := &request{method: tt.method, endpoint: "/md/v2/test", route: "/md/v2/test"}
+			if tt.method != http.MethodGet {
+				r.body = strings.NewReader(`{"qty":1}`)
+			}
+			if tt.reqID {
+				r.setHeader("X-ALOR-REQID", "key")
+			}
+			_, err := c.callAPI(context.Background(), r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка %v, ожидали ошибку: %v", err, tt.wantErr)
+			}
+			attempts, bodies := srv.result()
+			if attempts != tt.attempts {
+				t.Fatalf("попыток %d, ожидали %d", attempts, tt.attempts)
+			}
+			// при повторе тело запроса посылается заново
+			for n, body := range bodies {
+				if tt.method != http.MethodGet && body != `{"qty":1}` {
+					t.Errorf("попытка %d: тело %q", n+1, body)
+				}
+			}
+		})
+	}
+}
+
+func TestCallAPIRetryAfterSeconds(t *testing.T) {
+	srv := &retryServer{statuses: []int{429}, retryAfter: "1"}
+	c := newTestClient(t, srv.handle, WithRetryPolicy(fastRetry(2)))
+	start := time.Now()
+	if _, err := c.callAPI(context.Background(), &request{method: http.MethodGet, endpoint: "/md/v2/test"}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("повтор через %v, ожидали не раньше Retry-After (1s)", elapsed)
+	}
+}
+
+func TestCallAPIRetryCancel(t *testing.T) {
+	srv := &retryServer{statuses: []int{503, 503, 503}}
+	policy := fastRetry(3)
+	policy.BaseDelay, policy.MaxDelay = time.Minute, time.Minute
+	c := newTestClient(t, srv.handle, WithRetryPolicy(policy))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.callAPI(ctx, &request{method: http.MethodGet, endpoint: "/md/v2/test"})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("отмена ctx во время паузы не прервала повтор (%v)", elapsed)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusServiceUnavailable {
+		t.Fatalf("ошибка %v, ожидали ответ 503", err)
+	}
+	if attempts, _ := srv.result(); attempts != 1 {
+		t.Fatalf("попыток %d, ожидали 1", attempts)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"0", 0, 0},
+		{"5", 5 * time.Second, 5 * time.Second},
+		{"abc", 0, 0},
+		{time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second},
+		{time.Now().Add(-10 * time.Second).UTC().Format(http.TimeFormat), -11 * time.Second, -9 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, ожидали от %v до %v", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for n, w := range want {
+		if got := p.backoff(n + 1); got != w {
+			t.Errorf("backoff(%d) = %v, ожидали %v", n+1, got, w)
+		}
+	}
+	p.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(1); got < 80*time.Millisecond || got > 120*time.Millisecond {
+			t.Fatalf("backoff с jitter = %v", got)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package alor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// период опроса рисков по умолчанию
+const riskMonitorInterval = 5 * time.Second
+
+// комментарий к заявкам на закрытие позиций
+const riskKillComment = "risk monitor: kill switch"
+
+// RiskSnapshot данные по рискам портфеля на момент проверки
+type RiskSnapshot struct {
+	Portfolio string              // Номер счета
+	Time      time.Time           // Время получения данных
+	Summary   Portfolio           // Информация о портфеле (прибыль за день)
+	Risk      *PortfolioRisk      // Портфельные риски (фондовый и валютный рынок)
+	FortsRisk *PortfolioFortsRisk // Риски срочного рынка
+}
+
+// RiskBreach нарушение правила
+type RiskBreach struct {
+	Rule     string       // Название правила
+	Value    float64      // Текущее значение
+	Limit    float64      // Граница правила
+	Snapshot RiskSnapshot // Данные, по которым сработало правило
+}
+
+func (b RiskBreach) String() string {
+	return fmt.Sprintf("%s: значение %v, граница %v", b.Rule, b.Value, b.Limit)
+}
+
+// RiskRule правило контроля риска
+// возвращает нарушение и true, если правило нарушено
+// правило, для которого нет данных (например FortsRisk для фондового счета), не нарушается
+type RiskRule func(s RiskSnapshot) (RiskBreach, bool)
+
+type RiskBreachFunc func(breach RiskBreach)
+
+// MinCoverageRatio НПР1 не ниже ratio
+func MinCoverageRatio(ratio float64) RiskRule {
+	return func(s RiskSnapshot) (RiskBreach, bool) {
+		if s.Risk == nil {
+			return RiskBreach{}, false
+		}
+		v := s.Risk.RiskCoverageRatioOne
+		return RiskBreach{Rule: "MinCoverageRatio", Value: v, Limit: ratio}, v < ratio
+	}
+}
+
+// MinMoneyFree свободные средства срочного рынка (MoneyFree) не ниже value
+func MinMoneyFree(value float64) RiskRule {
+	return func(s RiskSnapshot) (RiskBreach, bool) {
+		if s.FortsRisk == nil {
+			return RiskBreach{}, false
+		}
+		v := s.FortsRisk.MoneyFree
+		return RiskBreach{Rule: "MinMoneyFree", Value: v, Limit: value}, v < value
+	}
+}
+
+// MaxDailyLoss убыток за день (Portfolio.Profit) не больше loss (loss положительное число)
+func MaxDailyLoss(loss float64) RiskRule {
+	return func(s RiskSnapshot) (RiskBreach, bool) {
+		v := s.Summary.Profit
+		return RiskBreach{Rule: "MaxDailyLoss", Value: v, Limit: -loss}, v < -loss
+	}
+}
+
+// MaxVarMarginLoss отрицательная вариационная маржа (VarMargin) не больше loss (loss положительное число)
+func MaxVarMarginLoss(loss float64) RiskRule {
+	return func(s RiskSnapshot) (RiskBreach, bool) {
+		if s.FortsRisk == nil {
+			return RiskBreach{}, false
+		}
+		v := s.FortsRisk.VarMargin
+		return RiskBreach{Rule: "MaxVarMarginLoss", Value: v, Limit: -loss}, v < -loss
+	}
+}
+
+// RiskMonitor контроль рисков портфеля
+// Периодически запрашивает риски портфеля и проверяет правила.
+// При нарушении правила вызывается функция OnBreach (один раз, пока правило не перестанет нарушаться),
+// и, если включен KillSwitch, снимаются все заявки и закрываются позиции.
+// Если kill switch завершился с ошибкой, он повторяется при каждой проверке, пока есть нарушение
+type RiskMonitor struct {
+	c          *Client
+	portfolio  string
+	market     Market
+	rules      []RiskRule
+	interval   time.Duration
+	killSwitch bool
+	mu         sync.Mutex
+	onBreach   RiskBreachFunc
+	breached   map[int]bool // правила, нарушенные при прошлой проверке
+	killed     bool         // kill switch уже сработал (без ошибок)
+	killing    bool         // kill switch выполняется сейчас
+	last       RiskSnapshot
+}
+
+// NewRiskMonitor создать контроль рисков портфеля
+func (c *Client) NewRiskMonitor(portfolio string, rules ...RiskRule) *RiskMonitor {
+	market, ok := classifyPortfolio(portfolio, "")
+	if !ok {
+		market = MarketStock
+	}
+	return &RiskMonitor{
+		c:         c,
+		portfolio: portfolio,
+		market:    market,
+		rules:     rules,
+		interval:  riskMonitorInterval,
+		breached:  make(map[int]bool),
+	}
+}
+
+// Interval установим период опроса рисков
+func (m *RiskMonitor) Interval(interval time.Duration) *RiskMonitor {
+	m.interval = interval
+	return m
+}
+
+// Market установим рынок портфеля (если не определился по номеру счета)
+func (m *RiskMonitor) Market(market Market) *RiskMonitor {
+	m.market = market
+	return m
+}
+
+// KillSwitch при нарушении правила снять все заявки и закрыть позиции по рынку
+func (m *RiskMonitor) KillSwitch(enable bool) *RiskMonitor {
+	m.killSwitch = enable
+	return m
+}
+
+// SetOnBreach регистрирует функцию на нарушение правила
+func (m *RiskMonitor) SetOnBreach(f RiskBreachFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onBreach = f
+}
+
+// Last вернем данные последней проверки
+func (m *RiskMonitor) Last() RiskSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// Reset снова разрешим срабатывание kill switch (после ручного разбора ситуации)
+func (m *RiskMonitor) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.killed = false
+	m.breached = make(map[int]bool)
+}
+
+// Start запустим опрос рисков (в отдельной горутине до отмены ctx)
+// Правила проверяются по свежим данным REST (GetPortfolio, GetPortfolioRisk/GetPortfolioFortsRisk)
+// каждые interval: пропущенные websocket сообщения и переподключения на проверку не влияют.
+// Если активна подписка на сделки портфеля (SubscribeTrades), проверка выполняется
+// и сразу после сделки, не дожидаясь следующего опроса (несколько сделок подряд дают одну проверку)
+func (m *RiskMonitor) Start(ctx context.Context) {
+	wake := make(chan struct{}, 1)
+	unregister := m.c.RegisterOnTrade(func(Trade) {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	})
+	go func() {
+		defer unregister()
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := m.Check(ctx); err != nil && ctx.Err() == nil {
+				log.Error("RiskMonitor.Check", "portfolio", m.portfolio, "err", err.Error())
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-wake:
+			}
+		}
+	}()
+}
+
+// snapshot запросим риски портфеля
+func (m *RiskMonitor) snapshot(ctx context.Context) (RiskSnapshot, error) {
+	s := RiskSnapshot{
+		Portfolio: m.portfolio,
+		Time:      time.Now(),
+	}
+	summary, err := m.c.GetPortfolio(ctx, m.portfolio)
+	if err != nil {
+		return s, err
+	}
+	s.Summary = summary
+	if m.market == MarketForts {
+		risk, err := m.c.GetPortfolioFortsRisk(ctx, m.portfolio)
+		if err != nil {
+			return s, err
+		}
+		s.FortsRisk = &risk
+		return s, nil
+	}
+	risk, err := m.c.GetPortfolioRisk(ctx, m.portfolio)
+	if err != nil {
+		return s, err
+	}
+	s.Risk = &risk
+	return s, nil
+}
+
+// Check запросим риски и проверим правила
+// возвращает все текущие нарушения (в том числе уже известные)
+func (m *RiskMonitor) Check(ctx context.Context) ([]RiskBreach, error) {
+	s, err := m.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.last = s
+	breaches := make([]RiskBreach, 0)
+	fresh := make([]RiskBreach, 0)
+	for n, rule := range m.rules {
+		breach, ok := rule(s)
+		if !ok {
+			m.breached[n] = false
+			continue
+		}
+		breach.Snapshot = s
+		breaches = append(breaches, breach)
+		if !m.breached[n] {
+			m.breached[n] = true
+			fresh = append(fresh, breach)
+		}
+	}
+	kill := m.killSwitch && !m.killed && !m.killing && len(breaches) > 0
+	if kill {
+		m.killing = true
+	}
+	onBreach := m.onBreach
+	m.mu.Unlock()
+
+	for _, breach := range fresh {
+		log.Warn("RiskMonitor: нарушено правило", "portfolio", m.portfolio, "breach", breach.String())
+		if onBreach != nil {
+			onBreach(breach)
+		}
+	}
+	if kill {
+		err := m.Kill(ctx)
+		// при ошибке kill switch сработает снова на следующей проверке
+		m.mu.Lock()
+		m.killing = false
+		m.killed = err == nil
+		m.mu.Unlock()
+		if err != nil {
+			return breaches, err
+		}
+	}
+	return breaches, nil
+}
+
+// Kill снимем все заявки и закроем все позиции портфеля по рынку
+func (m *RiskMonitor) Kill(ctx context.Context) error {
+	log.Warn("RiskMonitor: kill switch", "portfolio", m.portfolio)
+	var errs []error
+	report, err := m.c.CancelAllOrders(ctx, m.portfolio)
+	if err != nil {
+		errs = append(errs, err)
+	} else if err = report.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	if err = m.flatten(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// flatten закроем все позиции портфеля рыночными заявками
+// заявка отправляется на биржу позиции; режима торгов в позиции нет, используется основной режим инструмента
+func (m *RiskMonitor) flatten(ctx context.Context) error {
+	positions, err := m.c.GetPositions(ctx, m.portfolio)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, p := range positions {
+		if p.IsCurrency || p.Qty == 0 {
+			continue
+		}
+		side := SideTypeSell
+		if p.Qty < 0 {
+			side = SideTypeBuy
+		}
+		orderID, err := m.c.NewCreateOrderService().
+			Portfolio(m.portfolio).
+			Exchange(p.Exchange).
+			Symbol(p.Symbol).
+			Side(side).
+			OrderType(OrderTypeMarket).
+			Qty(int32(math.Abs(p.Qty))).
+			Comment(riskKillComment).
+			Do(ctx)
+		if err != nil {
+			log.Error("RiskMonitor: ошибка закрытия позиции", "symbol", p.Symbol, "err", err.Error())
+			errs = append(errs, fmt.Errorf("%s: %w", p.Symbol, err))
+			continue
+		}
+		log.Warn("RiskMonitor: позиция закрыта", "symbol", p.Symbol, "qty", p.Qty, "orderID", orderID)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,165 @@
+package alor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRiskMonitorFlattenExchange(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		orders []OrderRequest
+	)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/positions"):
+			_, _ = w.Write([]byte(`[
+				{"symbol":"SBER","exchange":"MOEX","qty":3},
+				{"symbol":"AAPL","exchange":"SPBX","qty":-2},
+				{"symbol":"RUB","exchange":"MOEX","qty":1000,"isCurrency":true},
+				{"symbol":"GAZP","exchange":"MOEX","qty":0}
+			]`))
+		case strings.HasSuffix(r.URL.Path, "/actions/market"):
+			var o OrderRequest
+			_ = json.NewDecoder(r.Body).Decode(&o)
+			mu.Lock()
+			orders = append(orders, o)
+			mu.Unlock()
+			_, _ = w.Write([]byte(`{"message":"success","orderNumber":"1"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	m := c.NewRiskMonitor("D39004")
+	if err := m.flatten(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		symbol, exchange string
+		side             SideType
+		qty              int32
+	}{
+		{"SBER", "MOEX", SideTypeSell, 3},
+		{"AAPL", "SPBX", SideTypeBuy, 2},
+	}
+	if len(orders) != len(want) {
+		t.Fatalf("заявок %d, ожидали %d", len(orders), len(want))
+	}
+	for i, w := range want {
+		o := orders[i]
+		if o.Instrument.Symbol != w.symbol || o.Instrument.Exchange != w.exchange || o.Side != w.side || o.Quantity != w.qty {
+			t.Errorf("заявка %d: %s %s %s %d, ожидали %s %s %s %d", i,
+				o.Instrument.Symbol, o.Instrument.Exchange, o.Side, o.Quantity, w.symbol, w.exchange, w.side, w.qty)
+		}
+		if o.User.Portfolio != "D39004" {
+			t.Errorf("заявка %d: портфель %s", i, o.User.Portfolio)
+		}
+	}
+}
+
+func TestRiskMonitorCheckOnTrade(t *testing.T) {
+	var checks atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/summary") {
+			checks.Add(1)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := c.NewRiskMonitor("D39004").Interval(time.Hour)
+	m.Start(ctx)
+
+	waitChecks := func(n int32) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for checks.Load() < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("проверок %d, ожидали %d", checks.Load(), n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitChecks(1)
+	c.PublishTrade(Trade{Id: "1", Symbol: "SBER"})
+	waitChecks(2)
+
+	// после остановки сделки не запускают проверку
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	c.PublishTrade(Trade{Id: "2", Symbol: "SBER"})
+	time.Sleep(10 * time.Millisecond)
+	if n := checks.Load(); n != 2 {
+		t.Fatalf("проверок после остановки %d, ожидали 2", n)
+	}
+}
+
+func TestRiskMonitorKillRetry(t *testing.T) {
+	var (
+		listings atomic.Int32 // запросы списка заявок (= запуски kill switch)
+		fail     atomic.Bool
+	)
+	fail.Store(true)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/summary"):
+			_, _ = w.Write([]byte(`{"profit":-1000}`))
+		case strings.HasSuffix(r.URL.Path, "/orders"):
+			listings.Add(1)
+			if fail.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`[]`))
+		case strings.HasSuffix(r.URL.Path, "/stoporders"), strings.HasSuffix(r.URL.Path, "/positions"):
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}, WithRetryPolicy(NoRetry()))
+	var breaches atomic.Int32
+	m := c.NewRiskMonitor("D39004", MaxDailyLoss(500)).KillSwitch(true)
+	m.SetOnBreach(func(RiskBreach) { breaches.Add(1) })
+	ctx := context.Background()
+
+	// первый kill switch не удался
+	if _, err := m.Check(ctx); err == nil {
+		t.Fatal("ожидали ошибку kill switch")
+	}
+	if n := listings.Load(); n != 1 {
+		t.Fatalf("запусков kill switch %d, ожидали 1", n)
+	}
+	// нарушение осталось: kill switch повторяется
+	fail.Store(false)
+	if _, err := m.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := listings.Load(); n != 2 {
+		t.Fatalf("запусков kill switch %d, ожидали 2", n)
+	}
+	// после успешного kill switch больше не запускается
+	if _, err := m.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := listings.Load(); n != 2 {
+		t.Fatalf("запусков kill switch %d, ожидали 2", n)
+	}
+	if n := breaches.Load(); n != 1 {
+		t.Fatalf("OnBreach вызван %d раз, ожидали 1", n)
+	}
+	// после Reset снова срабатывает
+	m.Reset()
+	if _, err := m.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := listings.Load(); n != 3 {
+		t.Fatalf("запусков kill switch после Reset %d, ожидали 3", n)
+	}
+}
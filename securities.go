@@ -7,18 +7,24 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"time"
 )
 
 // GetSecurity получить параметры по торговому инструменту
 // TODO использовать паттерн ok (Security, bool, error) ?
 func (c *Client) GetSecurity(ctx context.Context, board, symbol string) (Security, bool, error) {
+	return c.getSecurity(ctx, c.Exchange, board, symbol)
+}
+
+func (c *Client) getSecurity(ctx context.Context, exchange, board, symbol string) (Security, bool, error) {
 	queryURL, _ := url.Parse("/md/v2/Securities")
-	queryURL.Path = path.Join(queryURL.Path, c.Exchange)
+	queryURL.Path = path.Join(queryURL.Path, exchange)
 	queryURL.Path = path.Join(queryURL.Path, symbol)
 
 	r := &request{
 		method:   http.MethodGet,
 		endpoint: queryURL.String(),
+		route:    "/md/v2/Securities/{exchange}/{symbol}",
 	}
 
 	if board != "" {
@@ -41,6 +47,66 @@ func (c *Client) GetSecurity(ctx context.Context, board, symbol string) (Securit
 
 }
 
+// сколько хранить параметры инструмента в кэше
+const securityCacheTTL = time.Hour
+
+// время (MSK), после которого меняются лимиты цен PriceMin/PriceMax:
+// начало утренней сессии фондового рынка, промежуточный и основной клиринг срочного рынка
+var securitySessionStarts = []time.Duration{
+	6*time.Hour + 50*time.Minute,
+	14*time.Hour + 5*time.Minute,
+	19*time.Hour + 5*time.Minute,
+}
+
+// параметры инструмента в кэше
+type cachedSecurity struct {
+	sec     Security
+	expires time.Time
+}
+
+// securityExpires когда устареют параметры инструмента, загруженные в loaded:
+// через securityCacheTTL или в начале следующей сессии (новые лимиты цен), что раньше
+func securityExpires(loaded time.Time) time.Time {
+	expires := loaded.Add(securityCacheTTL)
+	msk := loaded.In(TzMsk)
+	day := time.Date(msk.Year(), msk.Month(), msk.Day(), 0, 0, 0, 0, TzMsk)
+	for _, d := range []time.Time{day, day.AddDate(0, 0, 1)} {
+		for _, start := range securitySessionStarts {
+			t := d.Add(start)
+			if t.After(loaded) && t.Before(expires) {
+				return t
+			}
+		}
+	}
+	return expires
+}
+
+// getSecurityCached получить параметры по торговому инструменту из кэша
+// если в кэше нет (или устарели), то запросим с сервера. exchange = "" биржа клиента по умолчанию
+func (c *Client) getSecurityCached(ctx context.Context, exchange, board, symbol string) (Security, bool, error) {
+	if exchange == "" {
+		exchange = c.Exchange
+	}
+	key := exchange + ":" + board + ":" + symbol
+	c.secMu.RLock()
+	cached, ok := c.securities[key]
+	c.secMu.RUnlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.sec, true, nil
+	}
+	sec, ok, err := c.getSecurity(ctx, exchange, board, symbol)
+	if err != nil || !ok {
+		return sec, ok, err
+	}
+	c.secMu.Lock()
+	if c.securities == nil {
+		c.securities = make(map[string]cachedSecurity)
+	}
+	c.securities[key] = cachedSecurity{sec: sec, expires: securityExpires(time.Now())}
+	c.secMu.Unlock()
+	return sec, true, nil
+}
+
 // GetSecurities получить список торговых инструментов
 // Объекты в ответе сортируются по объёму торгов.
 // Если не указано иное значение параметра limit, в ответе возвращается только 25 объектов за раз
@@ -53,6 +119,7 @@ func (c *Client) GetSecurities(ctx context.Context, opts ...Option) ([]Security,
 	r := &request{
 		method:   http.MethodGet,
 		endpoint: "/md/v2/Securities",
+		route:    "/md/v2/Securities",
 	}
 	// если входящая биржа пустая, берем по умолчанию
 	if params.Exchange == "" {
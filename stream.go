@@ -1,15 +1,59 @@
 package alor
 
+import "sync"
+
 type CandleCloseFunc func(candle Candle)
 type QuoteFunc func(quote Quote)
 type OrderFunc func(order Order)
+type AllTradeFunc func(trade AllTrade)
+type TradeFunc func(trade Trade)
 
 //type DataFeedConsumer func(Candle)
 
 type Stream struct {
-	OnCandle CandleCloseFunc // Функция обработки появления новой свечи
-	OnQuote  QuoteFunc       // Функция обработки появления котировки
-	OnOrder  OrderFunc       // Функция обработки появления заявках
+	OnCandle   CandleCloseFunc // Функция обработки появления новой свечи
+	OnQuote    QuoteFunc       // Функция обработки появления котировки
+	OnOrder    OrderFunc       // Функция обработки появления заявках
+	OnAllTrade AllTradeFunc    // Функция обработки появления сделки в ленте всех сделок
+	OnTrade    TradeFunc       // Функция обработки появления сделки по портфелю
+
+	mu             sync.RWMutex          // защита списков функций
+	nextID         int                   // номер следующей зарегистрированной функции
+	orderCallbacks []callback[OrderFunc] // Дополнительные функции обработки заявок (RegisterOnOrder)
+	quoteCallbacks []callback[QuoteFunc] // Дополнительные функции обработки котировок (RegisterOnQuote)
+	tradeCallbacks []callback[TradeFunc] // Дополнительные функции обработки сделок (RegisterOnTrade)
+}
+
+// callback зарегистрированная функция (id для отмены регистрации)
+type callback[F any] struct {
+	id int
+	f  F
+}
+
+// registerCallback добавим функцию в список
+// возвращает функцию для отмены регистрации
+func registerCallback[F any](s *Stream, list *[]callback[F], f F) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID
+	s.nextID++
+	*list = append(*list, callback[F]{id: id, f: f})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			// новый срез: Publish* перебирает старый без блокировки
+			callbacks := make([]callback[F], 0, len(*list))
+			for _, cb := range *list {
+				if cb.id != id {
+					callbacks = append(callbacks, cb)
+				}
+			}
+			*list = callbacks
+		})
+	}
 }
 
 // SetOnCandle регистрирует функцию для вызова OnCandleClosed
@@ -27,6 +71,16 @@ func (s *Stream) SetOnOrder(f OrderFunc) {
 	s.OnOrder = f
 }
 
+// SetOnAllTrade регистрирует функцию для вызова OnAllTrade
+func (s *Stream) SetOnAllTrade(f AllTradeFunc) {
+	s.OnAllTrade = f
+}
+
+// SetOnTrade регистрирует функцию для вызова OnTrade
+func (s *Stream) SetOnTrade(f TradeFunc) {
+	s.OnTrade = f
+}
+
 // RegisterOnCandleClosed регистрирует функцию для вызова OnCandleClosed
 //func (s *Stream) RegisterOnCandleClosed(f func(candle Candle)) {
 //	s.candleClosedCallbacks = append(s.candleClosedCallbacks, f)
@@ -51,24 +105,91 @@ func (s *Stream) PublishCandleClosed(candle Candle) {
 //	s.quotesCallbacks = append(s.quotesCallbacks, cb)
 //}
 
+// RegisterOnQuote регистрирует дополнительную функцию обработки котировок (в дополнение к OnQuote)
+// возвращает функцию для отмены регистрации
+func (s *Stream) RegisterOnQuote(f QuoteFunc) func() {
+	return registerCallback(s, &s.quoteCallbacks, f)
+}
+
 // PublishQuotes пошлем котировки = тем кто подписался
 func (s *Stream) PublishQuotes(quote Quote) {
-	hasFunction := s.OnQuote != nil
+	s.mu.RLock()
+	callbacks := s.quoteCallbacks
+	s.mu.RUnlock()
+
+	hasFunction := s.OnQuote != nil || len(callbacks) > 0
 	if !hasFunction {
 		log.Error("PublishQuotes: не зарегистрирована функция OnQuote")
 		return
 	}
-	s.OnQuote(quote)
+	if s.OnQuote != nil {
+		s.OnQuote(quote)
+	}
+	for _, cb := range callbacks {
+		cb.f(quote)
+	}
 
 }
 
+// RegisterOnOrder регистрирует дополнительную функцию обработки заявок (в дополнение к OnOrder)
+// возвращает функцию для отмены регистрации
+func (s *Stream) RegisterOnOrder(f OrderFunc) func() {
+	return registerCallback(s, &s.orderCallbacks, f)
+}
+
 // PublishOrder пошлем заявки тем кто подписался
 func (s *Stream) PublishOrder(order Order) {
-	hasFunction := s.OnOrder != nil
+	s.mu.RLock()
+	callbacks := s.orderCallbacks
+	s.mu.RUnlock()
+
+	hasFunction := s.OnOrder != nil || len(callbacks) > 0
 	if !hasFunction {
 		log.Error("PublishOrder: не зарегистрирована функция OnOrder")
 		return
 	}
-	s.OnOrder(order)
+	if s.OnOrder != nil {
+		s.OnOrder(order)
+	}
+	for _, cb := range callbacks {
+		cb.f(order)
+	}
+
+}
+
+// PublishAllTrade пошлем сделку из ленты всех сделок тем кто подписался
+func (s *Stream) PublishAllTrade(trade AllTrade) {
+	hasFunction := s.OnAllTrade != nil
+	if !hasFunction {
+		log.Error("PublishAllTrade: не зарегистрирована функция OnAllTrade")
+		return
+	}
+	s.OnAllTrade(trade)
+
+}
+
+// RegisterOnTrade регистрирует дополнительную функцию обработки сделок (в дополнение к OnTrade)
+// возвращает функцию для отмены регистрации
+func (s *Stream) RegisterOnTrade(f TradeFunc) func() {
+	return registerCallback(s, &s.tradeCallbacks, f)
+}
+
+// PublishTrade пошлем сделки по портфелю тем кто подписался
+func (s *Stream) PublishTrade(trade Trade) {
+	s.mu.RLock()
+	callbacks := s.tradeCallbacks
+	s.mu.RUnlock()
+
+	hasFunction := s.OnTrade != nil || len(callbacks) > 0
+	if !hasFunction {
+		log.Error("PublishTrade: не зарегистрирована функция OnTrade")
+		return
+	}
+	if s.OnTrade != nil {
+		s.OnTrade(trade)
+	}
+	for _, cb := range callbacks {
+		cb.f(trade)
+	}
 
 }
@@ -0,0 +1,117 @@
+package alor
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// форматы строкового времени, которые может вернуть сервер
+// дробная часть секунд разбирается автоматически (2024-05-23T10:32:55.0000000Z)
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// граница между Unix time seconds и Unix time milliseconds
+const unixMilliThreshold = 1e11
+
+// Time время (UTC) с разбором JSON в любом формате сервера:
+// строка RFC3339, Unix time seconds или milliseconds (число или строка).
+// null и пустая строка = нулевое время (IsZero)
+type Time struct {
+	time.Time
+}
+
+// NewTime создать Time из time.Time
+func NewTime(t time.Time) Time {
+	return Time{Time: t}
+}
+
+// UnixTime создать Time из Unix time (секунды или миллисекунды)
+func UnixTime(value int64) Time {
+	if value == 0 {
+		return Time{}
+	}
+	if value > unixMilliThreshold || value < -unixMilliThreshold {
+		return Time{Time: time.UnixMilli(value).UTC()}
+	}
+	return Time{Time: time.Unix(value, 0).UTC()}
+}
+
+// MSK вернем время в Московской зоне
+func (t Time) MSK() time.Time {
+	return t.Time.In(TzMsk)
+}
+
+// String вернем время в формате RFC3339 (пустая строка для нулевого времени)
+func (t Time) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Time.Format(time.RFC3339Nano)
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		t.Time = time.Time{}
+		return nil
+	}
+	// число = Unix time
+	if data[0] != '"' {
+		return t.parseUnix(string(data))
+	}
+	s, err := strconv.Unquote(string(data))
+	if err != nil {
+		return err
+	}
+	return t.parse(s)
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(strconv.Quote(t.Time.UTC().Format(time.RFC3339Nano))), nil
+}
+
+// parse разбор строкового времени
+func (t *Time) parse(s string) error {
+	if s == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+	for _, layout := range timeLayouts {
+		// время без зоны считаем UTC
+		if v, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			t.Time = v
+			return nil
+		}
+	}
+	if err := t.parseUnix(s); err == nil {
+		return nil
+	}
+	return fmt.Errorf("не поддерживаемый формат времени %q", s)
+}
+
+// parseUnix разбор Unix time (секунды или миллисекунды, возможно с дробной частью)
+func (t *Time) parseUnix(s string) error {
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*t = UnixTime(v)
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("не поддерживаемый формат времени %q", s)
+	}
+	if v > unixMilliThreshold || v < -unixMilliThreshold {
+		t.Time = time.UnixMicro(int64(v * 1e3)).UTC()
+		return nil
+	}
+	t.Time = time.UnixMicro(int64(v * 1e6)).UTC()
+	return nil
+}
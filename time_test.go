@@ -0,0 +1,106 @@
+package alor
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimeUnmarshalJSON(t *testing.T) {
+	want := time.Date(2024, 5, 23, 10, 32, 55, 0, time.UTC)
+	tests := []struct {
+		json    string
+		want    time.Time
+		wantErr bool
+	}{
+		// RFC3339 и другие строковые форматы
+		{`"2024-05-23T10:32:55Z"`, want, false},
+		{`"2024-05-23T10:32:55.0000000Z"`, want, false},
+		{`"2024-05-23T10:32:55.123Z"`, want.Add(123 * time.Millisecond), false},
+		{`"2024-05-23T13:32:55+03:00"`, want, false},
+		{`"2024-05-23T10:32:55"`, want, false},
+		{`"2024-05-23 10:32:55"`, want, false},
+		{`"2024-05-23"`, time.Date(2024, 5, 23, 0, 0, 0, 0, time.UTC), false},
+		// Unix time: секунды и миллисекунды (граница 1e11)
+		{`1716460375`, want, false},
+		{`1716460375000`, want, false},
+		{`1716460375123`, want.Add(123 * time.Millisecond), false},
+		{`"1716460375"`, want, false},
+		{`"1716460375000"`, want, false},
+		{`1716460375.5`, want.Add(500 * time.Millisecond), false},
+		{`1716460375000.5`, want.Add(500 * time.Microsecond), false},
+		{`99999999999`, time.Unix(99999999999, 0).UTC(), false},
+		{`100000000001`, time.UnixMilli(100000000001).UTC(), false},
+		// нулевое время
+		{`null`, time.Time{}, false},
+		{`""`, time.Time{}, false},
+		{`0`, time.Time{}, false},
+		// ошибки
+		{`"вчера"`, time.Time{}, true},
+		{`true`, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		// начальное значение не должно оставаться после null и пустой строки
+		got := NewTime(time.Now())
+		err := json.Unmarshal([]byte(tt.json), &got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ошибка %v, ожидали ошибку: %v", tt.json, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if !got.Equal(tt.want) || (tt.want.IsZero() && !got.IsZero()) {
+			t.Errorf("%s: %v, ожидали %v", tt.json, got.Time, tt.want)
+		}
+	}
+}
+
+func TestTimeStruct(t *testing.T) {
+	var v struct {
+		Time Time  `json:"t"`
+		Ptr  *Time `json:"p"`
+	}
+	if err := json.Unmarshal([]byte(`{"t":1716460375000,"p":null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Time.Unix() != 1716460375 || v.Ptr != nil {
+		t.Fatalf("%+v", v)
+	}
+}
+
+func TestTimeMarshalJSON(t *testing.T) {
+	tests := []struct {
+		time Time
+		want string
+	}{
+		{Time{}, `null`},
+		{NewTime(time.Date(2024, 5, 23, 13, 32, 55, 0, TzMsk)), `"2024-05-23T10:32:55Z"`},
+		{UnixTime(1716460375123), `"2024-05-23T10:32:55.123Z"`},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.time)
+		if err != nil || string(data) != tt.want {
+			t.Errorf("%v: %s (%v), ожидали %s", tt.time.Time, data, err, tt.want)
+		}
+		// обратный разбор
+		var back Time
+		if err := json.Unmarshal(data, &back); err != nil || !back.Equal(tt.time.Time) {
+			t.Errorf("%s: %v (%v)", data, back.Time, err)
+		}
+	}
+}
+
+func TestTimeMSK(t *testing.T) {
+	tm := UnixTime(1716460375)
+	msk := tm.MSK()
+	if msk.Hour() != 13 || msk.Minute() != 32 || !msk.Equal(tm.Time) {
+		t.Fatalf("MSK %v", msk)
+	}
+	if _, offset := msk.Zone(); offset != 3*60*60 {
+		t.Fatalf("смещение MSK %d", offset)
+	}
+	if tm.String() != "2024-05-23T10:32:55Z" || (Time{}).String() != "" {
+		t.Fatalf("String %q", tm.String())
+	}
+}
@@ -0,0 +1,228 @@
+package alor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TokenSource источник access (JWT) токена
+// Token вызывается, когда у клиента нет действующего токена (или пора его обновить)
+// и должен вернуть свежий токен
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc функция как TokenSource (например для тестов)
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// источник, которому нужен клиент (адрес сервера авторизации, http клиент)
+type clientTokenSource interface {
+	bindClient(c *Client)
+}
+
+// источник, который может (или не может) выдать новый токен
+// для источников без этого метода считаем, что токен обновляется
+type refreshableTokenSource interface {
+	canRefresh() bool
+}
+
+// canRefreshToken может ли источник выдать новый токен (нужно ли фоновое обновление)
+func canRefreshToken(source TokenSource) bool {
+	if r, ok := source.(refreshableTokenSource); ok {
+		return r.canRefresh()
+	}
+	return true
+}
+
+// RefreshTokenSource получение JWT токена по refresh токену (POST /refresh на сервере авторизации)
+type RefreshTokenSource struct {
+	refreshToken string
+	c            *Client
+}
+
+// NewRefreshTokenSource источник токена по refresh токену
+func NewRefreshTokenSource(refreshToken string) *RefreshTokenSource {
+	return &RefreshTokenSource{refreshToken: refreshToken}
+}
+
+func (s *RefreshTokenSource) bindClient(c *Client) {
+	if s.c == nil {
+		s.c = c
+	}
+}
+
+func (s *RefreshTokenSource) Token(ctx context.Context) (string, error) {
+	if s.c == nil {
+		return "", errors.New("RefreshTokenSource: не задан клиент")
+	}
+	r := &request{
+		method:           http.MethodPost,
+		endpoint:         "/refresh",
+		route:            "/refresh",
+		notAuthorization: true, // Проставить обязательно. Иначе будет ошибка
+	}
+	r.baseURL = s.c.oauthURL
+	r.setParam("token", s.refreshToken)
+
+	var result JSResp
+	data, err := s.c.callAPI(ctx, r)
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения JWT токена: %w", err)
+	}
+	if err = json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("ошибка получения JWT токена: %w", err)
+	}
+	return result.AccessToken, nil
+}
+
+// StaticTokenSource всегда возвращает один и тот же access токен
+// (токен получен снаружи библиотеки, обновлять его некому)
+type StaticTokenSource string
+
+// токен не меняется: фоновое обновление не нужно
+func (s StaticTokenSource) canRefresh() bool {
+	return false
+}
+
+func (s StaticTokenSource) Token(ctx context.Context) (string, error) {
+	if s == "" {
+		return "", errors.New("StaticTokenSource: пустой токен")
+	}
+	return string(s), nil
+}
+
+// время ожидания блокировки файла кэша токена
+const (
+	fileTokenLockTimeout = 30 * time.Second
+	fileTokenLockStale   = time.Minute // блокировку старше минуты считаем брошенной
+	fileTokenLockRetry   = 100 * time.Millisecond
+)
+
+// файл кэша токена
+type fileToken struct {
+	AccessToken string    `json:"accessToken"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+// FileTokenSource кэш токена в файле, общий для нескольких процессов
+// Если в файле есть действующий токен, он используется. Иначе токен берется из source
+// (под блокировкой файла, чтобы токен обновлял только один процесс) и записывается в файл
+type FileTokenSource struct {
+	path   string
+	source TokenSource
+}
+
+// NewFileTokenSource кэш токена в файле path поверх источника source
+func NewFileTokenSource(path string, source TokenSource) *FileTokenSource {
+	return &FileTokenSource{path: path, source: source}
+}
+
+func (s *FileTokenSource) bindClient(c *Client) {
+	if b, ok := s.source.(clientTokenSource); ok {
+		b.bindClient(c)
+	}
+}
+
+func (s *FileTokenSource) canRefresh() bool {
+	return canRefreshToken(s.source)
+}
+
+func (s *FileTokenSource) Token(ctx context.Context) (string, error) {
+	if token, ok := s.read(); ok {
+		return token, nil
+	}
+	unlock, err := s.lock(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	// пока ждали блокировку, токен мог обновить другой процесс
+	if token, ok := s.read(); ok {
+		return token, nil
+	}
+	token, err := s.source.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err = s.write(fileToken{AccessToken: token, Expiry: jwtExpiry(token)}); err != nil {
+		log.Error("FileTokenSource: ошибка записи токена", "path", s.path, "err", err.Error())
+	}
+	return token, nil
+}
+
+// read прочитаем действующий токен из файла
+func (s *FileTokenSource) read() (string, bool) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", false
+	}
+	var t fileToken
+	if err = json.Unmarshal(data, &t); err != nil {
+		return "", false
+	}
+	if t.AccessToken == "" || time.Now().Add(jwtRenewBefore).After(t.Expiry) {
+		return "", false
+	}
+	return t.AccessToken, true
+}
+
+// write запишем токен в файл (через временный файл, чтобы другие процессы не прочитали половину)
+func (s *FileTokenSource) write(t fileToken) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0o600); err == nil {
+		_, err = tmp.Write(data)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// lock заблокируем обновление токена (файл path.lock)
+func (s *FileTokenSource) lock(ctx context.Context) (func(), error) {
+	lockPath := s.path + ".lock"
+	ctx, cancel := context.WithTimeout(ctx, fileTokenLockTimeout)
+	defer cancel()
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		// процесс, взявший блокировку, мог завершиться не сняв ее
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > fileTokenLockStale {
+			_ = os.Remove(lockPath)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("FileTokenSource: не удалось заблокировать %s: %w", lockPath, ctx.Err())
+		case <-time.After(fileTokenLockRetry):
+		}
+	}
+}
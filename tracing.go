@@ -0,0 +1,112 @@
+package alor
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// имя библиотеки для OpenTelemetry
+const tracerName = "github.com/Ruvad39/go-alor"
+
+// WithTracerProvider включим трассировку OpenTelemetry
+// Спаны: GetJWT, каждый REST запрос (callAPI), создание/изменение заявок, подключение и подписка websocket.
+// Родительский спан берется из ctx пользователя.
+// Для тестов можно использовать sdktrace.NewTracerProvider(sdktrace.WithSyncer(tracetest.NewInMemoryExporter()))
+func WithTracerProvider(provider trace.TracerProvider) ClientOption {
+	return func(c *Client) {
+		c.tracer = provider.Tracer(tracerName, trace.WithInstrumentationVersion(libraryVersion))
+	}
+}
+
+// defaultTracer по умолчанию трассировка выключена
+func defaultTracer() trace.Tracer {
+	return noop.NewTracerProvider().Tracer(tracerName)
+}
+
+// startSpan начнем спан (дочерний к спану из ctx)
+func (c *Client) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := c.tracer
+	if tracer == nil {
+		tracer = defaultTracer()
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan завершим спан с ошибкой (если она есть)
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// injectTraceContext передадим контекст трассировки серверу (заголовок traceparent)
+// формат заголовков задается глобально: otel.SetTextMapPropagator
+func (c *Client) injectTraceContext(ctx context.Context, header http.Header) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// endOrderSpan завершим спан заявки (номер заявки в атрибуте alor.order.id)
+func endOrderSpan(span trace.Span, orderID string, err error) {
+	if err == nil && orderID != "" {
+		span.SetAttributes(orderIDAttribute(orderID))
+	}
+	endSpan(span, err)
+}
+
+// номер заявки
+func orderIDAttribute(orderID string) attribute.KeyValue {
+	return attribute.String("alor.order.id", orderID)
+}
+
+// атрибуты подписки websocket
+func wsAttributes(r IwsRequest) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("alor.ws.guid", r.GetGuid()),
+		attribute.String("alor.ws.opcode", r.GetOpCode()),
+		attribute.String("alor.symbol", r.GetCode()),
+	}
+}
+
+// атрибуты команды websocket
+func wsCommandAttributes(guid, opcode string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("alor.ws.guid", guid),
+		attribute.String("alor.ws.opcode", opcode),
+	}
+}
+
+// атрибуты ответа на команду websocket
+func wsResponseAttributes(httpCode int, orderNumber string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int("alor.ws.http_code", httpCode),
+		orderIDAttribute(orderNumber),
+	}
+}
+
+// размер входящего сообщения
+func wsMessageSize(size int) attribute.KeyValue {
+	return attribute.Int("alor.ws.message_size", size)
+}
+
+// атрибуты заявки
+func orderAttributes(orderType OrderType, symbol string, side SideType, qty int32, portfolio string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("alor.order.type", string(orderType)),
+		attribute.String("alor.symbol", symbol),
+		attribute.String("alor.side", string(side)),
+		attribute.Int("alor.qty", int(qty)),
+		attribute.String("alor.portfolio", portfolio),
+	}
+}
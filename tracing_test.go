@@ -0,0 +1,201 @@
+package alor
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTestTracer провайдер трассировки, спаны которого пишутся в память
+// на время теста включает передачу заголовка traceparent
+func newTestTracer(t *testing.T) (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTextMapPropagator(prev)
+		_ = tp.Shutdown(context.Background())
+	})
+	return tp, exporter
+}
+
+// findSpan найдем завершенный спан по имени
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("нет спана %s", name)
+	return tracetest.SpanStub{}
+}
+
+func spanAttr(s tracetest.SpanStub, key string) attribute.Value {
+	for _, a := range s.Attributes {
+		if string(a.Key) == key {
+			return a.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracingCreateOrder(t *testing.T) {
+	tp, exporter := newTestTracer(t)
+	var (
+		mu          sync.Mutex
+		traceparent []string
+	)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		traceparent = append(traceparent, r.Header.Get("traceparent"))
+		if len(traceparent) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"message":"success","orderNumber":"18995978560"}`))
+	}, WithTracerProvider(tp), WithRetryPolicy(fastRetry(2)))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "test")
+	orderID, err := c.NewCreateOrderService().Symbol("SBER").Side(SideTypeBuy).OrderType(OrderTypeLimit).
+		Qty(2).Price(250).Do(ctx)
+	parent.End()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	root := findSpan(t, spans, "test")
+	order := findSpan(t, spans, "alor.CreateOrder")
+	call := findSpan(t, spans, "alor.callAPI")
+	jwt := findSpan(t, spans, "alor.GetJWT")
+
+	// иерархия: test -> CreateOrder -> callAPI -> GetJWT
+	if order.Parent.SpanID() != root.SpanContext.SpanID() {
+		t.Error("CreateOrder не дочерний к спану пользователя")
+	}
+	if call.Parent.SpanID() != order.SpanContext.SpanID() {
+		t.Error("callAPI не дочерний к CreateOrder")
+	}
+	if jwt.Parent.SpanID() != call.SpanContext.SpanID() {
+		t.Error("GetJWT не дочерний к callAPI")
+	}
+
+	wantOrder := map[string]attribute.Value{
+		"alor.order.type": attribute.StringValue("limit"),
+		"alor.symbol":     attribute.StringValue("SBER"),
+		"alor.side":       attribute.StringValue("buy"),
+		"alor.qty":        attribute.IntValue(2),
+		"alor.portfolio":  attribute.StringValue("D39004"),
+		"alor.order.id":   attribute.StringValue(orderID),
+	}
+	for key, want := range wantOrder {
+		if got := spanAttr(order, key); got != want {
+			t.Errorf("CreateOrder %s = %v, ожидали %v", key, got.Emit(), want.Emit())
+		}
+	}
+	wantCall := map[string]attribute.Value{
+		"http.request.method": attribute.StringValue(http.MethodPost),
+		"alor.endpoint":       attribute.StringValue("/commandapi/warptrans/TRADE/v2/client/orders/actions/limit"),
+		"http.route":          attribute.StringValue("/commandapi/warptrans/TRADE/v2/client/orders/actions/limit"),
+	}
+	for key, want := range wantCall {
+		if got := spanAttr(call, key); got != want {
+			t.Errorf("callAPI %s = %v, ожидали %v", key, got.Emit(), want.Emit())
+		}
+	}
+
+	// ответ 503, повтор, ответ 200
+	var events []string
+	for _, e := range call.Events {
+		events = append(events, e.Name)
+	}
+	if len(events) != 3 || events[0] != "response" || events[1] != "retry" || events[2] != "response" {
+		t.Errorf("события callAPI %v, ожидали [response retry response]", events)
+	}
+	for _, s := range []tracetest.SpanStub{order, call, jwt} {
+		if s.Status.Code == codes.Error {
+			t.Errorf("спан %s завершен с ошибкой: %s", s.Name, s.Status.Description)
+		}
+	}
+
+	// traceparent: trace id пользователя, родитель - спан callAPI
+	want := "00-" + root.SpanContext.TraceID().String() + "-" + call.SpanContext.SpanID().String() + "-01"
+	for n, got := range traceparent {
+		if got != want {
+			t.Errorf("попытка %d: traceparent = %q, ожидали %q", n+1, got, want)
+		}
+	}
+}
+
+func TestTracingErrors(t *testing.T) {
+	t.Run("CreateOrder", func(t *testing.T) {
+		tp, exporter := newTestTracer(t)
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":"OrderRejected","message":"недостаточно средств"}`))
+		}, WithTracerProvider(tp))
+
+		_, err := c.NewCreateOrderService().Symbol("SBER").Side(SideTypeBuy).OrderType(OrderTypeMarket).
+			Qty(1).Do(context.Background())
+		if err == nil {
+			t.Fatal("ожидали ошибку")
+		}
+		spans := exporter.GetSpans()
+		for _, name := range []string{"alor.CreateOrder", "alor.callAPI"} {
+			s := findSpan(t, spans, name)
+			if s.Status.Code != codes.Error {
+				t.Errorf("спан %s: статус %v, ожидали Error", name, s.Status.Code)
+			}
+			if len(s.Events) == 0 || s.Events[len(s.Events)-1].Name != "exception" {
+				t.Errorf("спан %s: ошибка не записана", name)
+			}
+		}
+		if got := spanAttr(findSpan(t, spans, "alor.CreateOrder"), "alor.order.id"); got.Type() != attribute.INVALID {
+			t.Errorf("у отклоненной заявки есть alor.order.id = %v", got.Emit())
+		}
+	})
+
+	t.Run("GetJWT", func(t *testing.T) {
+		tp, exporter := newTestTracer(t)
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("запрос без токена")
+		}, WithTracerProvider(tp), WithTokenSource(StaticTokenSource("")))
+
+		if _, err := c.GetOrders(context.Background(), "D39004"); err == nil {
+			t.Fatal("ожидали ошибку получения токена")
+		}
+		spans := exporter.GetSpans()
+		for _, name := range []string{"alor.GetJWT", "alor.callAPI"} {
+			if s := findSpan(t, spans, name); s.Status.Code != codes.Error {
+				t.Errorf("спан %s: статус %v, ожидали Error", name, s.Status.Code)
+			}
+		}
+	})
+}
+
+func TestTracingDisabled(t *testing.T) {
+	_, _ = newTestTracer(t)
+	var traceparent string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		_, _ = w.Write([]byte(`[]`))
+	})
+	if _, err := c.GetOrders(context.Background(), "D39004"); err != nil {
+		t.Fatal(err)
+	}
+	if traceparent != "" {
+		t.Fatalf("traceparent без трассировки: %q", traceparent)
+	}
+}
@@ -49,9 +49,9 @@ type Quote struct {
 	OpenInterest         int64   `json:"open_interest"`        // Открытый интерес (open interest). Если не поддерживается инструментом — значение 0 или null
 	OrderBookMSTimestamp int64   `json:"ob_ms_timestamp"`      // Временная метка (UTC) сообщения о состоянии биржевого стакана в формате Unix Time Milliseconds
 	Type                 string  `json:"type"`                 // Полное название фьючерса
-	//Change               float64 `json:"change"`               // Разность цены и цены предыдущего закрытия
-	//ChangePercent        float64 `json:"change_percent"`       // Относительное изменение цены
-	//AccruedInt           float64 `json:"accruedInt"`           // Начислено (НКД)
+	Change               float64 `json:"change"`               // Разность цены и цены предыдущего закрытия (Heavy, Slim)
+	ChangePercent        float64 `json:"change_percent"`       // Относительное изменение цены (Heavy, Slim)
+	AccruedInt           float64 `json:"accruedInt"`           // Начислено (НКД) (Heavy, Slim)
 }
 
 // FaceValue
@@ -65,9 +65,15 @@ func (q Quote) LastTime() time.Time {
 	//return time.Unix(q.LastPriceTimestamp, 0)
 }
 
-//func (q Quote) LastTime2() time.Time {
-//	return time.Unix(q.OrderBookMSTimestamp, 0)
-//}
+// LastPriceTime время последней сделки (UTC)
+func (q Quote) LastPriceTime() Time {
+	return UnixTime(q.LastPriceTimestamp)
+}
+
+// OrderBookTime время состояния биржевого стакана (UTC)
+func (q Quote) OrderBookTime() Time {
+	return UnixTime(q.OrderBookMSTimestamp)
+}
 
 // Interval период свечей
 type Interval string
@@ -130,6 +136,11 @@ type Candle struct {
 	High     float64  `json:"high"`     // Максимальная цена
 	Low      float64  `json:"low"`      // Минимальная цена
 	Volume   int32    `json:"volume"`   // Объём
+	// заполняются только для баров, построенных из ленты сделок (BarAggregator)
+	OpenInterest int64 `json:"oi,omitempty"`         // Открытый интерес на закрытии бара
+	BuyVolume    int32 `json:"buyVolume,omitempty"`  // Объём сделок на покупку
+	SellVolume   int32 `json:"sellVolume,omitempty"` // Объём сделок на продажу
+	Trades       int32 `json:"trades,omitempty"`     // Кол-во сделок в баре
 }
 
 // GeTime вернем время начала свечи в формате time.Time
@@ -267,9 +278,9 @@ type Order struct {
 	Type           OrderType   `json:"type"`           // Тип заявки limit - Лимитная заявка market - Рыночная заявка
 	Side           SideType    `json:"side"`           // Направление сделки. buy — Купля sell — Продажа
 	Status         OrderStatus `json:"status"`         // статус заявки
-	TransitionTime string      `json:"transTime"`      // Дата и время выставления (UTC)
-	UpdateTime     string      `json:"updateTime"`     // Дата и время изменения статуса заявки (UTC)
-	EndTime        string      `json:"endTime"`        // Дата и время завершения (UTC)
+	TransitionTime Time        `json:"transTime"`      // Дата и время выставления (UTC)
+	UpdateTime     Time        `json:"updateTime"`     // Дата и время изменения статуса заявки (UTC)
+	EndTime        Time        `json:"endTime"`        // Дата и время завершения (UTC)
 	QtyUnits       int32       `json:"qtyUnits"`       // Количество (штуки)
 	QtyBatch       int32       `json:"qtyBatch"`       // Количество (лоты)
 	Qty            int32       `json:"qty"`            // Количество (лоты)
@@ -287,24 +298,55 @@ func IsActiveOrder(o Order) bool {
 	return o.Status == OrderStatusWorking
 }
 
+// StopOrder стоп-заявка (stop/stopLimit)
+type StopOrder struct {
+	Order
+	StopPrice float64       `json:"stopPrice"` // Стоп-цена
+	Condition ConditionType `json:"condition"` // Условие срабатывания стоп/стоп-лимитной заявки
+}
+
 // структура сделки
 type Trade struct {
-	Id           string    `json:"id"`           // Уникальный идентификатор сделки
-	OrderNo      string    `json:"orderNo"`      // Уникальный идентификатор заявки
-	Comment      string    `json:"comment"`      // Пользовательский комментарий к заявке
-	Symbol       string    `json:"symbol"`       // Тикер (Код финансового инструмента).
-	BrokerSymbol string    `json:"brokerSymbol"` // Пара Биржа:Тикер
-	Exchange     string    `json:"exchange"`     // Биржа
-	Date         time.Time `json:"date"`         // Дата и время завершения (UTC)
-	Board        string    `json:"board"`        // Код режима торгов (Борд):
-	QtyUnits     int32     `json:"qtyUnits"`     // Количество (штуки)
-	QtyBatch     int       `json:"qtyBatch"`     // Количество (лоты)
-	Qty          int       `json:"qty"`          // Количество (лоты)
-	Price        float64   `json:"price"`        // Цена
-	AccruedInt   int       `json:"accruedInt"`   // Начислено (НКД)
-	Side         string    `json:"side"`         // Направление сделки:
-	Existing     bool      `json:"existing"`     // True — для данных из "снепшота", то есть из истории. False — для новых событий
-	Commission   float64   `json:"commission"`   // Суммарная комиссия (null для Срочного рынка)
-	Volume       float64   `json:"volume"`       // Объём, рассчитанный по средней цене
+	Id           string  `json:"id"`           // Уникальный идентификатор сделки
+	OrderNo      string  `json:"orderNo"`      // Уникальный идентификатор заявки
+	Comment      string  `json:"comment"`      // Пользовательский комментарий к заявке
+	Symbol       string  `json:"symbol"`       // Тикер (Код финансового инструмента).
+	BrokerSymbol string  `json:"brokerSymbol"` // Пара Биржа:Тикер
+	Exchange     string  `json:"exchange"`     // Биржа
+	Date         Time    `json:"date"`         // Дата и время завершения (UTC)
+	Board        string  `json:"board"`        // Код режима торгов (Борд):
+	QtyUnits     int32   `json:"qtyUnits"`     // Количество (штуки)
+	QtyBatch     int     `json:"qtyBatch"`     // Количество (лоты)
+	Qty          int     `json:"qty"`          // Количество (лоты)
+	Price        float64 `json:"price"`        // Цена
+	AccruedInt   int     `json:"accruedInt"`   // Начислено (НКД)
+	Side         string  `json:"side"`         // Направление сделки:
+	Existing     bool    `json:"existing"`     // True — для данных из "снепшота", то есть из истории. False — для новых событий
+	Commission   float64 `json:"commission"`   // Суммарная комиссия (null для Срочного рынка)
+	Volume       float64 `json:"volume"`       // Объём, рассчитанный по средней цене
 	//RepoSpecificFields interface{} `json:"repoSpecificFields"` // Специальные поля для сделок РЕПО
 }
+
+// AllTrade сделка из ленты всех сделок по инструменту (AllTradesGetAndSubscribe)
+type AllTrade struct {
+	ID           int64    `json:"id"`        // Уникальный идентификатор сделки
+	OrderNo      int64    `json:"orderno"`   // Уникальный идентификатор заявки
+	Symbol       string   `json:"symbol"`    // Тикер (Код финансового инструмента)
+	Board        string   `json:"board"`     // Код режима торгов (Борд)
+	Qty          int32    `json:"qty"`       // Количество (лоты)
+	Price        float64  `json:"price"`     // Цена
+	Timestamp    int64    `json:"timestamp"` // Время (UTC) в формате Unix Time Milliseconds
+	Side         SideType `json:"side"`      // Направление сделки: buy — Купля sell — Продажа
+	OpenInterest int64    `json:"oi"`        // Открытый интерес (open interest). Если не поддерживается инструментом — значение 0 или null
+	Existing     bool     `json:"existing"`  // True — для данных из "снепшота", то есть из истории. False — для новых событий
+}
+
+// GetTime вернем время сделки в формате time.Time (Московское время)
+func (t *AllTrade) GetTime() time.Time {
+	return time.UnixMilli(t.Timestamp).In(TzMsk)
+}
+
+// Time вернем время сделки (UTC)
+func (t *AllTrade) Time() Time {
+	return UnixTime(t.Timestamp)
+}
@@ -0,0 +1,463 @@
+package alor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*
+	Торговые команды через WebSocket (wss://api.alor.ru/cws)
+	После подключения нужно авторизоваться командой authorize.
+	Дальше можно посылать команды на создание, изменение и снятие заявок:
+	create:market, create:limit, create:stop, create:stopLimit
+	update:market, update:limit, update:stop, update:stopLimit
+	delete:market, delete:limit, delete:stop, delete:stopLimit
+	На каждую команду приходит ответ с тем же значением guid (requestGuid)
+
+{
+  "opcode": "create:limit",
+  "guid": "c328fcf1-e495-408a-a0ed-e20f95d6b813",
+  "side": "buy",
+  "quantity": 1,
+  "price": 191.33,
+  "instrument": {
+    "symbol": "SBER",
+    "exchange": "MOEX"
+  },
+  "user": {
+    "portfolio": "D39004"
+  },
+  "timeInForce": "oneday"
+}
+
+{
+  "requestGuid": "c328fcf1-e495-408a-a0ed-e20f95d6b813",
+  "httpCode": 200,
+  "message": "An order '18995978560' has been created.",
+  "orderNumber": "18995978560"
+}
+*/
+
+var (
+	ErrCommandNotConnected   = errors.New("command websocket: нет соединения")
+	ErrCommandConnectionLost = errors.New("command websocket: соединение разорвано, статус команды неизвестен")
+)
+
+// WsCommandResponse ответ на торговую команду
+type WsCommandResponse struct {
+	WsMessage
+	OrderNumber string `json:"orderNumber"` // Номер заявки
+}
+
+// результат команды для ожидающей горутины
+type wsCommandResult struct {
+	resp WsCommandResponse
+	err  error
+}
+
+// команда авторизации
+type wsAuthorizeCommand struct {
+	OpCode string `json:"opcode"`
+	Guid   string `json:"guid"`
+	Token  string `json:"token"`
+}
+
+// команда создания/изменения рыночной или лимитной заявки
+type wsOrderCommand struct {
+	OpCode  string `json:"opcode"`
+	Guid    string `json:"guid"`
+	OrderID string `json:"orderId,omitempty"`
+	*OrderRequest
+}
+
+// команда создания/изменения stop/stopLimit заявки
+type wsOrderStopCommand struct {
+	OpCode  string `json:"opcode"`
+	Guid    string `json:"guid"`
+	OrderID string `json:"orderId,omitempty"`
+	*OrderStopRequest
+}
+
+// команда снятия заявки
+type wsDeleteCommand struct {
+	OpCode   string `json:"opcode"`
+	Guid     string `json:"guid"`
+	OrderID  string `json:"orderId"`
+	Exchange string `json:"exchange"`
+	User     User   `json:"user"`
+}
+
+// CommandConnection соединение для отправки торговых команд через websocket
+// Авторизуется один раз, при разрыве соединения переподключается автоматически
+type CommandConnection struct {
+	c          *Client                         // Ссылка на основного клиента
+	mu         sync.Mutex                      // защита conn, token и pending
+	writeMu    sync.Mutex                      // запись в websocket только из одной горутины
+	conn       *websocket.Conn                 // текущее соединение
+	token      string                          // токен, с которым прошла авторизация
+	pending    map[string]chan wsCommandResult // ожидающие ответа команды (по guid)
+	CloseC     chan struct{}                   // CloseC сигнальный канал для закрытия коннекта
+	ReconnectC chan struct{}                   // ReconnectC сигнальный канал для необходимости реконекта
+	closeOnce  sync.Once
+	coolDown   time.Duration // пауза перед переподключением
+}
+
+// метка соединения команд в метриках
+const wsCommandOpCode = "command"
+
+// NewCommandConnection создать соединение для отправки торговых команд
+func (c *Client) NewCommandConnection() *CommandConnection {
+	return &CommandConnection{
+		c:          c,
+		pending:    make(map[string]chan wsCommandResult),
+		CloseC:     make(chan struct{}),
+		ReconnectC: make(chan struct{}, 1),
+		coolDown:   reconnectCoolDownPeriod,
+	}
+}
+
+// Connect создаем соединение, авторизуемся и запускаем реконнект в отдельной горутине
+func (cc *CommandConnection) Connect(ctx context.Context) error {
+	err := cc.dialAndAuthorize(ctx)
+	if err != nil {
+		return err
+	}
+	// после обновления токена авторизуемся заново
+	unregister := cc.c.onTokenRefresh(func(string) {
+		cc.mu.Lock()
+		connected := cc.conn != nil
+		cc.mu.Unlock()
+		if !connected {
+			return
+		}
+		if err := cc.authorize(ctx); err != nil {
+			log.Error("CommandConnection: повторная авторизация", "err", err.Error())
+		}
+	})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-cc.CloseC:
+		}
+		unregister()
+	}()
+	go cc.reconnector(ctx)
+	return nil
+}
+
+// Close закроем соединение
+func (cc *CommandConnection) Close() {
+	cc.closeOnce.Do(func() {
+		close(cc.CloseC)
+		cc.mu.Lock()
+		conn := cc.conn
+		cc.conn = nil
+		cc.mu.Unlock()
+		if conn != nil {
+			_ = conn.Close()
+		}
+		cc.failPending(ErrCommandNotConnected)
+	})
+}
+
+// Reconnect в сигнальный канал рекконета пошлем сообщение
+func (cc *CommandConnection) Reconnect() {
+	select {
+	case cc.ReconnectC <- struct{}{}:
+	default:
+	}
+}
+
+func (cc *CommandConnection) reconnector(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			cc.Close()
+			return
+
+		case <-cc.CloseC:
+			return
+
+		case <-cc.ReconnectC:
+			log.Warn("CommandConnection: принят сигнал reconnect",
+				"период восстановления повторного подключения", cc.coolDown,
+			)
+			time.Sleep(cc.coolDown)
+
+			log.Warn("CommandConnection: re-connecting...")
+			cc.c.metrics.WsReconnect(wsCommandOpCode)
+			if err := cc.dialAndAuthorize(ctx); err != nil {
+				log.Error("CommandConnection: re-connect error, try to reconnect later", "err", err.Error())
+				cc.Reconnect()
+			}
+		}
+	}
+}
+
+// dialAndAuthorize создаем соединение, запускаем чтение ответов и авторизуемся
+func (cc *CommandConnection) dialAndAuthorize(ctx context.Context) (err error) {
+	ctx, span := cc.c.startSpan(ctx, "alor.ws.command.connect")
+	defer func() { endSpan(span, err) }()
+
+	conn, _, err := cc.c.dialer.DialContext(ctx, cc.c.wsCommandURL, nil)
+	if err != nil {
+		log.Error("CommandConnection websocket.Dial", "err", err.Error())
+		return err
+	}
+	cc.c.metrics.WsConnection(wsCommandOpCode, 1)
+	cc.mu.Lock()
+	cc.conn = conn
+	cc.token = ""
+	cc.mu.Unlock()
+
+	go cc.read(conn)
+
+	return cc.authorize(ctx)
+}
+
+// authorize авторизуемся текущим токеном (если он изменился)
+func (cc *CommandConnection) authorize(ctx context.Context) error {
+	token, err := cc.c.getJWT(ctx)
+	if err != nil {
+		return err
+	}
+	cc.mu.Lock()
+	authorized := cc.token == token
+	cc.mu.Unlock()
+	if authorized {
+		return nil
+	}
+	cmd := &wsAuthorizeCommand{
+		OpCode: "authorize",
+		Guid:   cc.c.getRequestID(),
+		Token:  token,
+	}
+	_, err = cc.send(ctx, cmd.Guid, cmd.OpCode, cmd)
+	if err != nil {
+		return fmt.Errorf("CommandConnection: ошибка авторизации: %w", err)
+	}
+	cc.mu.Lock()
+	cc.token = token
+	cc.mu.Unlock()
+	return nil
+}
+
+// read чтение ответов. При ошибке все ожидающие команды завершаются с ошибкой
+func (cc *CommandConnection) read(conn *websocket.Conn) {
+	defer cc.c.metrics.WsConnection(wsCommandOpCode, -1)
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			select {
+			case <-cc.CloseC:
+				return
+			default:
+			}
+			log.Error("CommandConnection ReadMessage", "err", err.Error())
+			_ = conn.Close()
+			cc.mu.Lock()
+			current := cc.conn == conn
+			if current {
+				cc.conn = nil
+			}
+			cc.mu.Unlock()
+			// соединение уже заменено новым
+			if !current {
+				return
+			}
+			cc.failPending(ErrCommandConnectionLost)
+			cc.Reconnect()
+			return
+		}
+		message = cc.c.wsFrame(WsFrameIncoming, "", true, message)
+		if debugEnabled() {
+			log.Debug("CommandConnection", "message", string(message))
+		}
+		cc.c.metrics.WsMessage(wsCommandOpCode)
+		resp := WsCommandResponse{}
+		if err = json.Unmarshal(message, &resp); err != nil {
+			log.Error("CommandConnection json.Unmarshal", "err", err.Error())
+			cc.c.metrics.WsDecodeError(wsCommandOpCode)
+			continue
+		}
+		cc.mu.Lock()
+		ch, ok := cc.pending[resp.RequestGuid]
+		delete(cc.pending, resp.RequestGuid)
+		cc.mu.Unlock()
+		if !ok {
+			log.Warn("CommandConnection: ответ на неизвестную команду", "guid", resp.RequestGuid)
+			continue
+		}
+		ch <- wsCommandResult{resp: resp}
+	}
+}
+
+// failPending завершим все ожидающие команды с ошибкой
+func (cc *CommandConnection) failPending(err error) {
+	cc.mu.Lock()
+	pending := cc.pending
+	cc.pending = make(map[string]chan wsCommandResult)
+	cc.mu.Unlock()
+	for _, ch := range pending {
+		ch <- wsCommandResult{err: err}
+	}
+}
+
+// send пошлем команду и дождемся ответа с тем же guid
+func (cc *CommandConnection) send(ctx context.Context, guid, opcode string, cmd any) (resp WsCommandResponse, err error) {
+	ctx, span := cc.c.startSpan(ctx, "alor.ws.command", wsCommandAttributes(guid, opcode)...)
+	defer func() { endSpan(span, err) }()
+
+	buf, err := json.Marshal(cmd)
+	if err != nil {
+		return WsCommandResponse{}, err
+	}
+	ch := make(chan wsCommandResult, 1)
+
+	cc.mu.Lock()
+	conn := cc.conn
+	if conn == nil {
+		cc.mu.Unlock()
+		return WsCommandResponse{}, ErrCommandNotConnected
+	}
+	cc.pending[guid] = ch
+	cc.mu.Unlock()
+
+	buf = cc.c.wsFrame(WsFrameOutgoing, guid, true, buf)
+	cc.writeMu.Lock()
+	err = conn.WriteMessage(websocket.TextMessage, buf)
+	cc.writeMu.Unlock()
+	if err != nil {
+		cc.mu.Lock()
+		delete(cc.pending, guid)
+		cc.mu.Unlock()
+		log.Error("CommandConnection WriteMessage", "guid", guid, "err", err.Error())
+		return WsCommandResponse{}, err
+	}
+
+	select {
+	case <-ctx.Done():
+		cc.mu.Lock()
+		delete(cc.pending, guid)
+		cc.mu.Unlock()
+		return WsCommandResponse{}, ctx.Err()
+	case result := <-ch:
+		// соединение разорвано до получения ответа
+		if result.err != nil {
+			return WsCommandResponse{}, result.err
+		}
+		resp = result.resp
+		span.AddEvent("response", trace.WithAttributes(wsResponseAttributes(resp.HttpCode, resp.OrderNumber)...))
+		if err := resp.Err(); err != nil {
+			apiErr := err.(*APIError)
+			apiErr.OrderNumber = resp.OrderNumber
+			return resp, apiErr
+		}
+		return resp, nil
+	}
+}
+
+// sendCommand перед командой проверим авторизацию (токен мог обновиться)
+func (cc *CommandConnection) sendCommand(ctx context.Context, guid, opcode string, cmd any) (WsCommandResponse, error) {
+	if err := cc.authorize(ctx); err != nil {
+		return WsCommandResponse{}, err
+	}
+	return cc.send(ctx, guid, opcode, cmd)
+}
+
+// CreateOrder создать рыночную или лимитную заявку
+// возвращает ID созданной заявки
+func (cc *CommandConnection) CreateOrder(ctx context.Context, order OrderRequest) (string, error) {
+	return cc.orderCommand(ctx, "create", "", order)
+}
+
+// UpdateOrder изменить рыночную или лимитную заявку
+// возвращает ID новой заявки
+func (cc *CommandConnection) UpdateOrder(ctx context.Context, orderID string, order OrderRequest) (string, error) {
+	return cc.orderCommand(ctx, "update", orderID, order)
+}
+
+// CreateOrderStop создать stop/stopLimit заявку
+// возвращает ID созданной заявки
+func (cc *CommandConnection) CreateOrderStop(ctx context.Context, order OrderStopRequest) (string, error) {
+	return cc.orderStopCommand(ctx, "create", "", order)
+}
+
+// UpdateOrderStop изменить stop/stopLimit заявку
+// возвращает ID новой заявки
+func (cc *CommandConnection) UpdateOrderStop(ctx context.Context, orderID string, order OrderStopRequest) (string, error) {
+	return cc.orderStopCommand(ctx, "update", orderID, order)
+}
+
+// CancelOrder отменить заявку указанного типа
+func (cc *CommandConnection) CancelOrder(ctx context.Context, orderType OrderType, portfolio, orderID string) (bool, error) {
+	cmd := &wsDeleteCommand{
+		OpCode:   "delete:" + string(orderType),
+		Guid:     cc.c.getRequestID(),
+		OrderID:  orderID,
+		Exchange: cc.c.Exchange,
+		User:     User{Portfolio: portfolio},
+	}
+	_, err := cc.sendCommand(ctx, cmd.Guid, cmd.OpCode, cmd)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (cc *CommandConnection) orderCommand(ctx context.Context, action, orderID string, order OrderRequest) (newOrderID string, err error) {
+	ctx, span := cc.c.startSpan(ctx, "alor.ws.order", orderAttributes(order.OrderType, order.Instrument.Symbol, order.Side, order.Quantity, order.User.Portfolio)...)
+	defer func() {
+		endOrderSpan(span, newOrderID, err)
+		cc.c.metrics.OrderResult("ws.order", err)
+	}()
+
+	// как и в CreateOrderService.Do: все что не лимитная = рыночная
+	orderType := OrderTypeMarket
+	if order.OrderType == OrderTypeLimit {
+		orderType = OrderTypeLimit
+	}
+	cmd := &wsOrderCommand{
+		OpCode:       action + ":" + string(orderType),
+		Guid:         cc.c.getRequestID(),
+		OrderID:      orderID,
+		OrderRequest: &order,
+	}
+	resp, err := cc.sendCommand(ctx, cmd.Guid, cmd.OpCode, cmd)
+	if err != nil {
+		return "", err
+	}
+	return resp.OrderNumber, nil
+}
+
+func (cc *CommandConnection) orderStopCommand(ctx context.Context, action, orderID string, order OrderStopRequest) (newOrderID string, err error) {
+	ctx, span := cc.c.startSpan(ctx, "alor.ws.orderStop", orderAttributes(order.OrderType, order.Instrument.Symbol, order.Side, order.Quantity, order.User.Portfolio)...)
+	defer func() {
+		endOrderSpan(span, newOrderID, err)
+		cc.c.metrics.OrderResult("ws.orderStop", err)
+	}()
+
+	// как и в CreateOrderStopService.Do: все что не stop = stopLimit
+	orderType := OrderTypeStopLimit
+	if order.OrderType == OrderTypeStop {
+		orderType = OrderTypeStop
+	}
+	cmd := &wsOrderStopCommand{
+		OpCode:           action + ":" + string(orderType),
+		Guid:             cc.c.getRequestID(),
+		OrderID:          orderID,
+		OrderStopRequest: &order,
+	}
+	resp, err := cc.sendCommand(ctx, cmd.Guid, cmd.OpCode, cmd)
+	if err != nil {
+		return "", err
+	}
+	return resp.OrderNumber, nil
+}
@@ -0,0 +1,229 @@
+package alor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsTestCommand торговая команда, принятая тестовым сервером
+type wsTestCommand struct {
+	OpCode string  `json:"opcode"`
+	Guid   string  `json:"guid"`
+	Token  string  `json:"token"`
+	Price  float64 `json:"price"`
+}
+
+// commandServer websocket сервер торговых команд
+// на authorize отвечает успехом, остальные команды передает onCommand
+// (вызывается в горутине соединения; false = закрыть соединение)
+type commandServer struct {
+	mu        sync.Mutex
+	conns     int
+	closed    chan struct{}
+	onCommand func(conn *websocket.Conn, cmd wsTestCommand) bool
+}
+
+func newCommandServer(t *testing.T, onCommand func(conn *websocket.Conn, cmd wsTestCommand) bool) (*commandServer, string) {
+	t.Helper()
+	s := &commandServer{closed: make(chan struct{}, 10), onCommand: onCommand}
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.mu.Lock()
+		s.conns++
+		s.mu.Unlock()
+		for {
+			var cmd wsTestCommand
+			if err := conn.ReadJSON(&cmd); err != nil {
+				s.closed <- struct{}{}
+				return
+			}
+			if cmd.OpCode == "authorize" {
+				_ = conn.WriteJSON(map[string]any{"requestGuid": cmd.Guid, "httpCode": 200, "message": "Handled successfully"})
+				continue
+			}
+			if !s.onCommand(conn, cmd) {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return s, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func (s *commandServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+// replyOrder ответ на команду: номер заявки = цена команды
+func replyOrder(conn *websocket.Conn, cmd wsTestCommand) {
+	_ = conn.WriteJSON(map[string]any{
+		"requestGuid": cmd.Guid,
+		"httpCode":    200,
+		"orderNumber": strconv.FormatFloat(cmd.Price, 'f', -1, 64),
+	})
+}
+
+func newCommandConnection(t *testing.T, ctx context.Context, url string) *CommandConnection {
+	t.Helper()
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {}, WithCommandWSURL(url))
+	cc := c.NewCommandConnection()
+	cc.coolDown = time.Millisecond
+	if err := cc.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cc.Close)
+	return cc
+}
+
+func limitOrder(price float64) OrderRequest {
+	return OrderRequest{
+		OrderType:  OrderTypeLimit,
+		Side:       SideTypeBuy,
+		Quantity:   1,
+		Price:      price,
+		Instrument: Instrument{Symbol: "SBER", Exchange: "MOEX"},
+		User:       User{Portfolio: "D39004"},
+	}
+}
+
+// TestCommandConnectionGuid ответы приходят в другом порядке: каждая команда получает свой ответ по guid
+func TestCommandConnectionGuid(t *testing.T) {
+	var held []wsTestCommand
+	_, url := newCommandServer(t, func(conn *websocket.Conn, cmd wsTestCommand) bool {
+		if cmd.Price == 0 {
+			_ = conn.WriteJSON(map[string]any{"requestGuid": cmd.Guid, "httpCode": 400, "message": "bad price", "orderNumber": "0"})
+			return true
+		}
+		// дождемся двух команд и ответим в обратном порядке
+		held = append(held, cmd)
+		if len(held) == 2 {
+			replyOrder(conn, held[1])
+			replyOrder(conn, held[0])
+		}
+		return true
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cc := newCommandConnection(t, ctx, url)
+
+	var wg sync.WaitGroup
+	for _, price := range []float64{100, 200} {
+		wg.Add(1)
+		go func(price float64) {
+			defer wg.Done()
+			orderID, err := cc.CreateOrder(ctx, limitOrder(price))
+			if err != nil {
+				t.Errorf("цена %v: %v", price, err)
+				return
+			}
+			if want := strconv.FormatFloat(price, 'f', -1, 64); orderID != want {
+				t.Errorf("цена %v: номер заявки %s, ожидали %s", price, orderID, want)
+			}
+		}(price)
+	}
+	wg.Wait()
+
+	// ответ с ошибкой: APIError с номером заявки
+	_, err := cc.CreateOrder(ctx, limitOrder(0))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != 400 || apiErr.OrderNumber != "0" {
+		t.Fatalf("ошибка %v, ожидали APIError 400", err)
+	}
+}
+
+// TestCommandConnectionLost соединение разорвано до ответа: команда завершается с ErrCommandConnectionLost,
+// соединение восстанавливается и следующая команда проходит
+func TestCommandConnectionLost(t *testing.T) {
+	var drop sync.Once
+	srv, url := newCommandServer(t, func(conn *websocket.Conn, cmd wsTestCommand) bool {
+		dropped := false
+		drop.Do(func() { dropped = true })
+		if dropped {
+			return false
+		}
+		replyOrder(conn, cmd)
+		return true
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cc := newCommandConnection(t, ctx, url)
+
+	if _, err := cc.CreateOrder(ctx, limitOrder(100)); !errors.Is(err, ErrCommandConnectionLost) {
+		t.Fatalf("ошибка %v, ожидали ErrCommandConnectionLost", err)
+	}
+	// дождемся переподключения
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		orderID, err := cc.CreateOrder(ctx, limitOrder(200))
+		if err == nil {
+			if orderID != "200" {
+				t.Fatalf("номер заявки %s, ожидали 200", orderID)
+			}
+			break
+		}
+		if !errors.Is(err, ErrCommandNotConnected) || time.Now().After(deadline) {
+			t.Fatalf("после переподключения: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := srv.connections(); n != 2 {
+		t.Fatalf("подключений %d, ожидали 2", n)
+	}
+}
+
+// TestCommandConnectionCancel отмена ctx соединения закрывает его и завершает ожидающие команды
+func TestCommandConnectionCancel(t *testing.T) {
+	received := make(chan struct{}, 1)
+	srv, url := newCommandServer(t, func(conn *websocket.Conn, cmd wsTestCommand) bool {
+		received <- struct{}{} // не отвечаем
+		return true
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cc := newCommandConnection(t, ctx, url)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := cc.CreateOrder(context.Background(), limitOrder(100))
+		result <- err
+	}()
+	<-received
+	cancel()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrCommandNotConnected) {
+			t.Fatalf("ошибка %v, ожидали ErrCommandNotConnected", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("команда не завершилась после отмены ctx")
+	}
+	select {
+	case <-srv.closed:
+	case <-time.After(time.Second):
+		t.Fatal("соединение не закрыто после отмены ctx")
+	}
+	if _, err := cc.CreateOrder(context.Background(), limitOrder(200)); !errors.Is(err, ErrCommandNotConnected) {
+		t.Fatalf("ошибка %v, ожидали ErrCommandNotConnected", err)
+	}
+	// после закрытия не переподключается
+	time.Sleep(20 * time.Millisecond)
+	if n := srv.connections(); n != 1 {
+		t.Fatalf("подключений %d, ожидали 1", n)
+	}
+}
@@ -0,0 +1,136 @@
+package alor
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// максимальный размер буфера, который возвращается в пул (большие снепшоты не держим в памяти)
+const wsMaxPooledBuffer = 1 << 20
+
+// wsBufferPool буферы для чтения websocket сообщений
+var wsBufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+func getWsBuffer() *bytes.Buffer {
+	buf := wsBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putWsBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > wsMaxPooledBuffer {
+		return
+	}
+	wsBufferPool.Put(buf)
+}
+
+// readWsMessage прочитаем следующее сообщение в buf
+func readWsMessage(conn *websocket.Conn, buf *bytes.Buffer) error {
+	_, r, err := conn.NextReader()
+	if err != nil {
+		return err
+	}
+	_, err = buf.ReadFrom(r)
+	return err
+}
+
+// wsEnvelope сообщение подписки с данными типа T
+// данные разбираются сразу в модель (за один проход, без json.RawMessage)
+type wsEnvelope[T any] struct {
+	Data      *T     `json:"data"` // Данные по ответу
+	Guid      string `json:"guid"` // Уникальный идентификатор запроса
+	WsMessage        // Системное сообщение
+}
+
+// wsDataField поле данных в сообщении подписки
+var wsDataField = []byte(`"data"`)
+
+// wsMessageDecoder разбор сообщений подписки (выбирается один раз по opcode и формату)
+type wsMessageDecoder interface {
+	// decode разберем сообщение и передадим данные в обработчик
+	// системное сообщение (httpCode != 0) возвращается в msg, обработчик не вызывается
+	decode(message []byte) (msg WsMessage, err error)
+}
+
+// wsDecoder разбор сообщений с данными типа T
+type wsDecoder[T any] struct {
+	pool   sync.Pool // *wsEnvelope[T]
+	handle func(data *T)
+}
+
+func newWsDecoder[T any](handle func(data *T)) *wsDecoder[T] {
+	return &wsDecoder[T]{
+		pool: sync.Pool{
+			New: func() any {
+				return &wsEnvelope[T]{Data: new(T)}
+			},
+		},
+		handle: handle,
+	}
+}
+
+func (d *wsDecoder[T]) decode(message []byte) (WsMessage, error) {
+	env := d.pool.Get().(*wsEnvelope[T])
+	defer d.pool.Put(env)
+
+	// json.Unmarshal не обнуляет поля, которых нет в сообщении
+	data := env.Data
+	if data == nil {
+		data = new(T)
+	} else {
+		var zero T
+		*data = zero
+	}
+	*env = wsEnvelope[T]{Data: data}
+
+	if err := json.Unmarshal(message, env); err != nil {
+		return WsMessage{}, err
+	}
+	if env.HttpCode != 0 {
+		return env.WsMessage, nil
+	}
+	// "data": null или сообщение без данных
+	// (json.Unmarshal не трогает Data, если поля нет: проверим по тексту сообщения)
+	if env.Data == nil || !bytes.Contains(message, wsDataField) {
+		return WsMessage{}, nil
+	}
+	d.handle(env.Data)
+	return WsMessage{}, nil
+}
+
+// wsDecoderFor разбор сообщений модели T в формате format
+// для формата Slim данные разбираются в структуру S и переводятся в модель
+func wsDecoderFor[S slimModel[T], T any](format Format, handle func(T)) wsMessageDecoder {
+	if format == FormatSlim {
+		return newWsDecoder(func(s *S) { handle((*s).model()) })
+	}
+	return newWsDecoder(func(v *T) { handle(*v) })
+}
+
+// newDecoder выберем разбор сообщений по коду операции подписки
+func (s *WsService) newDecoder() wsMessageDecoder {
+	format := s.WsRequest.GetFormat()
+	switch s.WsRequest.GetOpCode() {
+	case OnCandleSubscribe:
+		return wsDecoderFor[candleSlim](format, s.onCandle)
+	case onQuotesSubscribe:
+		return wsDecoderFor[quoteSlim](format, s.onQuote)
+	case onOrdersSubscribe:
+		return wsDecoderFor[orderSlim](format, s.onOrder)
+	case onAllTradesSubscribe:
+		return wsDecoderFor[allTradeSlim](format, s.onAllTrade)
+	case onTradesSubscribe:
+		return wsDecoderFor[tradeSlim](format, s.onTrade)
+	default:
+		return newWsDecoder(func(*json.RawMessage) {
+			log.Error("WsService.handler", "guid", s.WsRequest.GetGuid(), "OpCode неизвеcтен", s.WsRequest.GetOpCode())
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package alor
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// сообщения подписок (как их присылает сервер)
+var (
+	benchQuoteSimple = []byte(`{"data":{"symbol":"SBER","exchange":"MOEX","description":"Сбербанк России ПАО ао","prev_close_price":263.5,"last_price":262.59,"open_price":263.28,"high_price":264.79,"low_price":262.14,"ask":262.6,"bid":262.59,"ask_vol":5,"bid_vol":14,"total_ask_vol":3156426,"total_bid_vol":1580212,"last_price_timestamp":1668086820,"lotsize":10,"lotvalue":2625.9,"facevalue":3,"open_interest":0,"ob_ms_timestamp":1668086821327,"type":"Обыкновенные акции"},"guid":"quote|SBER"}`)
+	benchQuoteSlim   = []byte(`{"data":{"sym":"SBER","ex":"MOEX","desc":"Сбербанк России ПАО ао","pcp":263.5,"c":262.59,"o":263.28,"h":264.79,"l":262.14,"ak":262.6,"bd":262.59,"akv":5,"bdv":14,"tak":3156426,"tbd":1580212,"lpt":1668086820,"ls":10,"lv":2625.9,"fv":3,"oi":0,"obts":1668086821327,"t":"Обыкновенные акции"},"guid":"quote|SBER"}`)
+	benchCandle      = []byte(`{"data":{"time":1668086820,"close":262.59,"open":263.28,"high":264.79,"low":262.14,"volume":6384},"guid":"candle|SBER|60"}`)
+	benchAllTrade    = []byte(`{"data":{"id":7512283470,"orderno":32784627138,"symbol":"SBER","board":"TQBR","qty":3,"price":262.59,"timestamp":1668086820123,"side":"buy","oi":0,"existing":false},"guid":"alltrades|SBER"}`)
+	benchOrder       = []byte(`{"data":{"id":"18995978560","symbol":"SBER","brokerSymbol":"MOEX:SBER","exchange":"MOEX","portfolio":"D39004","comment":"","type":"limit","side":"buy","status":"working","transTime":"2022-11-10T13:27:00.000Z","updateTime":"2022-11-10T13:27:00.000Z","endTime":"2022-11-10T20:50:00.000Z","qtyUnits":10,"qtyBatch":1,"qty":1,"filledQtyUnits":0,"filledQtyBatch":0,"filled":0,"price":262.5,"existing":false,"timeInForce":"oneday","volume":2625},"guid":"orders|D39004"}`)
+)
+
+// newBenchService сервис подписки без соединения (сообщения передаются прямо в handler)
+func newBenchService(b *testing.B, opcode string, format Format) *WsService {
+	b.Helper()
+	c := NewClient("")
+	c.SetOnQuote(func(Quote) {})
+	c.SetOnCandle(func(Candle) {})
+	c.SetOnAllTrade(func(AllTrade) {})
+	c.SetOnOrder(func(Order) {})
+	return c.NewWsService(&WSRequestBase{OpCode: opcode, Code: "SBER", Guid: opcode, Format: format})
+}
+
+func benchHandler(b *testing.B, s *WsService, message []byte) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(message)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.handler(message)
+	}
+}
+
+// benchTwoPass прежний разбор: сначала WSResponse с json.RawMessage, затем данные в модель
+func benchTwoPass[T any](b *testing.B, message []byte) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(message)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		msg := new(WSResponse)
+		if err := json.Unmarshal(message, msg); err != nil {
+			b.Fatal(err)
+		}
+		var v T
+		if err := json.Unmarshal(*msg.Data, &v); err != nil {
+			b.Fatal(err)
+		}
+		_ = string(message) // строка для лога (раньше строилась и при выключенном Debug)
+	}
+}
+
+func BenchmarkWsQuoteTwoPass(b *testing.B) {
+	benchTwoPass[Quote](b, benchQuoteSimple)
+}
+
+func BenchmarkWsQuoteSimple(b *testing.B) {
+	var got Quote
+	s := newBenchService(b, onQuotesSubscribe, FormatSimple)
+	s.c.SetOnQuote(func(q Quote) { got = q })
+	benchHandler(b, s, benchQuoteSimple)
+	if got.LastPrice != 262.59 || got.Symbol != "SBER" {
+		b.Fatalf("не верно разобрана котировка: %+v", got)
+	}
+}
+
+func BenchmarkWsQuoteSlim(b *testing.B) {
+	var got Quote
+	s := newBenchService(b, onQuotesSubscribe, FormatSlim)
+	s.c.SetOnQuote(func(q Quote) { got = q })
+	benchHandler(b, s, benchQuoteSlim)
+	if got.LastPrice != 262.59 || got.Symbol != "SBER" {
+		b.Fatalf("не верно разобрана котировка: %+v", got)
+	}
+}
+
+func BenchmarkWsCandleTwoPass(b *testing.B) {
+	benchTwoPass[Candle](b, benchCandle)
+}
+
+func BenchmarkWsCandle(b *testing.B) {
+	benchHandler(b, newBenchService(b, OnCandleSubscribe, FormatSimple), benchCandle)
+}
+
+func BenchmarkWsAllTradeTwoPass(b *testing.B) {
+	benchTwoPass[AllTrade](b, benchAllTrade)
+}
+
+func BenchmarkWsAllTrade(b *testing.B) {
+	benchHandler(b, newBenchService(b, onAllTradesSubscribe, FormatSimple), benchAllTrade)
+}
+
+func BenchmarkWsOrderTwoPass(b *testing.B) {
+	benchTwoPass[Order](b, benchOrder)
+}
+
+func BenchmarkWsOrder(b *testing.B) {
+	benchHandler(b, newBenchService(b, onOrdersSubscribe, FormatSimple), benchOrder)
+}
+
+// BenchmarkWsQuoteParallel много подписок на котировки одновременно
+func BenchmarkWsQuoteParallel(b *testing.B) {
+	c := NewClient("")
+	c.SetOnQuote(func(Quote) {})
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchQuoteSimple)))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		s := c.NewWsService(&WSRequestBase{OpCode: onQuotesSubscribe, Code: "SBER", Guid: "quote|SBER"})
+		for pb.Next() {
+			s.handler(benchQuoteSimple)
+		}
+	})
+}
+
+// сообщения подписок в остальных форматах
+var (
+	testQuoteHeavy   = []byte(`{"data":{"symbol":"SBER","exchange":"MOEX","description":"Сбербанк России ПАО ао","prev_close_price":263.5,"last_price":262.59,"open_price":263.28,"high_price":264.79,"low_price":262.14,"ask":262.6,"bid":262.59,"ask_vol":5,"bid_vol":14,"total_ask_vol":3156426,"total_bid_vol":1580212,"last_price_timestamp":1668086820,"lotsize":10,"lotvalue":2625.9,"facevalue":3,"open_interest":0,"ob_ms_timestamp":1668086821327,"type":"Обыкновенные акции","change":-0.91,"change_percent":-0.35,"accruedInt":0},"guid":"quote|SBER"}`)
+	testCandleSlim   = []byte(`{"data":{"t":1668086820,"c":262.59,"o":263.28,"h":264.79,"l":262.14,"v":6384},"guid":"candle|SBER|60"}`)
+	testAllTradeSlim = []byte(`{"data":{"id":7512283470,"ono":32784627138,"sym":"SBER","b":"TQBR","q":3,"px":262.59,"t":1668086820123,"s":"buy","oi":0,"h":false},"guid":"alltrades|SBER"}`)
+	testOrderSlim    = []byte(`{"data":{"id":"18995978560","sym":"SBER","tic":"MOEX:SBER","ex":"MOEX","p":"D39004","cmt":"","t":"limit","s":"buy","st":"working","tt":"2022-11-10T13:27:00.000Z","ut":"2022-11-10T13:27:00.000Z","et":"2022-11-10T20:50:00.000Z","qu":10,"qb":1,"q":1,"fqu":0,"fqb":0,"fq":0,"px":262.5,"h":false,"tf":"oneday","v":2625},"guid":"orders|D39004"}`)
+	testTrade        = []byte(`{"data":{"id":"159","orderNo":"18995978560","comment":"","symbol":"SBER","brokerSymbol":"MOEX:SBER","exchange":"MOEX","date":"2022-11-10T13:27:00.000Z","board":"TQBR","qtyUnits":10,"qtyBatch":1,"qty":1,"price":262.5,"accruedInt":0,"side":"buy","existing":false,"commission":0.5,"volume":2625},"guid":"trades|D39004"}`)
+	testTradeSlim    = []byte(`{"data":{"id":"159","ono":"18995978560","cmt":"","sym":"SBER","tic":"MOEX:SBER","ex":"MOEX","d":"2022-11-10T13:27:00.000Z","b":"TQBR","qu":10,"qb":1,"q":1,"px":262.5,"ai":0,"s":"buy","h":false,"c":0.5,"v":2625},"guid":"trades|D39004"}`)
+)
+
+// twoPassDecode прежний разбор: WSResponse с json.RawMessage, затем данные в модель
+// called = false для системного сообщения, "data": null и сообщения без данных
+func twoPassDecode[S slimModel[T], T any](t *testing.T, format Format, message []byte) (v T, msg WsMessage, called bool) {
+	t.Helper()
+	resp := new(WSResponse)
+	if err := json.Unmarshal(message, resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.HttpCode != 0 || resp.Data == nil {
+		return v, resp.WsMessage, false
+	}
+	if err := decodeFormat[S](*resp.Data, format, &v); err != nil {
+		t.Fatal(err)
+	}
+	return v, WsMessage{}, true
+}
+
+// decodeRecorder разбор сообщений с записью переданных в обработчик данных
+type decodeRecorder[T any] struct {
+	decoder wsMessageDecoder
+	got     []T
+}
+
+func newDecodeRecorder[S slimModel[T], T any](format Format) *decodeRecorder[T] {
+	r := &decodeRecorder[T]{}
+	r.decoder = wsDecoderFor[S](format, func(v T) { r.got = append(r.got, v) })
+	return r
+}
+
+// checkDecode разбор за один проход совпадает с прежним разбором
+func checkDecode[S slimModel[T], T any](t *testing.T, format Format, messages ...[]byte) {
+	t.Helper()
+	r := newDecodeRecorder[S](format)
+	for n, message := range messages {
+		want, wantMsg, called := twoPassDecode[S](t, format, message)
+		calls := len(r.got)
+		msg, err := r.decoder.decode(message)
+		if err != nil {
+			t.Fatalf("сообщение %d: %v", n+1, err)
+		}
+		if msg != wantMsg {
+			t.Fatalf("сообщение %d: системное сообщение %+v, ожидали %+v", n+1, msg, wantMsg)
+		}
+		if !called {
+			if len(r.got) != calls {
+				t.Fatalf("сообщение %d: обработчик вызван для %s", n+1, message)
+			}
+			continue
+		}
+		if len(r.got) != calls+1 {
+			t.Fatalf("сообщение %d: обработчик вызван %d раз", n+1, len(r.got)-calls)
+		}
+		if got := r.got[calls]; !reflect.DeepEqual(got, want) {
+			t.Fatalf("сообщение %d:\n разбор   %+v\n ожидали  %+v", n+1, got, want)
+		}
+	}
+}
+
+func TestWsDecoderMatchesTwoPass(t *testing.T) {
+	tests := []struct {
+		name  string
+		check func(t *testing.T)
+	}{
+		{"quote simple", func(t *testing.T) { checkDecode[quoteSlim](t, FormatSimple, benchQuoteSimple) }},
+		{"quote slim", func(t *testing.T) { checkDecode[quoteSlim](t, FormatSlim, benchQuoteSlim) }},
+		{"quote heavy", func(t *testing.T) { checkDecode[quoteSlim](t, FormatHeavy, testQuoteHeavy) }},
+		{"candle simple", func(t *testing.T) { checkDecode[candleSlim](t, FormatSimple, benchCandle) }},
+		{"candle slim", func(t *testing.T) { checkDecode[candleSlim](t, FormatSlim, testCandleSlim) }},
+		{"candle heavy", func(t *testing.T) { checkDecode[candleSlim](t, FormatHeavy, benchCandle) }},
+		{"alltrade simple", func(t *testing.T) { checkDecode[allTradeSlim](t, FormatSimple, benchAllTrade) }},
+		{"alltrade slim", func(t *testing.T) { checkDecode[allTradeSlim](t, FormatSlim, testAllTradeSlim) }},
+		{"alltrade heavy", func(t *testing.T) { checkDecode[allTradeSlim](t, FormatHeavy, benchAllTrade) }},
+		{"order simple", func(t *testing.T) { checkDecode[orderSlim](t, FormatSimple, benchOrder) }},
+		{"order slim", func(t *testing.T) { checkDecode[orderSlim](t, FormatSlim, testOrderSlim) }},
+		{"order heavy", func(t *testing.T) { checkDecode[orderSlim](t, FormatHeavy, benchOrder) }},
+		{"trade simple", func(t *testing.T) { checkDecode[tradeSlim](t, FormatSimple, testTrade) }},
+		{"trade slim", func(t *testing.T) { checkDecode[tradeSlim](t, FormatSlim, testTradeSlim) }},
+		{"trade heavy", func(t *testing.T) { checkDecode[tradeSlim](t, FormatHeavy, testTrade) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, tt.check)
+	}
+}
+
+func TestWsDecoderSystemMessage(t *testing.T) {
+	messages := [][]byte{
+		[]byte(`{"message":"Handled successfully","httpCode":200,"requestGuid":"quote|SBER"}`),
+		[]byte(`{"message":"Invalid JWT token!","httpCode":401,"requestGuid":"quote|SBER"}`),
+		// системное сообщение с данными: данные не обрабатываются
+		[]byte(`{"data":{"symbol":"SBER","last_price":1},"message":"Handled successfully","httpCode":200,"requestGuid":"quote|SBER"}`),
+		[]byte(`{"data":null,"guid":"quote|SBER"}`),
+		[]byte(`{"guid":"quote|SBER"}`),
+		benchQuoteSimple,
+	}
+	checkDecode[quoteSlim](t, FormatSimple, messages...)
+	checkDecode[quoteSlim](t, FormatSlim, append(messages[:len(messages)-1:len(messages)-1], benchQuoteSlim)...)
+
+	r := newDecodeRecorder[quoteSlim](FormatSimple)
+	msg, err := r.decoder.decode(messages[1])
+	if err != nil || msg.HttpCode != 401 || msg.Message != "Invalid JWT token!" || msg.RequestGuid != "quote|SBER" || msg.Err() == nil {
+		t.Fatalf("системное сообщение %+v (%v)", msg, err)
+	}
+	if _, err := r.decoder.decode([]byte(`{"data":`)); err == nil {
+		t.Fatal("ожидали ошибку разбора")
+	}
+}
+
+func TestWsDecoderPoolNoLeak(t *testing.T) {
+	tests := []struct {
+		name  string
+		check func(t *testing.T)
+	}{
+		// после полного сообщения приходит сообщение с частью полей
+		{"quote simple", func(t *testing.T) {
+			checkDecode[quoteSlim](t, FormatSimple, benchQuoteSimple, []byte(`{"data":{"symbol":"GAZP","last_price":160.1},"guid":"quote|GAZP"}`))
+		}},
+		{"quote slim", func(t *testing.T) {
+			checkDecode[quoteSlim](t, FormatSlim, benchQuoteSlim, []byte(`{"data":{"sym":"GAZP","c":160.1},"guid":"quote|GAZP"}`))
+		}},
+		{"order simple", func(t *testing.T) {
+			checkDecode[orderSlim](t, FormatSimple, benchOrder, []byte(`{"data":{"id":"2","status":"filled"},"guid":"orders|D39004"}`))
+		}},
+		{"order slim", func(t *testing.T) {
+			checkDecode[orderSlim](t, FormatSlim, testOrderSlim, []byte(`{"data":{"id":"2","st":"filled"},"guid":"orders|D39004"}`))
+		}},
+		{"trade simple", func(t *testing.T) {
+			checkDecode[tradeSlim](t, FormatSimple, testTrade, []byte(`{"data":{"id":"2"},"guid":"trades|D39004"}`))
+		}},
+		{"alltrade slim", func(t *testing.T) {
+			checkDecode[allTradeSlim](t, FormatSlim, testAllTradeSlim, []byte(`{"data":{"id":2,"px":1},"guid":"alltrades|SBER"}`))
+		}},
+		// системное сообщение и null между сообщениями с данными
+		{"candle", func(t *testing.T) {
+			checkDecode[candleSlim](t, FormatSimple, benchCandle,
+				[]byte(`{"message":"Handled successfully","httpCode":200,"requestGuid":"candle|SBER|60"}`),
+				[]byte(`{"data":null,"guid":"candle|SBER|60"}`),
+				[]byte(`{"data":{"time":1668086880},"guid":"candle|SBER|60"}`))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, tt.check)
+	}
+
+	// данные, переданные в обработчик, не меняются следующим сообщением
+	r := newDecodeRecorder[quoteSlim](FormatSimple)
+	for _, message := range [][]byte{benchQuoteSimple, []byte(`{"data":{"symbol":"GAZP"},"guid":"quote|GAZP"}`)} {
+		if _, err := r.decoder.decode(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(r.got) != 2 || r.got[0].Symbol != "SBER" || r.got[0].LastPrice != 262.59 || r.got[1].LastPrice != 0 {
+		t.Fatalf("данные обработчика: %+v", r.got)
+	}
+}
+
+func TestWsServiceDecoder(t *testing.T) {
+	tests := []struct {
+		opcode string
+		format Format
+		want   wsMessageDecoder
+	}{
+		{onQuotesSubscribe, FormatSimple, &wsDecoder[Quote]{}},
+		{onQuotesSubscribe, FormatHeavy, &wsDecoder[Quote]{}},
+		{onQuotesSubscribe, FormatSlim, &wsDecoder[quoteSlim]{}},
+		{OnCandleSubscribe, FormatSimple, &wsDecoder[Candle]{}},
+		{OnCandleSubscribe, FormatSlim, &wsDecoder[candleSlim]{}},
+		{onOrdersSubscribe, FormatSimple, &wsDecoder[Order]{}},
+		{onOrdersSubscribe, FormatSlim, &wsDecoder[orderSlim]{}},
+		{onAllTradesSubscribe, FormatSimple, &wsDecoder[AllTrade]{}},
+		{onAllTradesSubscribe, FormatSlim, &wsDecoder[allTradeSlim]{}},
+		{onTradesSubscribe, FormatSimple, &wsDecoder[Trade]{}},
+		{onTradesSubscribe, FormatSlim, &wsDecoder[tradeSlim]{}},
+		{"Unknown", FormatSimple, &wsDecoder[json.RawMessage]{}},
+	}
+	c := NewClient("")
+	for _, tt := range tests {
+		s := c.NewWsService(&WSRequestBase{OpCode: tt.opcode, Code: "SBER", Format: tt.format})
+		if got, want := reflect.TypeOf(s.newDecoder()), reflect.TypeOf(tt.want); got != want {
+			t.Errorf("%s %s: разбор %v, ожидали %v", tt.opcode, tt.format, got, want)
+		}
+	}
+}
+
+// slimDecode разбор данных сообщения в формате format
+func slimDecode[S slimModel[T], T any](t *testing.T, format Format, message []byte) T {
+	t.Helper()
+	v, _, called := twoPassDecode[S](t, format, message)
+	if !called {
+		t.Fatalf("нет данных в %s", message)
+	}
+	return v
+}
+
+func TestSlimMatchesSimple(t *testing.T) {
+	tests := []struct {
+		name   string
+		simple any
+		slim   any
+	}{
+		{name: "quote",
+			simple: slimDecode[quoteSlim](t, FormatSimple, benchQuoteSimple),
+			slim:   slimDecode[quoteSlim](t, FormatSlim, benchQuoteSlim)},
+		{name: "candle",
+			simple: slimDecode[candleSlim](t, FormatSimple, benchCandle),
+			slim:   slimDecode[candleSlim](t, FormatSlim, testCandleSlim)},
+		{name: "alltrade",
+			simple: slimDecode[allTradeSlim](t, FormatSimple, benchAllTrade),
+			slim:   slimDecode[allTradeSlim](t, FormatSlim, testAllTradeSlim)},
+		{name: "order",
+			simple: slimDecode[orderSlim](t, FormatSimple, benchOrder),
+			slim:   slimDecode[orderSlim](t, FormatSlim, testOrderSlim)},
+		{name: "trade",
+			simple: slimDecode[tradeSlim](t, FormatSimple, testTrade),
+			slim:   slimDecode[tradeSlim](t, FormatSlim, testTradeSlim)},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.simple, tt.slim) {
+			t.Errorf("%s:\n simple %+v\n slim   %+v", tt.name, tt.simple, tt.slim)
+		}
+	}
+
+	// количество в штуках
+	order := slimDecode[orderSlim](t, FormatSlim, []byte(`{"data":{"id":"1","qu":20,"q":2,"fqu":10,"fq":1},"guid":"orders|D39004"}`))
+	if order.QtyUnits != 20 || order.FilledQtyUnits != 10 || order.Qty != 2 || order.Filled != 1 {
+		t.Errorf("заявка Slim: %+v", order)
+	}
+	trade := slimDecode[tradeSlim](t, FormatSlim, []byte(`{"data":{"id":"1","qu":20,"q":2},"guid":"trades|D39004"}`))
+	if trade.QtyUnits != 20 || trade.Qty != 2 {
+		t.Errorf("сделка Slim: %+v", trade)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/trace"
 	"log/slog"
 	"net/http"
 	"sync"
@@ -33,6 +34,7 @@ type IwsRequest interface {
 	GetGuid() string
 	GetCode() string
 	GetInterval() Interval
+	GetFormat() Format
 	SetToken(token string)
 	SetExchange(exchange string)
 }
@@ -63,7 +65,7 @@ type WSRequestBase struct {
 	Token     string   `json:"token"`          // Access Токен для авторизации запроса
 	Exchange  string   `json:"exchange"`       // Биржа: MOEX — Московская Биржа SPBX — СПБ Биржа
 	Frequency int32    `json:"freq"`           // Максимальная частота отдачи данных сервером в миллисекундах
-	Format    string   `json:"format"`         // Формат представления возвращаемых данных: Simple, Slim, Heavy
+	Format    Format   `json:"format"`         // Формат представления возвращаемых данных: Simple, Slim, Heavy
 	Code      string   `json:"code,omitempty"` // Код финансового инструмента (Тикер)
 	Interval  Interval `json:"tf"`             // Длительность таймфрейма в секундах или код (D — дни, W — недели, M — месяцы, Y — годы)
 	From      int64    `json:"from,omitempty"` // Дата и время (UTC) для первой запрашиваемой свечи
@@ -90,6 +92,9 @@ func (r *WSRequestBase) GetCode() string {
 func (r *WSRequestBase) GetInterval() Interval {
 	return r.Interval
 }
+func (r *WSRequestBase) GetFormat() Format {
+	return r.Format
+}
 func (r *WSRequestBase) SetToken(token string) {
 	r.Token = token
 }
@@ -103,13 +108,32 @@ func WithFrequency(param int32) WSRequestOption {
 	}
 }
 
+// WithDepth глубина стакана или кол-во сделок из истории (для ленты всех сделок)
+func WithDepth(param int32) WSRequestOption {
+	return func(r *WSRequestBase) {
+		r.Depth = param
+	}
+}
+
+// WithSkipHistory Флаг отсеивания исторических данных
+func WithSkipHistory(param bool) WSRequestOption {
+	return func(r *WSRequestBase) {
+		r.SkipHistory = param
+	}
+}
+
 // WSService сервис для подписок
 type WsService struct {
-	c          *Client       // Ссылка на основного клиента
-	WsRequest  IwsRequest    // Структура запроса для подписки (wsRequest)
-	CloseC     chan struct{} // CloseC сигнальный канал для закрытия коннекта
-	ReconnectC chan struct{} // ReconnectC сигнальный канал для необходимости реконекта
-	prevCandle Candle        // Предыдущая свеча (для работы с onCandleSubscribe)
+	c          *Client        // Ссылка на основного клиента
+	WsRequest  IwsRequest     // Структура запроса для подписки (wsRequest)
+	CloseC     chan struct{}  // CloseC сигнальный канал для закрытия коннекта
+	ReconnectC chan struct{}  // ReconnectC сигнальный канал для необходимости реконекта
+	prevCandle Candle         // Предыдущая свеча (для работы с onCandleSubscribe)
+	aggregator *BarAggregator // Построение баров из ленты сделок (для работы с onAllTradesSubscribe)
+	mu         sync.Mutex     // защита conn
+	writeMu    sync.Mutex     // запись в websocket только из одной горутины
+	conn       *websocket.Conn
+	decoder    wsMessageDecoder // Разбор сообщений подписки
 }
 
 func (c *Client) NewWsService(wsRequest IwsRequest) *WsService {
@@ -141,6 +165,16 @@ func (s *WsService) Reconnect() {
 
 // Connect запускаем поток и создаем соединение с websocket
 func (s *WsService) Connect(ctx context.Context) error {
+	// после обновления токена повторим подписку с новым токеном
+	unregister := s.c.onTokenRefresh(s.reauthorize)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.CloseC:
+		}
+		unregister()
+	}()
+
 	err := s.DialAndConnect(ctx)
 	if err != nil {
 		return err
@@ -170,6 +204,7 @@ func (s *WsService) reconnector(ctx context.Context) {
 			time.Sleep(reconnectCoolDownPeriod)
 
 			log.Warn("re-connecting...")
+			s.c.metrics.WsReconnect(s.WsRequest.GetOpCode())
 			if err := s.DialAndConnect(ctx); err != nil {
 				log.Error("re-connect error, try to reconnect later", "guid", s.WsRequest.GetGuid())
 				// re-emit the re-connect signal if error
@@ -181,10 +216,11 @@ func (s *WsService) reconnector(ctx context.Context) {
 
 // DialAndConnect создаем соединение с websocket. Запрашиваем подписку. Вызываем чтение данных
 func (s *WsService) DialAndConnect(ctx context.Context) error {
-	wssURL := getWsEndpoint()
-	conn, resp, err := websocket.DefaultDialer.Dial(wssURL, nil)
+	dialCtx, span := s.c.startSpan(ctx, "alor.ws.connect", wsAttributes(s.WsRequest)...)
+	conn, resp, err := s.c.dialer.DialContext(dialCtx, s.c.wsURL, nil)
 	if err != nil {
 		log.Error("websocket.Dial", "guid", s.WsRequest.GetGuid(), "err", err.Error())
+		endSpan(span, err)
 		return err
 	}
 	// TODO создать и вернуть ошибку
@@ -193,13 +229,29 @@ func (s *WsService) DialAndConnect(ctx context.Context) error {
 		log.Error("websocket.Dial", "guid", s.WsRequest.GetGuid(), "resp.StatusCode", resp.StatusCode)
 	}
 	// пошлем сообщение для подписки
-	err = s.sendMessage(conn)
+	err = s.sendMessage(dialCtx, conn)
+	endSpan(span, err)
 	if err != nil {
 		return err
 	}
+	s.c.metrics.WsConnection(s.WsRequest.GetOpCode(), 1)
+	defer s.c.metrics.WsConnection(s.WsRequest.GetOpCode(), -1)
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		if s.conn == conn {
+			s.conn = nil
+		}
+		s.mu.Unlock()
+	}()
 
-	//connCtx, connCancel := context.WithCancel(ctx)
-	connCtx, _ := context.WithCancel(ctx)
+	// спан подписки живет, пока живет соединение (входящие сообщения - события спана)
+	subCtx, subSpan := s.c.startSpan(ctx, "alor.ws.subscription", wsAttributes(s.WsRequest)...)
+	defer subSpan.End()
+	connCtx, connCancel := context.WithCancel(subCtx)
+	defer connCancel()
 
 	//connCtx, connCancel := s.SetConn(ctx, conn)
 	//s.EmitConnect()
@@ -222,10 +274,27 @@ func (s *WsService) DialAndConnect(ctx context.Context) error {
 	wg.Wait()
 	return nil
 }
-func (s *WsService) sendMessage(conn *websocket.Conn) error {
+
+// reauthorize повторим подписку с новым токеном (на текущем соединении)
+func (s *WsService) reauthorize(token string) {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	log.Debug("reauthorize: новый токен, повторим подписку", "guid", s.WsRequest.GetGuid())
+	if err := s.sendMessage(context.Background(), conn); err != nil {
+		log.Error("reauthorize", "guid", s.WsRequest.GetGuid(), "err", err.Error())
+	}
+}
+
+func (s *WsService) sendMessage(ctx context.Context, conn *websocket.Conn) error {
 	log.Debug("зашли в sendMessage", "guid", s.WsRequest.GetGuid())
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
 	// получим токен доступа
-	token, err := s.c.GetJWT()
+	token, err := s.c.getJWT(ctx)
 	if err != nil {
 		log.Error("sendMessage: ошибка поучение токена доступа", "guid", s.WsRequest.GetGuid(), "err", err.Error())
 		return err
@@ -236,6 +305,7 @@ func (s *WsService) sendMessage(conn *websocket.Conn) error {
 		return err
 	}
 	//log.Debug("sendMessage", slog.Any("buf", buf))
+	buf = s.c.wsFrame(WsFrameOutgoing, s.WsRequest.GetGuid(), false, buf)
 	err = conn.WriteMessage(websocket.TextMessage, buf)
 	if err != nil {
 		log.Error("sendMessage: conn.WriteMessage", "guid", s.WsRequest.GetGuid(), "err", err.Error())
@@ -254,6 +324,7 @@ func (s *WsService) Read(ctx context.Context, conn *websocket.Conn) {
 	//	cancel()
 	//}()
 
+	span := trace.SpanFromContext(ctx)
 	for {
 		select {
 
@@ -262,67 +333,57 @@ func (s *WsService) Read(ctx context.Context, conn *websocket.Conn) {
 		case <-s.CloseC:
 			return
 		default:
-			_, message, err := conn.ReadMessage()
+			// сообщение читаем в буфер из пула (буфер используется повторно после обработки)
+			buf := getWsBuffer()
+			err := readWsMessage(conn, buf)
 			if err != nil {
+				putWsBuffer(buf)
 				log.Error("ReadMessage", "guid", s.WsRequest.GetGuid(), "err", err.Error())
 				// и дальше обрабатываем разные типы ошибок
 				_ = conn.Close()
 				s.Reconnect()
 				return
 			}
+			message := s.c.wsFrame(WsFrameIncoming, s.WsRequest.GetGuid(), false, buf.Bytes())
+			s.c.metrics.WsMessage(s.WsRequest.GetOpCode())
+			if span.IsRecording() {
+				span.AddEvent("message", trace.WithAttributes(wsMessageSize(len(message))))
+			}
 			// пошлем в обработчик
 			s.handler(message)
+			putWsBuffer(buf)
 		}
 	}
 
 }
 
-// TODO создать обработчик по всем событиям
-// или создать буферизированный канал с данными json.RawMessage и посылать данные туда
-// предварительно запустив "НУЖНЫЙ" обработчик данных, где читать этот канал
+// handler разбор сообщения подписки
+// данные разбираются за один проход сразу в модель (разбор выбирается по opcode и формату подписки)
 func (s *WsService) handler(message []byte) {
-	log.Debug("handler", "guid", s.WsRequest.GetGuid(), "message", string(message))
-	msg := new(WSResponse)
-	err := json.Unmarshal(message, msg)
+	if debugEnabled() {
+		log.Debug("handler", "guid", s.WsRequest.GetGuid(), "message", string(message))
+	}
+	if s.decoder == nil {
+		s.decoder = s.newDecoder()
+	}
+	msg, err := s.decoder.decode(message)
 	if err != nil {
 		log.Error("handlerEvent", "guid", s.WsRequest.GetGuid(), "error json.Unmarshal", err.Error())
+		s.c.metrics.WsDecodeError(s.WsRequest.GetOpCode())
 		return
 	}
-	//log.Debug("handler", "msg", msg)
 	// системное сообщение
 	if msg.HttpCode != 0 {
-
-		if msg.HttpCode >= 400 {
-			log.Error("handlerEvent", "guid", s.WsRequest.GetGuid(), "err", msg.String())
+		if err := msg.Err(); err != nil {
+			log.Error("handlerEvent", "guid", s.WsRequest.GetGuid(), "err", err.Error())
 			// закроем обработчик
 			s.Close()
-
 		}
-		return
-	}
-	// иначе информационное сообщение
-	// TODO посылаем данные в канал ?
-	switch s.WsRequest.GetOpCode() {
-	case OnCandleSubscribe:
-		s.onCandle(msg.Data)
-	case onQuotesSubscribe:
-		s.onQuote(msg.Data)
-	case onOrdersSubscribe:
-		s.onOrder(msg.Data)
-	default:
-		log.Error("WsService.handler", "guid", s.WsRequest.GetGuid(), "OpCode неизвеcтен", s.WsRequest.GetOpCode())
 	}
 }
 
 // onCandle обработка handler получения свечей
-func (s *WsService) onCandle(data *json.RawMessage) {
-	candle := Candle{}
-	err := json.Unmarshal(*data, &candle)
-	if err != nil {
-		log.Error("WsService.onCandle", "guid", s.WsRequest.GetGuid(), "json.Unmarshaljson err", err.Error())
-		return
-	}
-
+func (s *WsService) onCandle(candle Candle) {
 	candle.Symbol = s.WsRequest.GetCode()
 	candle.Interval = s.WsRequest.GetInterval()
 
@@ -337,8 +398,12 @@ func (s *WsService) onCandle(data *json.RawMessage) {
 	// # Пришла новая свеча
 	if candle.Time > s.prevCandle.Time {
 		// новая свеча
-		log.Debug("WsService OnCandle", "guid", s.WsRequest.GetGuid(), "time", s.prevCandle.GeTime(), "candle", s.prevCandle)
+		if debugEnabled() {
+			log.Debug("WsService OnCandle", "guid", s.WsRequest.GetGuid(), "time", s.prevCandle.GeTime(), "candle", s.prevCandle)
+		}
+		start := time.Now()
 		s.c.PublishCandleClosed(s.prevCandle) // пошлем в рассылку
+		s.observeCallback(start)
 		s.prevCandle = candle
 
 	}
@@ -346,29 +411,47 @@ func (s *WsService) onCandle(data *json.RawMessage) {
 }
 
 // onQuote  handler обработка  получения котировок
-func (s *WsService) onQuote(data *json.RawMessage) {
-	quote := Quote{}
-	err := json.Unmarshal(*data, &quote)
-	if err != nil {
-		log.Error("WsService.onQuote", "guid", s.WsRequest.GetGuid(), "json.Unmarshaljson err", err.Error())
-		return
-	}
-	//log.Debug("onQuote", slog.Any("Quote", quote))
+func (s *WsService) onQuote(quote Quote) {
+	start := time.Now()
 	s.c.PublishQuotes(quote) // пошлем в рассылку
-
+	s.observeCallback(start)
 }
 
 // onOrder handler обработка получения заявок
-func (s *WsService) onOrder(data *json.RawMessage) {
-	order := Order{}
-	err := json.Unmarshal(*data, &order)
-	if err != nil {
-		log.Error("WsService.onOrder", "guid", s.WsRequest.GetGuid(), "json.Unmarshaljson err", err.Error())
-		return
+func (s *WsService) onOrder(order Order) {
+	if debugEnabled() {
+		log.Debug("onOrder", slog.Any("Order", order))
 	}
-	log.Debug("onOrder", slog.Any("Order", order))
+	start := time.Now()
 	s.c.PublishOrder(order) // пошлем в рассылку
+	s.observeCallback(start)
+}
+
+// onAllTrade handler обработка получения ленты всех сделок
+// если задан агрегатор, то сделка уходит на построение баров
+func (s *WsService) onAllTrade(trade AllTrade) {
+	if s.aggregator != nil {
+		s.aggregator.Add(trade)
+		return
+	}
+	start := time.Now()
+	s.c.PublishAllTrade(trade) // пошлем в рассылку
+	s.observeCallback(start)
+}
+
+// onTrade handler обработка получения сделок по портфелю
+func (s *WsService) onTrade(trade Trade) {
+	if debugEnabled() {
+		log.Debug("onTrade", slog.Any("Trade", trade))
+	}
+	start := time.Now()
+	s.c.PublishTrade(trade) // пошлем в рассылку
+	s.observeCallback(start)
+}
 
+// observeCallback время работы функций обработки данных
+func (s *WsService) observeCallback(start time.Time) {
+	s.c.metrics.ObserveCallback(s.WsRequest.GetOpCode(), time.Since(start))
 }
 
 // Do запуск сервиса
@@ -78,3 +78,77 @@ func (c *Client) SubscribeOrders(ctx context.Context, portfolio string, opts ...
 	s := c.NewWsService(r)
 	return s.Do(ctx)
 }
+
+// SubscribeTrades подписка на получение информации о всех сделках с участием указанного портфеля
+func (c *Client) SubscribeTrades(ctx context.Context, portfolio string, opts ...WSRequestOption) error {
+	r := &WSRequestBase{
+		OpCode:    onTradesSubscribe,
+		Portfolio: portfolio,
+		Exchange:  c.Exchange,
+	}
+	// обработаем входящие параметры
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.Guid = "trades|" + r.Portfolio
+
+	s := c.NewWsService(r)
+	return s.Do(ctx)
+}
+
+// SubscribeAllTrades подписка на ленту всех сделок по инструменту
+func (c *Client) SubscribeAllTrades(ctx context.Context, symbol string, opts ...WSRequestOption) error {
+	_, ok, err := c.GetSecurity(ctx, "", symbol)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("инструмент %s не найден", symbol)
+	}
+	r := &WSRequestBase{
+		OpCode:   onAllTradesSubscribe,
+		Code:     symbol,
+		Exchange: c.Exchange,
+	}
+	// обработаем входящие параметры
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.Guid = "alltrades|" + r.Code
+
+	s := c.NewWsService(r)
+	return s.Do(ctx)
+}
+
+// SubscribeBars подписка на бары, построенные из ленты всех сделок
+// (временные, тиковые, объемные, range). Закрытые бары приходят в OnCandle
+// Сделки из истории (см. WithDepth) используются для построения первых баров
+func (c *Client) SubscribeBars(ctx context.Context, symbol string, spec BarSpec, opts ...WSRequestOption) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	sec, ok, err := c.GetSecurity(ctx, "", symbol)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("инструмент %s не найден", symbol)
+	}
+	if spec.Type == BarTypeRange && sec.MinStep <= 0 {
+		return fmt.Errorf("инструмент %s: не задан минимальный шаг цены", symbol)
+	}
+	r := &WSRequestBase{
+		OpCode:   onAllTradesSubscribe,
+		Code:     symbol,
+		Exchange: c.Exchange,
+	}
+	// обработаем входящие параметры
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.Guid = "bars|" + r.Code + "|" + spec.Interval().String()
+
+	s := c.NewWsService(r)
+	s.aggregator = NewBarAggregator(symbol, spec, sec.MinStep, c.PublishCandleClosed)
+	return s.Do(ctx)
+}